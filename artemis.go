@@ -38,6 +38,15 @@ func init() {
 
 func main() {
 	ctxLog = log.WithField("version", version.Version).WithField("git-hash", version.GitHash).WithField("build-time", version.BuiltTimestamp)
+
+	if len(os.Args) > 1 && os.Args[1] == "simulate" {
+		if err := simulate(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Simulation failed: %s\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
 	userset := flag.NewFlagSet("artemisd", flag.ExitOnError)
 
 	printVersion := userset.Bool("version", false, "Print the version and exit")
@@ -83,6 +92,19 @@ func main() {
 	// JWT
 	cfgset.String("jwt_sign_key", "", "JWT signing key")
 
+	// Mass failure guard
+	cfgset.Float64("mass_failure_fraction", 0, "Freeze replacements of all ASGs when more than this part of nodes turn unhealthy within window, 0 disables it")
+	cfgset.Int("mass_failure_window", 300, "Window (in seconds) within which nodes have to turn unhealthy")
+	cfgset.Int("mass_failure_min_unhealthy", 2, "Minimal amount of nodes which have to turn unhealthy")
+	cfgset.String("mass_failure_alert_url", "", "URL to which freeze and unfreeze of replacements are posted")
+
+	// Shadow mode
+	cfgset.Bool("shadow_mode", false, "Evaluate all ASGs, but only record what would have been done to nodes")
+
+	// StatsD listener
+	cfgset.String("statsd_address", "", "UDP address (ip:port) to receive StatsD metrics on, empty disables listener")
+	cfgset.Int("statsd_flush_interval", 10, "Interval (in seconds) StatsD metrics are aggregated for")
+
 	globalconf.Register("", cfgset)
 	cfg, err := getConfig(cfgset, *cfgPath)
 	if err != nil {
@@ -186,6 +208,16 @@ func getConfig(flagset *flag.FlagSet, userCfgFile string) (*config.Config, error
 		CORSMaxAge:             (*flagset.Lookup("cors_max_age")).Value.(flag.Getter).Get().(int),
 		CORSOptionsPassThrough: (*flagset.Lookup("cors_options_pass_through")).Value.(flag.Getter).Get().(bool),
 		CORSDebug:              (*flagset.Lookup("cors_debug")).Value.(flag.Getter).Get().(bool),
+
+		MassFailureFraction:     (*flagset.Lookup("mass_failure_fraction")).Value.(flag.Getter).Get().(float64),
+		MassFailureWindow:       (*flagset.Lookup("mass_failure_window")).Value.(flag.Getter).Get().(int),
+		MassFailureMinUnhealthy: (*flagset.Lookup("mass_failure_min_unhealthy")).Value.(flag.Getter).Get().(int),
+		MassFailureAlertURL:     (*flagset.Lookup("mass_failure_alert_url")).Value.(flag.Getter).Get().(string),
+
+		ShadowMode: (*flagset.Lookup("shadow_mode")).Value.(flag.Getter).Get().(bool),
+
+		StatsdAddress:       (*flagset.Lookup("statsd_address")).Value.(flag.Getter).Get().(string),
+		StatsdFlushInterval: (*flagset.Lookup("statsd_flush_interval")).Value.(flag.Getter).Get().(int),
 	}
 
 	log.SetLevel(log.Level(cfg.Verbosity))
@@ -17,6 +17,19 @@ type Config struct {
 	CORSMaxAge             int
 	CORSOptionsPassThrough bool
 	CORSDebug              bool
+
+	// Global mass failure guard, disabled if fraction is 0
+	MassFailureFraction     float64
+	MassFailureWindow       int
+	MassFailureMinUnhealthy int
+	MassFailureAlertURL     string
+
+	// ShadowMode runs all ASGs in shadow mode, nothing is done to nodes
+	ShadowMode bool
+
+	// StatsD listener, disabled if address is empty. Flush interval in seconds
+	StatsdAddress       string
+	StatsdFlushInterval int
 }
 
 // StringToSlice return slice from "x,y,z"
@@ -0,0 +1,140 @@
+package domain
+
+import (
+	"math"
+	"sort"
+
+	"github.com/juju/errors"
+)
+
+const (
+	AggregationAvg   = Aggregation("avg")
+	AggregationMin   = Aggregation("min")
+	AggregationMax   = Aggregation("max")
+	AggregationSum   = Aggregation("sum")
+	AggregationCount = Aggregation("count")
+	AggregationP50   = Aggregation("p50")
+	AggregationP90   = Aggregation("p90")
+	AggregationP99   = Aggregation("p99")
+	// AggregationRate is change of value per second between first and last metric
+	AggregationRate = Aggregation("rate")
+	// AggregationEWMA is exponentially weighted moving average, the latest
+	// metrics weigh the most
+	AggregationEWMA = Aggregation("ewma")
+
+	defaultEWMAAlpha = 0.5
+)
+
+type (
+	Aggregation string
+
+	// Aggregator calculates single value from metrics of time window
+	Aggregator struct {
+		Function Aggregation
+		// Alpha is smoothing factor of EWMA, the higher it is the more
+		// the latest metric weighs
+		Alpha float64
+	}
+)
+
+// NewAggregator constructor, alpha is only used by EWMA and defaults to 0.5
+func NewAggregator(function Aggregation, alpha float64) (*Aggregator, error) {
+	switch function {
+	case AggregationAvg, AggregationMin, AggregationMax, AggregationSum, AggregationCount,
+		AggregationP50, AggregationP90, AggregationP99, AggregationRate:
+	case AggregationEWMA:
+		if alpha == 0 {
+			alpha = defaultEWMAAlpha
+		}
+
+		if alpha < 0 || alpha > 1 {
+			return nil, errors.Errorf("Alpha %v of EWMA has to be between 0 and 1", alpha)
+		}
+	default:
+		return nil, errors.Errorf("Aggregation [%s] is not supported", function)
+	}
+
+	return &Aggregator{
+		Function: function,
+		Alpha:    alpha,
+	}, nil
+}
+
+// Aggregate metrics into single value, 0 is returned if there are none.
+// Metrics are expected to be sorted by time, as queries return them
+func (a *Aggregator) Aggregate(metrics []Metric) float64 {
+	if len(metrics) == 0 {
+		return 0
+	}
+
+	fn := AggregationAvg
+	if a != nil {
+		fn = a.Function
+	}
+
+	values := make([]float64, len(metrics))
+	for i, m := range metrics {
+		values[i] = m.GetValue()
+	}
+
+	switch fn {
+	case AggregationMin:
+		min := values[0]
+		for _, v := range values {
+			min = math.Min(min, v)
+		}
+		return min
+	case AggregationMax:
+		max := values[0]
+		for _, v := range values {
+			max = math.Max(max, v)
+		}
+		return max
+	case AggregationSum:
+		return sum(values)
+	case AggregationCount:
+		return float64(len(values))
+	case AggregationP50:
+		return percentile(values, 50)
+	case AggregationP90:
+		return percentile(values, 90)
+	case AggregationP99:
+		return percentile(values, 99)
+	case AggregationRate:
+		first, last := metrics[0], metrics[len(metrics)-1]
+		seconds := last.GetTimestamp().Sub(first.GetTimestamp()).Seconds()
+		if seconds == 0 {
+			return 0
+		}
+		return (last.GetValue() - first.GetValue()) / seconds
+	case AggregationEWMA:
+		ewma := values[0]
+		for _, v := range values[1:] {
+			ewma = a.Alpha*v + (1-a.Alpha)*ewma
+		}
+		return ewma
+	}
+
+	return sum(values) / float64(len(values))
+}
+
+func sum(values []float64) float64 {
+	total := 0.0
+	for _, v := range values {
+		total = total + v
+	}
+
+	return total
+}
+
+// percentile of values, interpolated between the closest ranks
+func percentile(values []float64, p float64) float64 {
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
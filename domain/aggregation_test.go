@@ -0,0 +1,78 @@
+package domain
+
+import (
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type AggregationSuite struct{}
+
+var _ = Suite(&AggregationSuite{})
+
+func prepareValues(values ...float64) []Metric {
+	start := time.Now()
+	metrics := []Metric{}
+	for i, v := range values {
+		metrics = append(metrics, NewNamedMetric("cpu", "", nil, v, start.Add(time.Duration(i)*time.Second)))
+	}
+
+	return metrics
+}
+
+func (s *AggregationSuite) TestIfMetricsAreAggregated(c *C) {
+	metrics := prepareValues(4, 1, 3, 2, 10)
+
+	expected := map[Aggregation]float64{
+		AggregationAvg:   4,
+		AggregationMin:   1,
+		AggregationMax:   10,
+		AggregationSum:   20,
+		AggregationCount: 5,
+		AggregationP50:   3,
+		AggregationRate:  1.5,
+	}
+
+	for fn, value := range expected {
+		aggregator, err := NewAggregator(fn, 0)
+		c.Assert(err, IsNil)
+		c.Assert(aggregator.Aggregate(metrics), Equals, value, Commentf("%s", fn))
+	}
+
+	p90, err := NewAggregator(AggregationP90, 0)
+	c.Assert(err, IsNil)
+	c.Assert(p90.Aggregate(prepareValues(0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10)), Equals, 9.0)
+	c.Assert(p90.Aggregate(prepareValues(10, 20)), Equals, 19.0)
+
+	ewma, err := NewAggregator(AggregationEWMA, 0.5)
+	c.Assert(err, IsNil)
+	c.Assert(ewma.Aggregate(prepareValues(0, 4, 8)), Equals, 5.0)
+
+	// Without aggregator avg is calculated, negative values included
+	var avg *Aggregator
+	c.Assert(avg.Aggregate(prepareValues(-1, -3)), Equals, -2.0)
+	c.Assert(avg.Aggregate([]Metric{}), Equals, 0.0)
+
+	_, err = NewAggregator("median", 0)
+	c.Assert(err, ErrorMatches, "Aggregation \\[median\\] is not supported")
+
+	_, err = NewAggregator(AggregationEWMA, 2)
+	c.Assert(err, NotNil)
+}
+
+func (s *AggregationSuite) TestIfMetricIsAggregatedAcrossASG(c *C) {
+	now := time.Now()
+	asg := NewAutoScalingGroup(ID("asg-1"))
+	asg.Setup(NewNodeSet(prepareLocalNode(ID("node1")), prepareLocalNode(ID("node2"))), NewPolicySet())
+
+	asg.AddNamedMetrics(ID("node1"), []NamedMetric{NewNamedMetric("cpu", "percent", nil, 20, now.Add(-2*time.Second))})
+	asg.AddNamedMetrics(ID("node2"), []NamedMetric{NewNamedMetric("cpu", "percent", nil, 60, now.Add(-time.Second))})
+
+	max, err := NewAggregator(AggregationMax, 0)
+	c.Assert(err, IsNil)
+
+	from, to := now.Add(-time.Minute), now.Add(time.Second)
+	c.Assert(asg.AggregateMetric("cpu", nil, max, from, to), Equals, 60.0)
+	c.Assert(asg.AggregateMetric("cpu", nil, nil, from, to), Equals, 40.0)
+	c.Assert(asg.Nodes[ID("node1")].AggregateMetric("cpu", nil, max, from, to), Equals, 20.0)
+}
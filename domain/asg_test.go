@@ -121,6 +121,7 @@ func (s *ASGSuite) TestIfASGEvaluatesThatOneNodeShouldBeLaunchedAndBeforeThatSam
 				ID:     DigitalOcean,
 				APIKey: "some-key",
 			},
+			Reason: "Node [node1] has failed 3 consecutive health checks",
 		},
 		NodeID: ID("node1"),
 	})
@@ -206,6 +207,7 @@ func (s *ASGSuite) TestIfASGEvaluatesThatOneOfThreeDifferentNodeShouldBeLaunched
 				ID:     DigitalOcean,
 				APIKey: "some-key",
 			},
+			Reason: "Node [node1] has failed 3 consecutive health checks",
 		},
 		NodeID: ID("node1"),
 	})
@@ -301,6 +303,7 @@ func (s *ASGSuite) TestIfASGRemovesFailingNodeAndReplacesItWithNewOne(c *C) {
 				ID:     DigitalOcean,
 				APIKey: "some-key",
 			},
+			Reason: "Node [node1] has failed 1 consecutive health checks",
 		},
 		NodeID: ID("node1"),
 	})
@@ -368,6 +371,7 @@ func (s *ASGSuite) TestIfASGAddsUpNewNodesWhenDesiredIsIncreasedAfterInitialSetu
 				ID:     DigitalOcean,
 				APIKey: "some-key",
 			},
+			Reason: "Healthy nodes 1 are less than desired 2",
 		},
 	})
 
@@ -377,3 +381,45 @@ func (s *ASGSuite) TestIfASGAddsUpNewNodesWhenDesiredIsIncreasedAfterInitialSetu
 	c.Assert(len(asg.Commands), Equals, 0)
 	c.Assert(len(asg.Nodes), Equals, 2)
 }
+
+func (s *ASGSuite) TestIfDesiredCapacityIsSetWithinLimitsAndChecksStateIsKept(c *C) {
+	plc, err := NewDesiredNodeAmountPerProviderPolicy(ID("policy-1"), 1, 3, 1, 3, 0.7, time.Duration(-5*time.Second), Provider{
+		ID:     DigitalOcean,
+		APIKey: "some-key",
+	})
+	c.Assert(err, IsNil)
+
+	asg := NewAutoScalingGroup(ID("asg-1"))
+	err = asg.Setup(NewNodeSet(prepareNode(5, ID("node1"), 5)), NewPolicySet(plc))
+	c.Assert(err, IsNil)
+
+	// One failed check is recorded
+	err = asg.Evaluate()
+	c.Assert(err, IsNil)
+	c.Assert(plc.(*DesiredHealthyNodeAmountPerProviderPolicy).ConsecutiveChecksNum[ID("node1")], Equals, 1)
+
+	err = asg.SetDesiredCapacity(ID("policy-1"), 4, false)
+	c.Assert(err, ErrorMatches, "Desired 4 can not be more than max 3")
+
+	err = asg.SetDesiredCapacity(ID("policy-1"), 0, false)
+	c.Assert(err, ErrorMatches, "Desired 0 can not be less than min 1")
+
+	err = asg.SetDesiredCapacity(ID("policy-2"), 2, false)
+	c.Assert(err, NotNil)
+
+	err = asg.SetDesiredCapacity(ID("policy-1"), 2, false)
+	c.Assert(err, IsNil)
+	c.Assert(plc.(*DesiredHealthyNodeAmountPerProviderPolicy).Desired, Equals, 2)
+	c.Assert(plc.(*DesiredHealthyNodeAmountPerProviderPolicy).ConsecutiveChecksNum[ID("node1")], Equals, 1)
+
+	// Cooldown is honored only when asked
+	asg.Cooldown = time.Minute
+	asg.LastScalingActivity = time.Now()
+
+	err = asg.SetDesiredCapacity(ID("policy-1"), 3, true)
+	c.Assert(err, ErrorMatches, "ASG is in cooldown until .*")
+
+	err = asg.SetDesiredCapacity(ID("policy-1"), 3, false)
+	c.Assert(err, IsNil)
+	c.Assert(plc.(*DesiredHealthyNodeAmountPerProviderPolicy).Desired, Equals, 3)
+}
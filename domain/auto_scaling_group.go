@@ -2,12 +2,15 @@ package domain
 
 import (
 	"sort"
+	"sync"
 
 	"strings"
 
 	"time"
 
 	"fmt"
+	"io"
+	"os"
 
 	"github.com/juju/errors"
 )
@@ -21,7 +24,101 @@ type (
 		Policies PolicySet
 		Commands CommandSet
 
+		// Metrics reported for ASG as a whole, e.g. queue backlog
+		Metrics *MetricStore
+		// MetricRetention of ASG and its nodes, DefaultRetention if not set
+		MetricRetention Retention
+
+		// Cooldown is time which has to pass after last executed command
+		// before capacity can be changed again
+		Cooldown            time.Duration
+		LastScalingActivity time.Time
+
+		// WarmPool is optional, when set nodes are taken from it first
+		WarmPool *WarmPool
+
+		// HealthCheckGracePeriod is time after launch during which
+		// node is not judged unhealthy until it reports healthy
+		HealthCheckGracePeriod time.Duration
+
+		// HeartbeatTimeout is time after which node which has not
+		// reported anything is considered to be missing data
+		HeartbeatTimeout time.Duration
+
+		// HealthChecks are run by artemis against every node
+		HealthChecks []*HealthCheck
+
+		// Scrapers pull metrics from exporters running on every node
+		Scrapers []*Scraper
+
+		// HealthModel is optional, when set node health is combined from
+		// several signals instead of average of all health metrics
+		HealthModel *HealthModel
+
+		// Healing is optional, when set unhealthy nodes go through it
+		// instead of being relaunched right away
+		Healing *HealingLadder
+
+		// Hooks are optional, nodes wait for them when launched or terminated
+		Hooks *LifecycleHooks
+
+		// Breaker is optional, when set launches are suspended after too many
+		// of them have failed
+		Breaker *CircuitBreaker
+
+		// Guard is optional, when set replacements are frozen if too many
+		// nodes turn unhealthy at once. GlobalGuard is shared by all ASGs
+		// of supervisor
+		Guard       *MassFailureGuard
+		GlobalGuard *MassFailureGuard
+
+		// Credentials are optional, when set nodes have to present their own
+		// token when they report metrics
+		Credentials *NodeCredentials
+
+		// Rollout is optional, when set commands which would disrupt too
+		// many nodes at once are deferred to later cycles
+		Rollout *RolloutBudget
+
 		stop bool
+
+		// Shadow is optional, when set commands are only recorded as
+		// decisions and nothing is done to nodes
+		Shadow *ShadowLog
+
+		// Clock is optional, when set ASG uses it instead of real time
+		Clock Clock
+
+		// Log is optional, activity of ASG is written to stdout if it is not set
+		Log io.Writer
+
+		// planning is set on copy of ASG which is only planned, not executed
+		planning bool
+
+		// cycle is held by ASG loop while it runs one cycle
+		cycle sync.Mutex
+
+		// lock guards suspended processes and history which are changed over API,
+		// lifecycle actions completed in background and metrics queued by listeners
+		lock             sync.Mutex
+		suspended        map[Process]Suspension
+		history          []NodeEvent
+		lifecycleResults []lifecycleResult
+		queuedMetrics    []queuedMetrics
+
+		// replacing are nodes which are kept until their replacement, which
+		// they point to, is ready
+		replacing map[ID]ID
+		// surging are replacements which are not in service yet, they take
+		// rollout budget even once node they have replaced is gone
+		surging map[ID]bool
+		// healingNodes wait for their healing step to take effect
+		healingNodes map[ID]*healingProgress
+
+		// telemetry is set up with ASG, copy which is only planned has none
+		telemetry *telemetry
+		// streams of node agents, set up with ASG as well
+		streams *agentSessions
 	}
 
 	// AutoScalingGroupSet type
@@ -52,6 +149,31 @@ func (asg *AutoScalingGroup) Setup(nodes NodeSet, policies PolicySet) error {
 	asg.Nodes = nodes
 	asg.Policies = policies
 	asg.Commands = NewCommandSet()
+	asg.Metrics = NewMetricStore(asg.MetricRetention)
+	for _, node := range nodes {
+		asg.applyRetention(node)
+	}
+
+	if asg.telemetry == nil {
+		asg.telemetry = newTelemetry()
+	}
+
+	if asg.streams == nil {
+		asg.streams = newAgentSessions()
+	}
+
+	if asg.replacing == nil {
+		asg.replacing = map[ID]ID{}
+	}
+
+	if asg.surging == nil {
+		asg.surging = map[ID]bool{}
+	}
+
+	if asg.healingNodes == nil {
+		asg.healingNodes = map[ID]*healingProgress{}
+	}
+	asg.observeNodes()
 
 	return nil
 }
@@ -72,18 +194,310 @@ func (asg *AutoScalingGroup) ChangePolicy(policy Policy) error {
 
 // AddMetrics ...
 func (asg *AutoScalingGroup) AddMetrics(node ID, metrics MetricSeries) error {
+	return asg.addNodeMetrics(node, metrics.List())
+}
+
+// AddNamedMetrics adds metrics of any name reported by given node
+func (asg *AutoScalingGroup) AddNamedMetrics(node ID, metrics []NamedMetric) error {
+	return asg.addNodeMetrics(node, namedMetrics(metrics))
+}
+
+func (asg *AutoScalingGroup) addNodeMetrics(node ID, metrics []Metric) error {
 	if asg.State == ASGStateNew {
 		return errors.Errorf("ASG is in ASGStateNew state, use Setup() first!")
 	}
 
 	if _, ok := asg.Nodes[node]; !ok {
-		errors.Errorf("Node by ID %s was not found", node)
+		return errors.Errorf("Node by ID %s was not found", node)
+	}
+
+	now := asg.now()
+	asg.Nodes[node].RecordReport(now)
+	err := asg.Nodes[node].addMetrics(metrics, now)
+	if err == nil {
+		asg.telemetry.ingested(len(metrics))
+	}
+
+	return err
+}
+
+// QueueNamedMetrics hands metrics over to ASG loop which adds them on its next
+// cycle, then is called by the loop with the result. Metrics without node are
+// added to ASG itself. Unlike AddNamedMetrics it is safe to call from any goroutine
+func (asg *AutoScalingGroup) QueueNamedMetrics(node ID, metrics []NamedMetric, then func(error)) {
+	asg.queueMetrics(node, namedMetrics(metrics), then)
+}
+
+func (asg *AutoScalingGroup) queueMetrics(node ID, metrics []Metric, then func(error)) {
+	asg.lock.Lock()
+	asg.queuedMetrics = append(asg.queuedMetrics, queuedMetrics{node: node, metrics: metrics, then: then})
+	asg.lock.Unlock()
+}
+
+// addQueuedMetrics adds metrics queued since last cycle, it is called by ASG
+// loop so nodes are not changed under it
+func (asg *AutoScalingGroup) addQueuedMetrics() {
+	asg.lock.Lock()
+	queued := asg.queuedMetrics
+	asg.queuedMetrics = nil
+	asg.lock.Unlock()
+
+	for _, q := range queued {
+		var err error
+		if q.node == "" {
+			err = asg.addGroupMetrics(q.metrics)
+		} else {
+			err = asg.addNodeMetrics(q.node, q.metrics)
+		}
+		if q.then != nil {
+			q.then(err)
+		}
+	}
+}
+
+// SetDesiredCapacity changes desired capacity of given policy, change will be
+// picked up on next evaluation
+func (asg *AutoScalingGroup) SetDesiredCapacity(policyID ID, desired int, honorCooldown bool) error {
+	if asg.State == ASGStateNew {
+		return errors.Errorf("ASG is in ASGStateNew state, use Setup() first!")
+	}
+
+	policy, ok := asg.Policies[policyID]
+	if !ok {
+		return errors.Errorf("Policy by id %s was not found", policyID)
+	}
+
+	plc, ok := policy.(CapacityPolicy)
+	if !ok {
+		return errors.Errorf("Policy by id %s does not support setting desired capacity", policyID)
 	}
 
-	asg.Nodes[node].AddMetrics(metrics)
+	if honorCooldown && asg.now().Sub(asg.LastScalingActivity) < asg.Cooldown {
+		return errors.Errorf("ASG is in cooldown until %s", asg.LastScalingActivity.Add(asg.Cooldown).Format(time.RFC3339))
+	}
+
+	return errors.Trace(plc.SetDesiredCapacity(desired))
+}
+
+// CompleteLifecycleAction lets node which waits for given hook to continue
+func (asg *AutoScalingGroup) CompleteLifecycleAction(node ID, hookName string, result LifecycleResult) error {
+	if asg.Hooks == nil {
+		return errors.Errorf("ASG [%s] has no lifecycle hooks", asg.ID)
+	}
+
+	return asg.Hooks.Complete(node, hookName, result)
+}
+
+// RecordLifecycleActionHeartbeat extends timeout of given hook
+func (asg *AutoScalingGroup) RecordLifecycleActionHeartbeat(node ID, hookName string) error {
+	if asg.Hooks == nil {
+		return errors.Errorf("ASG [%s] has no lifecycle hooks", asg.ID)
+	}
+
+	return asg.Hooks.Heartbeat(node, hookName)
+}
+
+// runLifecycleHooks puts node into waiting state and runs hooks in background,
+// then is called by ASG loop once they are completed. Without hooks then is
+// called right away and its error is returned
+func (asg *AutoScalingGroup) runLifecycleHooks(node *Node, transition LifecycleTransition, then func(LifecycleResult) error) error {
+	if asg.Hooks == nil || !asg.Hooks.Has(transition) {
+		return then(LifecycleContinue)
+	}
+
+	state := node.State
+	switch transition {
+	case LifecycleLaunching:
+		node.ChangeState(NodeStatePendingWait)
+	case LifecycleTerminating:
+		node.ChangeState(NodeStateTerminatingWait)
+	}
+
+	go func() {
+		result := asg.Hooks.Run(asg.ID, node, transition)
+
+		asg.lock.Lock()
+		asg.lifecycleResults = append(asg.lifecycleResults, lifecycleResult{node: node, state: state, result: result, then: then})
+		asg.lock.Unlock()
+	}()
+
+	return nil
+}
+
+// completeLifecycleActions moves nodes whose hooks have been completed on to
+// their next state, it is called by ASG loop so nodes are not changed under it
+func (asg *AutoScalingGroup) completeLifecycleActions() {
+	asg.lock.Lock()
+	results := asg.lifecycleResults
+	asg.lifecycleResults = nil
+	asg.lock.Unlock()
+
+	for _, r := range results {
+		r.node.ChangeState(r.state)
+		if err := r.then(r.result); err != nil {
+			asg.logf("[%s] Lifecycle action of node [%s] : %s \n", asg.ID, r.node.ID, err)
+		}
+	}
+}
+
+// inTransition returns true if node waits for lifecycle hooks or for its
+// replacement, such node is neither judged nor picked by policies
+func (asg *AutoScalingGroup) inTransition(node *Node) bool {
+	_, replaced := asg.replacing[node.ID]
+	return node.State == NodeStatePendingWait || node.State == NodeStateTerminatingWait || replaced || asg.healingNodes[node.ID] != nil
+}
+
+// ResetCircuitBreaker closes circuit breaker so launches are resumed right away
+func (asg *AutoScalingGroup) ResetCircuitBreaker() error {
+	if asg.Breaker == nil {
+		return errors.Errorf("ASG [%s] has no circuit breaker", asg.ID)
+	}
+
+	asg.Breaker.Reset(asg.ID)
 	return nil
 }
 
+// ConfirmReplacements of nodes which are unhealthy now, unfreezes ASG guard
+func (asg *AutoScalingGroup) ConfirmReplacements() error {
+	if asg.Guard == nil {
+		return errors.Errorf("ASG [%s] has no mass failure guard", asg.ID)
+	}
+
+	asg.Guard.Confirm(asg.ID)
+	return nil
+}
+
+// replacementsFrozen returns true if either ASG or global guard has seen
+// too many nodes turning unhealthy at once
+func (asg *AutoScalingGroup) replacementsFrozen() bool {
+	now := asg.now()
+	frozen := false
+	for _, g := range []*MassFailureGuard{asg.Guard, asg.GlobalGuard} {
+		if g == nil {
+			continue
+		}
+
+		// Planning must not change what guard has seen
+		if asg.planning {
+			frozen = frozen || g.IsFrozen()
+			continue
+		}
+
+		if g.Observe(asg.ID, asg.Nodes, now) {
+			frozen = true
+		}
+	}
+
+	return frozen
+}
+
+// allowLaunch returns false while circuit breaker suspends launches
+func (asg *AutoScalingGroup) allowLaunch() bool {
+	if asg.Breaker == nil {
+		return true
+	}
+
+	return asg.Breaker.Allow(asg.ID, asg.now())
+}
+
+// launchStarted records node which was created by launch
+func (asg *AutoScalingGroup) launchStarted(node ID) {
+	if asg.Breaker == nil || asg.planning {
+		return
+	}
+
+	asg.Breaker.Launched(node)
+}
+
+// launchFailed records node which could not be launched or has never become
+// healthy, node is empty if it could not be created
+func (asg *AutoScalingGroup) launchFailed(node ID, reason string) {
+	if asg.Breaker == nil || asg.planning {
+		return
+	}
+
+	asg.Breaker.RecordFailure(asg.ID, node, asg.now(), reason)
+}
+
+// launchSucceeded records node which has become healthy after launch
+func (asg *AutoScalingGroup) launchSucceeded(node ID) {
+	if asg.Breaker == nil || asg.planning {
+		return
+	}
+
+	asg.Breaker.RecordSuccess(asg.ID, node)
+}
+
+// AddGroupMetrics adds metrics which are not bound to any node
+func (asg *AutoScalingGroup) AddGroupMetrics(metrics MetricSeries) error {
+	return asg.addGroupMetrics(metrics.List())
+}
+
+// AddGroupNamedMetrics adds metrics of any name which are not bound to any node,
+// backlog metrics are added the same way as by AddGroupMetrics
+func (asg *AutoScalingGroup) AddGroupNamedMetrics(metrics []NamedMetric) error {
+	return asg.addGroupMetrics(namedMetrics(metrics))
+}
+
+func (asg *AutoScalingGroup) addGroupMetrics(metrics []Metric) error {
+	if asg.State == ASGStateNew {
+		return errors.Errorf("ASG is in ASGStateNew state, use Setup() first!")
+	}
+
+	if asg.Metrics == nil {
+		asg.Metrics = NewMetricStore(asg.MetricRetention)
+	}
+
+	err := asg.Metrics.Add(asg.now(), metrics...)
+	if err == nil {
+		asg.telemetry.ingested(len(metrics))
+	}
+
+	return err
+}
+
+// QueryGroupMetrics returns metrics of ASG of given name reported between from and to
+func (asg *AutoScalingGroup) QueryGroupMetrics(metricType MetricType, labels Labels, from, to time.Time) []Metric {
+	return asg.Metrics.Query(metricType, labels, from, to)
+}
+
+// SetMetricRetention changes retention of ASG and all its nodes
+func (asg *AutoScalingGroup) SetMetricRetention(retention Retention) error {
+	if err := retention.Validate(); err != nil {
+		return errors.Trace(err)
+	}
+
+	asg.MetricRetention = retention
+	asg.Metrics.SetRetention(retention)
+	for _, node := range asg.Nodes {
+		asg.applyRetention(node)
+	}
+
+	return nil
+}
+
+// applyRetention of ASG to metrics of given node
+func (asg *AutoScalingGroup) applyRetention(node *Node) {
+	if node.Metrics == nil {
+		node.Metrics = NewMetricStore(asg.MetricRetention)
+		return
+	}
+
+	node.Metrics.SetRetention(asg.MetricRetention)
+}
+
+// AggregateMetric aggregates metrics of given name and labels reported by all
+// nodes and ASG itself between from and to, their data points are pooled
+func (asg *AutoScalingGroup) AggregateMetric(metricType MetricType, labels Labels, aggregator *Aggregator, from, to time.Time) float64 {
+	metrics := asg.QueryGroupMetrics(metricType, labels, from, to)
+	for _, node := range asg.Nodes {
+		metrics = append(metrics, node.QueryMetrics(metricType, labels, from, to)...)
+	}
+	sortMetrics(metrics)
+
+	return aggregator.Aggregate(metrics)
+}
+
 // RemoveNode ...
 func (asg *AutoScalingGroup) RemoveNode(node ID) error {
 	if asg.State == ASGStateNew {
@@ -94,8 +508,15 @@ func (asg *AutoScalingGroup) RemoveNode(node ID) error {
 		errors.Errorf("Node by ID %s was not found", node)
 	}
 
-	fmt.Printf("Remove node [%s] \n", node)
+	asg.logf("Remove node [%s] \n", node)
 	delete(asg.Nodes, node)
+	delete(asg.replacing, node)
+	delete(asg.surging, node)
+	delete(asg.healingNodes, node)
+	if asg.Credentials != nil {
+		asg.Credentials.Revoke(node)
+	}
+	asg.closeAgentSession(node)
 	return nil
 }
 
@@ -109,7 +530,8 @@ func (asg *AutoScalingGroup) AddNode(node *Node) error {
 		errors.Errorf("Node with ID %s already exists", node.ID)
 	}
 
-	fmt.Printf("Add node [%s] \n", node.ID)
+	asg.logf("Add node [%s] \n", node.ID)
+	asg.applyRetention(node)
 	asg.Nodes[node.ID] = node
 	return nil
 }
@@ -125,26 +547,81 @@ func (asg *AutoScalingGroup) Evaluate() error {
 		return errors.Errorf("ASG is in ASGStateNew state, use Setup() first!")
 	}
 
-	fmt.Printf("Nodes: %d \n", len(asg.Nodes))
+	asg.logf("Nodes: %d \n", len(asg.Nodes))
 
+	start := time.Now()
+	defer func() {
+		asg.telemetry.evaluated(time.Since(start))
+	}()
+
+	if asg.IsSuspended(ProcessReconcile) {
+		return nil
+	}
+
+	// Policies judge nodes every cycle, commands deferred by rollout budget
+	// go first as long as they are still valid
+	deferred := asg.Commands
+	asg.Commands = NewCommandSet()
 	for _, policy := range asg.Policies {
 		err := policy.Evaluate(asg)
 		if err != nil {
+			asg.Commands = deferred
 			return errors.Trace(err)
 		}
 	}
+	asg.keepDeferred(deferred)
 
 	return nil
 }
 
+// keepDeferred puts deferred commands before those just planned. Relaunch and
+// heal are kept while their node is still unhealthy and nothing else is
+// happening to it, other commands are planned by policies again if still needed
+func (asg *AutoScalingGroup) keepDeferred(deferred CommandSet) {
+	if len(deferred) == 0 {
+		return
+	}
+
+	commands := NewCommandSet()
+	targeted := map[ID]bool{}
+	for _, cmd := range deferred.sorted() {
+		id, ok := commandNode(cmd)
+		switch cmd.(type) {
+		case *Relaunch, *RelaunchLocal, *Heal:
+		default:
+			ok = false
+		}
+
+		node := asg.Nodes.GetByID(id)
+		if !ok || node == nil || node.State != NodeStateUnhealthy || asg.inTransition(node) || targeted[id] {
+			asg.RecordEvent("Deferred command %T dropped, it is not valid anymore", cmd)
+			continue
+		}
+
+		targeted[id] = true
+		commands[Order(len(commands)+1)] = cmd
+	}
+
+	for _, cmd := range asg.Commands.sorted() {
+		if id, ok := commandNode(cmd); ok && targeted[id] {
+			continue
+		}
+		commands[Order(len(commands)+1)] = cmd
+	}
+
+	asg.logf("[%s] %d deferred commands are kept \n", asg.ID, len(commands)-len(asg.Commands))
+	asg.Commands = commands
+}
+
 // Execute required commands created by policies
 func (asg *AutoScalingGroup) Execute() error {
 	if asg.State == ASGStateNew {
 		return errors.Errorf("ASG is in ASGStateNew state, use Setup() first!")
 	}
 
-	fmt.Printf("Commands: %d \n", len(asg.Commands))
+	asg.logf("Commands: %d \n", len(asg.Commands))
 	asg.State = ASGStateExecuting
+	defer asg.observeNodes()
 	var keys []int
 	keys = []int{}
 	for k := range asg.Commands {
@@ -152,13 +629,43 @@ func (asg *AutoScalingGroup) Execute() error {
 	}
 	sort.Ints(keys)
 
+	if asg.Shadow != nil {
+		asg.Shadow.Observe(asg.Nodes, asg.now())
+	}
+
+	var allowance *rolloutAllowance
+	if asg.Rollout != nil {
+		allowance = asg.Rollout.allowance(asg)
+	}
+
+	deferred := []Command{}
 	errs := []string{}
 	for _, k := range keys {
-		fmt.Printf("Command: %+v \n", asg.Commands[Order(k)])
+		if p, ok := asg.suspendedProcessOf(asg.Commands[Order(k)]); ok {
+			asg.RecordEvent("Command %T skipped, process %s is suspended", asg.Commands[Order(k)], p)
+			delete(asg.Commands, Order(k))
+			continue
+		}
+
+		if allowance != nil && !allowance.take(asg.Commands[Order(k)]) {
+			asg.RecordEvent("Command %T deferred, MaxSurge %d and MaxUnavailable %d are reached", asg.Commands[Order(k)], asg.Rollout.MaxSurge, asg.Rollout.MaxUnavailable)
+			deferred = append(deferred, asg.Commands[Order(k)])
+			delete(asg.Commands, Order(k))
+			continue
+		}
+
+		if asg.Shadow != nil {
+			asg.shadowExecute(asg.Commands[Order(k)])
+			delete(asg.Commands, Order(k))
+			continue
+		}
+
+		asg.logf("Command: %+v \n", asg.Commands[Order(k)])
 		// If case of error we add it to slice of errors
 		// and we do move on
 		// Commands are atomic and if one fails it should not influence others
 		err := asg.Commands[Order(k)].Execute(asg)
+		asg.telemetry.commandExecuted(asg.Commands[Order(k)], err)
 
 		// how to deal with this ?
 		// we just return what has failed
@@ -166,14 +673,20 @@ func (asg *AutoScalingGroup) Execute() error {
 			errs = append(errs, err.Error())
 		}
 
+		asg.LastScalingActivity = asg.now()
 		delete(asg.Commands, Order(k))
 	}
 
+	// Deferred commands keep their order and are executed on next cycle
+	for i, cmd := range deferred {
+		asg.Commands[Order(i+1)] = cmd
+	}
+
+	asg.State = ASGStateActive
 	if len(errs) > 0 {
 		return errors.Errorf("Execution finished with these errors - %s", strings.Join(errs, ":"))
 	}
 
-	asg.State = ASGStateActive
 	return nil
 }
 
@@ -181,30 +694,170 @@ func (asg *AutoScalingGroup) Execute() error {
 func (asg *AutoScalingGroup) Run() error {
 	for {
 		// Stop
-		if asg.stop {
+		if asg.stopped() {
 			return nil
 		}
 
-		err := asg.Evaluate()
-		if err != nil {
+		if err := asg.runCycle(); err != nil {
 			return err
 		}
-		err = asg.Execute()
+		time.Sleep(time.Second * 5)
+		asg.logf("[%s] OK \n", asg.ID)
+	}
+}
+
+// runCycle holds cycle lock, so that nodes, policies and commands are not
+// looked at by others while they are being changed
+func (asg *AutoScalingGroup) runCycle() error {
+	asg.cycle.Lock()
+	defer asg.cycle.Unlock()
+
+	asg.completeLifecycleActions()
+	asg.addQueuedMetrics()
+	asg.refreshBootstrap()
+	asg.refillWarmPool()
+	asg.RunHealthChecks()
+	asg.continueHealing()
+
+	err := asg.Evaluate()
+	if err != nil {
+		return err
+	}
+	err = asg.Execute()
+
+	// Failed commands are retried on next cycle, circuit breaker
+	// takes care of those which keep failing
+	if err != nil {
+		asg.logf("[%s] %s \n", asg.ID, err)
+	}
+
+	return nil
+}
+
+// logf writes activity of ASG to its Log
+func (asg *AutoScalingGroup) logf(format string, args ...interface{}) {
+	if asg.Log == nil {
+		fmt.Fprintf(os.Stdout, format, args...)
+		return
+	}
+
+	fmt.Fprintf(asg.Log, format, args...)
+}
+
+// now returns time of ASG clock, real time if it has none
+func (asg *AutoScalingGroup) now() time.Time {
+	if asg.Clock == nil {
+		return time.Now()
+	}
+
+	return asg.Clock.Now()
+}
+
+// IsHealthy returns true if node health in given range reaches threshold
+func (asg *AutoScalingGroup) IsHealthy(node *Node, threshold float64, from, to time.Time) bool {
+	return asg.isHealthy(node, threshold, nil, from, to)
+}
+
+// isHealthy aggregates health of node with given aggregator, health model
+// judges signals on its own
+func (asg *AutoScalingGroup) isHealthy(node *Node, threshold float64, aggregator *Aggregator, from, to time.Time) bool {
+	if asg.HealthModel != nil {
+		return asg.HealthModel.Evaluate(node, threshold, from, to)
+	}
+
+	return round(node.AggregateMetric(HealthMetricType, nil, aggregator, from, to), .5, 2) >= threshold
+}
+
+// suspendedProcessOf returns suspended process given command belongs to
+func (asg *AutoScalingGroup) suspendedProcessOf(cmd Command) (Process, bool) {
+	for _, p := range commandProcesses(cmd) {
+		if asg.IsSuspended(p) {
+			return p, true
+		}
+	}
 
-		if err != nil {
-			return err
+	return "", false
+}
+
+// RunHealthChecks which are due and record their results as node metrics
+func (asg *AutoScalingGroup) RunHealthChecks() {
+	if asg.IsSuspended(ProcessHealthCheck) {
+		return
+	}
+
+	now := asg.now()
+	for _, hc := range asg.HealthChecks {
+		if hc.Due(now) {
+			hc.Run(asg.Nodes, now)
 		}
-		time.Sleep(time.Second * 5)
-		fmt.Printf("[%s] OK \n", asg.ID)
+	}
+
+	for _, s := range asg.Scrapers {
+		if s.Due(now) {
+			s.Run(asg.Nodes, now, asg.logf)
+		}
+	}
+
+	if asg.HealthModel != nil {
+		asg.HealthModel.RecordProviderStatus(asg.Nodes, now)
 	}
 }
 
+// refillWarmPool in background, nodes which are still being prepared are
+// tracked by the pool itself so it is safe to call it every cycle
+func (asg *AutoScalingGroup) refillWarmPool() {
+	if asg.WarmPool == nil || asg.Shadow != nil || asg.IsSuspended(ProcessLaunch) {
+		return
+	}
+
+	provider := asg.launchProvider(asg.WarmPool.Provider)
+	go func() {
+		if err := asg.WarmPool.Refill(provider); err != nil {
+			asg.logf("[%s] Could not refill warm pool : %s \n", asg.ID, err)
+		}
+	}()
+}
+
+// refreshBootstrap rotates bootstrap token of ASG once it is older than TTL,
+// nodes launched afterwards get new one
+func (asg *AutoScalingGroup) refreshBootstrap() {
+	if asg.Credentials == nil {
+		return
+	}
+
+	if err := asg.Credentials.RefreshBootstrap(asg.now()); err != nil {
+		asg.logf("[%s] Could not rotate bootstrap token : %s \n", asg.ID, err)
+	}
+}
+
+// launchProvider returns provider nodes are launched with, user data carries
+// bootstrap token when nodes need credentials
+func (asg *AutoScalingGroup) launchProvider(provider Provider) Provider {
+	if asg.Credentials != nil {
+		provider.UserData = asg.Credentials.UserData(asg.ID, provider.UserData)
+	}
+
+	return provider
+}
+
 // Stop ASG
 func (asg *AutoScalingGroup) Stop() error {
+	asg.lock.Lock()
 	asg.stop = true
+	asg.lock.Unlock()
+
 	return nil
 }
 
+// stopped returns true once ASG has been stopped, ASG loop checks it
+// every cycle
+func (asg *AutoScalingGroup) stopped() bool {
+	asg.lock.Lock()
+	defer asg.lock.Unlock()
+
+	return asg.stop
+}
+
 // Remove ...
 func (asg *AutoScalingGroup) Remove() error {
 	if asg.State == ASGStateNew {
@@ -0,0 +1,264 @@
+package domain
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+const (
+	// BreakerClosed lets all launches through
+	BreakerClosed = BreakerState("closed")
+	// BreakerOpen suspends launches until backoff passes
+	BreakerOpen = BreakerState("open")
+	// BreakerHalfOpen lets single trial launch through
+	BreakerHalfOpen = BreakerState("half-open")
+
+	maxBreakerEvents = 50
+	// defaultTrialTimeout after which unfinished trial launch counts as failed
+	defaultTrialTimeout = 10 * time.Minute
+)
+
+type (
+	BreakerState string
+
+	// CircuitBreaker suspends launches of ASG when too many of them fail within
+	// Window. Launch fails if node could not be created or if it has never
+	// become healthy. While open, launches are suspended for Backoff which is
+	// doubled on every trip up to MaxBackoff, then single trial launch is let
+	// through and its outcome either closes breaker or opens it again. Trial
+	// which has no outcome within TrialTimeout counts as failed
+	CircuitBreaker struct {
+		sync.Mutex
+		Threshold  int
+		Window     time.Duration
+		Backoff    time.Duration
+		MaxBackoff time.Duration
+		// TrialTimeout defaults to 10 minutes
+		TrialTimeout time.Duration
+		// AlertURL is optional, breaker state changes are posted to it
+		AlertURL string
+
+		State    BreakerState
+		Failures []time.Time
+		Trips    int
+		RetryAt  time.Time
+		Events   []NodeEvent
+		// TrialNode is node launched by trial, only its outcome counts
+		TrialNode ID
+
+		trial   bool
+		trialAt time.Time
+	}
+
+	// BreakerAlert is sent to AlertURL when breaker changes its state
+	BreakerAlert struct {
+		ASGID    ID
+		State    BreakerState
+		Failures int
+		RetryAt  time.Time
+		Message  string
+	}
+)
+
+// NewCircuitBreaker constructor
+func NewCircuitBreaker(threshold int, window, backoff, maxBackoff time.Duration, alertURL string) (*CircuitBreaker, error) {
+	if threshold <= 0 {
+		return nil, errors.Errorf("Threshold %d has to be more than 0", threshold)
+	}
+
+	if window <= 0 {
+		return nil, errors.Errorf("Window %s has to be more than 0", window)
+	}
+
+	if backoff <= 0 {
+		return nil, errors.Errorf("Backoff %s has to be more than 0", backoff)
+	}
+
+	if maxBackoff == 0 {
+		maxBackoff = backoff
+	}
+
+	if maxBackoff < backoff {
+		return nil, errors.Errorf("MaxBackoff %s can not be less than Backoff %s", maxBackoff, backoff)
+	}
+
+	return &CircuitBreaker{
+		Threshold:    threshold,
+		Window:       window,
+		Backoff:      backoff,
+		MaxBackoff:   maxBackoff,
+		TrialTimeout: defaultTrialTimeout,
+		AlertURL:     alertURL,
+		State:        BreakerClosed,
+	}, nil
+}
+
+// SetTrialTimeout defines how long trial launch can take before it counts as
+// failed, timeout defaults to 10 minutes
+func (cb *CircuitBreaker) SetTrialTimeout(timeout time.Duration) error {
+	if timeout < 0 {
+		return errors.Errorf("TrialTimeout %s can not be negative", timeout)
+	}
+
+	if timeout == 0 {
+		timeout = defaultTrialTimeout
+	}
+
+	cb.Lock()
+	defer cb.Unlock()
+	cb.TrialTimeout = timeout
+
+	return nil
+}
+
+// Allow returns true if launch can be started now. Once backoff has passed
+// breaker goes half-open and lets through only one launch until its outcome
+// is known or TrialTimeout passes
+func (cb *CircuitBreaker) Allow(asgID ID, now time.Time) bool {
+	cb.Lock()
+	defer cb.Unlock()
+
+	switch cb.State {
+	case BreakerOpen:
+		if now.Before(cb.RetryAt) {
+			return false
+		}
+		cb.change(asgID, BreakerHalfOpen, "Backoff has passed, trying single launch")
+		cb.startTrial(now)
+		return true
+	case BreakerHalfOpen:
+		if !cb.trial {
+			cb.startTrial(now)
+			return true
+		}
+		if now.Sub(cb.trialAt) >= cb.TrialTimeout {
+			cb.trip(asgID, now, fmt.Sprintf("Trial launch has not finished within %s", cb.TrialTimeout))
+		}
+		return false
+	}
+
+	return true
+}
+
+// Launched records node created by launch, while half-open it is trial node
+func (cb *CircuitBreaker) Launched(node ID) {
+	cb.Lock()
+	defer cb.Unlock()
+
+	if cb.State == BreakerHalfOpen && cb.trial && cb.TrialNode == "" {
+		cb.TrialNode = node
+	}
+}
+
+// RecordFailure of launch of given node, empty when node could not be created.
+// Breaker is opened when Threshold of failures is reached within Window or
+// when trial launch has failed
+func (cb *CircuitBreaker) RecordFailure(asgID ID, node ID, now time.Time, reason string) {
+	cb.Lock()
+	defer cb.Unlock()
+
+	cb.Failures = append(cb.Failures, now)
+	from := now.Add(-cb.Window)
+	for len(cb.Failures) > 0 && cb.Failures[0].Before(from) {
+		cb.Failures = cb.Failures[1:]
+	}
+
+	switch cb.State {
+	case BreakerHalfOpen:
+		// Nodes launched before trial do not decide about it
+		if node == "" || node == cb.TrialNode {
+			cb.trip(asgID, now, fmt.Sprintf("Trial launch has failed : %s", reason))
+		}
+	case BreakerClosed:
+		if len(cb.Failures) >= cb.Threshold {
+			cb.trip(asgID, now, fmt.Sprintf("%d launches have failed within %s, last : %s", len(cb.Failures), cb.Window, reason))
+		}
+	}
+}
+
+// RecordSuccess of launch of given node, only trial node closes breaker
+func (cb *CircuitBreaker) RecordSuccess(asgID ID, node ID) {
+	cb.Lock()
+	defer cb.Unlock()
+
+	if cb.State != BreakerHalfOpen || cb.TrialNode == "" || node != cb.TrialNode {
+		return
+	}
+
+	cb.close(asgID, "Trial launch has succeeded")
+}
+
+// Reset closes breaker and forgets all failures
+func (cb *CircuitBreaker) Reset(asgID ID) {
+	cb.Lock()
+	defer cb.Unlock()
+
+	cb.close(asgID, "Reset manually")
+}
+
+func (cb *CircuitBreaker) trip(asgID ID, now time.Time, reason string) {
+	backoff := cb.Backoff
+	for i := 0; i < cb.Trips && backoff < cb.MaxBackoff; i++ {
+		backoff = backoff * 2
+	}
+	if backoff > cb.MaxBackoff {
+		backoff = cb.MaxBackoff
+	}
+
+	cb.Trips++
+	cb.endTrial()
+	cb.RetryAt = now.Add(backoff)
+	cb.change(asgID, BreakerOpen, fmt.Sprintf("%s, launches are suspended for %s", reason, backoff))
+}
+
+func (cb *CircuitBreaker) close(asgID ID, reason string) {
+	cb.Trips = 0
+	cb.endTrial()
+	cb.Failures = nil
+	cb.RetryAt = time.Time{}
+	cb.change(asgID, BreakerClosed, reason)
+}
+
+func (cb *CircuitBreaker) startTrial(now time.Time) {
+	cb.trial = true
+	cb.trialAt = now
+	cb.TrialNode = ""
+}
+
+func (cb *CircuitBreaker) endTrial() {
+	cb.trial = false
+	cb.trialAt = time.Time{}
+	cb.TrialNode = ""
+}
+
+// change state of breaker, record it and alert about it in background
+func (cb *CircuitBreaker) change(asgID ID, state BreakerState, message string) {
+	cb.State = state
+	cb.Events = append(cb.Events, NodeEvent{
+		Time:    time.Now(),
+		Message: fmt.Sprintf("Circuit breaker %s: %s", state, message),
+	})
+
+	if len(cb.Events) > maxBreakerEvents {
+		cb.Events = cb.Events[len(cb.Events)-maxBreakerEvents:]
+	}
+
+	fmt.Printf("[%s] Circuit breaker %s: %s \n", asgID, state, message)
+
+	alert := BreakerAlert{
+		ASGID:    asgID,
+		State:    state,
+		Failures: len(cb.Failures),
+		RetryAt:  cb.RetryAt,
+		Message:  message,
+	}
+
+	go func(url string) {
+		if err := notify(url, alert); err != nil {
+			fmt.Printf("[%s] Could not send circuit breaker alert : %s \n", asgID, err)
+		}
+	}(cb.AlertURL)
+}
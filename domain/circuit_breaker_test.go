@@ -0,0 +1,183 @@
+package domain
+
+import (
+	"errors"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type CircuitBreakerSuite struct{}
+
+var _ = Suite(&CircuitBreakerSuite{})
+
+func (s *CircuitBreakerSuite) TestIfBreakerTripsOnlyWhenFailuresAreWithinWindow(c *C) {
+	cb, err := NewCircuitBreaker(3, time.Minute, time.Second*10, time.Second*30, "")
+	c.Assert(err, IsNil)
+
+	now := time.Now()
+	cb.RecordFailure(ID("asg-1"), "", now.Add(-2*time.Minute), "old")
+	cb.RecordFailure(ID("asg-1"), "", now.Add(-time.Second), "first")
+	cb.RecordFailure(ID("asg-1"), "", now, "second")
+	c.Assert(cb.State, Equals, BreakerClosed)
+	c.Assert(len(cb.Failures), Equals, 2)
+	c.Assert(cb.Allow(ID("asg-1"), now), Equals, true)
+
+	cb.RecordFailure(ID("asg-1"), "", now, "third")
+	c.Assert(cb.State, Equals, BreakerOpen)
+	c.Assert(cb.RetryAt, Equals, now.Add(time.Second*10))
+	c.Assert(cb.Allow(ID("asg-1"), now.Add(time.Second*9)), Equals, false)
+	c.Assert(cb.Events[len(cb.Events)-1].Message, Equals, "Circuit breaker open: 3 launches have failed within 1m0s, last : third, launches are suspended for 10s")
+}
+
+func (s *CircuitBreakerSuite) TestIfBreakerBacksOffExponentiallyAndClosesAfterTrialLaunch(c *C) {
+	cb, err := NewCircuitBreaker(1, time.Minute, time.Second*10, time.Second*30, "")
+	c.Assert(err, IsNil)
+
+	now := time.Now()
+	cb.RecordFailure(ID("asg-1"), "", now, "failed")
+	c.Assert(cb.State, Equals, BreakerOpen)
+
+	// Only single trial launch is let through
+	now = now.Add(time.Second * 10)
+	c.Assert(cb.Allow(ID("asg-1"), now), Equals, true)
+	c.Assert(cb.State, Equals, BreakerHalfOpen)
+	c.Assert(cb.Allow(ID("asg-1"), now), Equals, false)
+
+	cb.RecordFailure(ID("asg-1"), "", now, "failed again")
+	c.Assert(cb.State, Equals, BreakerOpen)
+	c.Assert(cb.RetryAt, Equals, now.Add(time.Second*20))
+
+	now = now.Add(time.Second * 20)
+	c.Assert(cb.Allow(ID("asg-1"), now), Equals, true)
+	cb.RecordFailure(ID("asg-1"), "", now, "failed again")
+	c.Assert(cb.RetryAt, Equals, now.Add(time.Second*30))
+
+	now = now.Add(time.Second * 30)
+	c.Assert(cb.Allow(ID("asg-1"), now), Equals, true)
+	cb.RecordFailure(ID("asg-1"), "", now, "failed again")
+	c.Assert(cb.RetryAt, Equals, now.Add(time.Second*30))
+
+	now = now.Add(time.Second * 30)
+	c.Assert(cb.Allow(ID("asg-1"), now), Equals, true)
+	cb.Launched(ID("node2"))
+	c.Assert(cb.TrialNode, Equals, ID("node2"))
+
+	// Only trial node decides, nodes launched before it do not
+	cb.RecordSuccess(ID("asg-1"), ID("node1"))
+	cb.RecordFailure(ID("asg-1"), ID("node1"), now, "old node failed")
+	c.Assert(cb.State, Equals, BreakerHalfOpen)
+
+	cb.RecordSuccess(ID("asg-1"), ID("node2"))
+	c.Assert(cb.State, Equals, BreakerClosed)
+	c.Assert(cb.TrialNode, Equals, ID(""))
+	c.Assert(cb.Trips, Equals, 0)
+	c.Assert(len(cb.Failures), Equals, 0)
+	c.Assert(cb.Allow(ID("asg-1"), now), Equals, true)
+}
+
+func (s *CircuitBreakerSuite) TestIfTrialWithoutOutcomeFailsAfterTimeout(c *C) {
+	cb, err := NewCircuitBreaker(1, time.Minute, time.Second*10, time.Second*30, "")
+	c.Assert(err, IsNil)
+	c.Assert(cb.TrialTimeout, Equals, defaultTrialTimeout)
+	c.Assert(cb.SetTrialTimeout(-time.Minute), NotNil)
+	c.Assert(cb.SetTrialTimeout(time.Minute), IsNil)
+
+	now := time.Now()
+	cb.RecordFailure(ID("asg-1"), "", now, "failed")
+
+	now = now.Add(time.Second * 10)
+	c.Assert(cb.Allow(ID("asg-1"), now), Equals, true)
+	cb.Launched(ID("node1"))
+	c.Assert(cb.Allow(ID("asg-1"), now.Add(59*time.Second)), Equals, false)
+	c.Assert(cb.State, Equals, BreakerHalfOpen)
+
+	// Trial node was removed before it has become healthy or unhealthy
+	now = now.Add(time.Minute)
+	c.Assert(cb.Allow(ID("asg-1"), now), Equals, false)
+	c.Assert(cb.State, Equals, BreakerOpen)
+	c.Assert(cb.RetryAt, Equals, now.Add(time.Second*20))
+	c.Assert(cb.TrialNode, Equals, ID(""))
+	c.Assert(cb.Events[len(cb.Events)-1].Message, Equals, "Circuit breaker open: Trial launch has not finished within 1m0s, launches are suspended for 20s")
+
+	// Success of former trial node does not close breaker
+	cb.RecordSuccess(ID("asg-1"), ID("node1"))
+	c.Assert(cb.State, Equals, BreakerOpen)
+}
+
+func (s *CircuitBreakerSuite) TestIfLaunchesAreSuspendedUntilBreakerIsReset(c *C) {
+	drv := registerTestDriver()
+	drv.createErr = errors.New("image is broken")
+
+	asg := NewAutoScalingGroup(ID("asg-1"))
+	asg.Setup(NewNodeSet(), NewPolicySet())
+
+	cb, err := NewCircuitBreaker(2, time.Minute, time.Minute, time.Hour, "")
+	c.Assert(err, IsNil)
+	asg.Breaker = cb
+
+	cmd := &Launch{BaseCommand: BaseCommand{Provider: Provider{ID: testProviderID}}}
+	c.Assert(cmd.Execute(asg), NotNil)
+	c.Assert(cmd.Execute(asg), NotNil)
+	c.Assert(cb.State, Equals, BreakerOpen)
+
+	// Driver is not called at all
+	drv.createErr = nil
+	c.Assert(cmd.Execute(asg), IsNil)
+	c.Assert(len(drv.created), Equals, 0)
+	c.Assert(len(asg.Nodes), Equals, 0)
+
+	c.Assert(asg.ResetCircuitBreaker(), IsNil)
+	c.Assert(cb.State, Equals, BreakerClosed)
+	c.Assert(cb.Events[len(cb.Events)-1].Message, Equals, "Circuit breaker closed: Reset manually")
+	c.Assert(cmd.Execute(asg), IsNil)
+	c.Assert(len(drv.created), Equals, 1)
+
+	asg.Breaker = nil
+	c.Assert(asg.ResetCircuitBreaker(), NotNil)
+}
+
+func (s *CircuitBreakerSuite) TestIfNodeWhichHasNeverBecomeHealthyIsFailedLaunch(c *C) {
+	node := prepareLocalNode(ID("node1"))
+	node.AddMetrics(prepareMetrics(5, 5))
+
+	asg := NewAutoScalingGroup(ID("asg-1"))
+	asg.Setup(NewNodeSet(node), NewPolicySet())
+
+	cb, err := NewCircuitBreaker(1, time.Minute, time.Minute, time.Hour, "")
+	c.Assert(err, IsNil)
+	asg.Breaker = cb
+
+	plc, err := NewDesiredNodeAmountPerProviderPolicy(ID("policy-1"), 1, 1, 1, 1, 0.7, time.Duration(-5*time.Second), Provider{ID: testProviderID})
+	c.Assert(err, IsNil)
+
+	err = plc.Evaluate(asg)
+	c.Assert(err, IsNil)
+	c.Assert(node.State, Equals, NodeStateUnhealthy)
+	c.Assert(cb.State, Equals, BreakerOpen)
+
+	// Node which has been in service is not a failed launch
+	cb.Reset(asg.ID)
+	node.ChangeState(NodeStateInService)
+	err = plc.Evaluate(asg)
+	c.Assert(err, IsNil)
+	c.Assert(cb.State, Equals, BreakerClosed)
+}
+
+func (s *CircuitBreakerSuite) TestIfBreakerSettingsAreValidated(c *C) {
+	_, err := NewCircuitBreaker(0, time.Minute, time.Minute, 0, "")
+	c.Assert(err, NotNil)
+
+	_, err = NewCircuitBreaker(1, 0, time.Minute, 0, "")
+	c.Assert(err, NotNil)
+
+	_, err = NewCircuitBreaker(1, time.Minute, 0, 0, "")
+	c.Assert(err, NotNil)
+
+	_, err = NewCircuitBreaker(1, time.Minute, time.Minute, time.Second, "")
+	c.Assert(err, NotNil)
+
+	cb, err := NewCircuitBreaker(1, time.Minute, time.Minute, 0, "")
+	c.Assert(err, IsNil)
+	c.Assert(cb.MaxBackoff, Equals, time.Minute)
+}
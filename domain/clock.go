@@ -0,0 +1,49 @@
+package domain
+
+import (
+	"sync"
+	"time"
+)
+
+type (
+	// Clock tells ASG what time it is, simulation replaces real time with
+	// virtual one
+	Clock interface {
+		Now() time.Time
+	}
+
+	// VirtualClock only moves when it is told to
+	VirtualClock struct {
+		sync.Mutex
+		now time.Time
+	}
+)
+
+// NewVirtualClock constructor
+func NewVirtualClock(start time.Time) *VirtualClock {
+	return &VirtualClock{now: start}
+}
+
+// Now returns virtual time
+func (vc *VirtualClock) Now() time.Time {
+	vc.Lock()
+	defer vc.Unlock()
+
+	return vc.now
+}
+
+// Set virtual time
+func (vc *VirtualClock) Set(t time.Time) {
+	vc.Lock()
+	defer vc.Unlock()
+
+	vc.now = t
+}
+
+// Advance virtual time by given duration
+func (vc *VirtualClock) Advance(d time.Duration) {
+	vc.Lock()
+	defer vc.Unlock()
+
+	vc.now = vc.now.Add(d)
+}
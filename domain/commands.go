@@ -1,6 +1,7 @@
 package domain
 
 import (
+	"sort"
 	"time"
 
 	"golang.org/x/oauth2"
@@ -27,6 +28,8 @@ type (
 		State    CommandState
 		Error    *CMDError
 		Timeout  time.Duration
+		// Reason why policy has created the command
+		Reason string
 	}
 
 	BaseCommands []BaseCommand
@@ -38,3 +41,42 @@ func (t *TokenSource) Token() (*oauth2.Token, error) {
 	}
 	return token, nil
 }
+
+// GetReason why command has been created
+func (bc *BaseCommand) GetReason() string {
+	return bc.Reason
+}
+
+// sorted returns commands in their order
+func (cs CommandSet) sorted() []Command {
+	keys := []int{}
+	for k := range cs {
+		keys = append(keys, int(k))
+	}
+	sort.Ints(keys)
+
+	commands := []Command{}
+	for _, k := range keys {
+		commands = append(commands, cs[Order(k)])
+	}
+
+	return commands
+}
+
+// commandNode returns node given command is executed on, false if it creates new one
+func commandNode(cmd Command) (ID, bool) {
+	switch c := cmd.(type) {
+	case *Relaunch:
+		return c.NodeID, true
+	case *RelaunchLocal:
+		return c.NodeID, true
+	case *Terminate:
+		return c.NodeID, true
+	case *TerminateLocal:
+		return c.NodeID, true
+	case *Heal:
+		return c.NodeID, true
+	}
+
+	return "", false
+}
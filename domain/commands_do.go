@@ -1,14 +1,9 @@
 package domain
 
 import (
-	"fmt"
-	"net"
 	"time"
 
-	"strconv"
-
-	"github.com/digitalocean/godo"
-	"golang.org/x/oauth2"
+	"github.com/juju/errors"
 )
 
 type (
@@ -30,84 +25,40 @@ type (
 )
 
 func (lc *Launch) Execute(asg *AutoScalingGroup) error {
-
-	dropletName := "auto-" + strconv.Itoa(time.Now().Nanosecond())
-	tokenSource := &TokenSource{
-		AccessToken: lc.Provider.APIKey,
+	driver, err := NewDriver(asg.launchProvider(lc.Provider))
+	if err != nil {
+		return err
 	}
 
-	oauthClient := oauth2.NewClient(oauth2.NoContext, tokenSource)
-	client := godo.NewClient(oauthClient)
-
-	createRequest := &godo.DropletCreateRequest{
-		Name:   dropletName,
-		Region: lc.Provider.Region,
-		Size:   lc.Provider.Size,
-		Image: godo.DropletCreateImage{
-			Slug: lc.Provider.Image,
-		},
-		PrivateNetworking: true,
-		SSHKeys: []godo.DropletCreateSSHKey{
-			godo.DropletCreateSSHKey{
-				Fingerprint: lc.Provider.SSHKey,
-			},
-		},
+	if !asg.allowLaunch() {
+		asg.logf("Launch for [%s] is suspended by circuit breaker \n", asg.ID)
+		return nil
 	}
 
-	newDroplet, _, err := client.Droplets.Create(createRequest)
-
+	start := time.Now()
+	node, err := launchNode(asg, driver, lc.Provider)
 	if err != nil {
-		fmt.Printf("Could not launch droplet : %s\n\n", err)
+		asg.launchFailed("", err.Error())
 		return err
 	}
+	asg.launchStarted(node.ID)
 
-	status := newDroplet.Status
-	for {
-		fmt.Printf("Droplet [%d] status [%s] : \n\n", newDroplet.ID, status)
-		if status == "active" {
-			break
-		}
+	asg.logf("Setting up new Node for [%s] \n", asg.ID)
+	// Add new node
+	asg.AddNode(node)
 
-		d, _, err := client.Droplets.Get(newDroplet.ID)
-		if err != nil {
-			fmt.Printf("Could not get status for droplet : %s\n\n", err)
-			return err
+	err = asg.runLifecycleHooks(node, LifecycleLaunching, func(result LifecycleResult) error {
+		if result != LifecycleContinue {
+			return abandonLaunch(asg, driver, node)
 		}
 
-		status = d.Status
-		// timeout needed
-		time.Sleep(time.Second * 5)
-	}
-
-	publicIP, err := newDroplet.PublicIPv4()
-	if err != nil {
-		fmt.Printf("Could not get public IP : %s\n\n", err)
-		return err
-	}
-
-	privateIP, err := newDroplet.PrivateIPv4()
+		asg.telemetry.launched(time.Since(start))
+		return nil
+	})
 	if err != nil {
-		fmt.Printf("Could not get private IP : %s\n\n", err)
 		return err
 	}
 
-	fmt.Printf("Setting up new Node for [%s] \n", asg.ID)
-	// API call
-	node := NewNode()
-	node.Setup(
-		ID(strconv.Itoa(newDroplet.ID)),
-		lc.Provider,
-		NetworkInterface{
-			IP: net.ParseIP(privateIP),
-		},
-		NetworkInterface{
-			IP: net.ParseIP(publicIP),
-		},
-	)
-
-	// Add new node
-	asg.AddNode(node)
-
 	// Only when health metrics are received then return
 
 	time.Sleep(time.Second * 3)
@@ -116,134 +67,134 @@ func (lc *Launch) Execute(asg *AutoScalingGroup) error {
 }
 
 func (lc *Terminate) Execute(asg *AutoScalingGroup) error {
+	driver, err := NewDriver(lc.Provider)
+	if err != nil {
+		return err
+	}
 
-	tokenSource := &TokenSource{
-		AccessToken: lc.Provider.APIKey,
+	node := asg.Nodes.GetByID(lc.NodeID)
+	if node == nil {
+		return lc.terminate(asg, driver, nil)
 	}
 
-	oauthClient := oauth2.NewClient(oauth2.NoContext, tokenSource)
-	client := godo.NewClient(oauthClient)
+	return asg.runLifecycleHooks(node, LifecycleTerminating, func(LifecycleResult) error {
+		return lc.terminate(asg, driver, node)
+	})
+}
 
-	nid, err := strconv.Atoi(string(lc.NodeID))
-	if err != nil {
-		fmt.Printf("Could not convert to int [%s]: %s\n\n", lc.NodeID, err)
-		return err
+// terminate node once terminating hooks are completed
+func (lc *Terminate) terminate(asg *AutoScalingGroup, driver Driver, node *Node) error {
+	// Keep node for later if warm pool allows it
+	if node != nil && asg.WarmPool != nil && asg.WarmPool.ReuseOnScaleIn {
+		err := driver.PowerOff(lc.NodeID)
+		if err == nil && asg.WarmPool.Put(node) {
+			asg.RemoveNode(lc.NodeID)
+			asg.logf("Execute Terminate [%s], node [%s] returned to warm pool \n", asg.ID, lc.NodeID)
+			return nil
+		}
 	}
 
 	// Remove bad one
-	_, err = client.Droplets.Delete(nid)
+	err := driver.Delete(lc.NodeID)
 	asg.RemoveNode(lc.NodeID)
 
 	if err != nil {
-		fmt.Printf("Could not delete node [%s]: %s\n\n", lc.NodeID, err)
 		return err
 	}
 
-	fmt.Printf("Execute Terminate [%s] \n", asg.ID)
+	asg.logf("Execute Terminate [%s] \n", asg.ID)
 
 	return nil
 }
 
 func (lc *Relaunch) Execute(asg *AutoScalingGroup) error {
-
-	dropletName := "auto-" + strconv.Itoa(time.Now().Nanosecond())
-	tokenSource := &TokenSource{
-		AccessToken: lc.Provider.APIKey,
+	driver, err := NewDriver(asg.launchProvider(lc.Provider))
+	if err != nil {
+		return err
 	}
 
-	oauthClient := oauth2.NewClient(oauth2.NoContext, tokenSource)
-	client := godo.NewClient(oauthClient)
-
-	// Launch new
-
-	createRequest := &godo.DropletCreateRequest{
-		Name:   dropletName,
-		Region: lc.Provider.Region,
-		Size:   lc.Provider.Size,
-		Image: godo.DropletCreateImage{
-			Slug: lc.Provider.Image,
-		},
-		PrivateNetworking: true,
-		SSHKeys: []godo.DropletCreateSSHKey{
-			godo.DropletCreateSSHKey{
-				Fingerprint: lc.Provider.SSHKey,
-			},
-		},
+	// Bad one is kept while launches are suspended
+	if !asg.allowLaunch() {
+		asg.logf("Relaunch of node [%s] is suspended by circuit breaker \n", lc.NodeID)
+		return nil
 	}
 
-	newDroplet, _, err := client.Droplets.Create(createRequest)
-
+	// Launch new
+	start := time.Now()
+	node, err := launchNode(asg, driver, lc.Provider)
 	if err != nil {
-		fmt.Printf("Could not launch droplet : %s\n\n", err)
+		asg.launchFailed("", err.Error())
 		return err
 	}
+	asg.launchStarted(node.ID)
 
-	status := newDroplet.Status
-	for {
-		fmt.Printf("Droplet [%d] status [%s] : \n\n", newDroplet.ID, status)
-		if status == "active" {
-			break
-		}
+	asg.logf("Setting up new Node for [%s] \n", asg.ID)
+	// Add new node, bad one is kept until new one is ready
+	asg.AddNode(node)
+	asg.surging[node.ID] = true
+	if asg.Nodes.GetByID(lc.NodeID) != nil {
+		asg.replacing[lc.NodeID] = node.ID
+	}
 
-		d, _, err := client.Droplets.Get(newDroplet.ID)
-		if err != nil {
-			fmt.Printf("Could not get status for droplet : %s\n\n", err)
-			return err
+	return asg.runLifecycleHooks(node, LifecycleLaunching, func(result LifecycleResult) error {
+		// Bad one is kept if new one did not make it
+		if result != LifecycleContinue {
+			delete(asg.replacing, lc.NodeID)
+			return abandonLaunch(asg, driver, node)
 		}
+		asg.telemetry.launched(time.Since(start))
 
-		status = d.Status
-		// timeout needed
-		time.Sleep(time.Second * 5)
-	}
+		// Only when health metrics are received then return
 
-	publicIP, err := newDroplet.PublicIPv4()
-	if err != nil {
-		fmt.Printf("Could not get public IP : %s\n\n", err)
-		return err
-	}
+		time.Sleep(time.Second * 3)
 
-	privateIP, err := newDroplet.PrivateIPv4()
-	if err != nil {
-		fmt.Printf("Could not get private IP : %s\n\n", err)
-		return err
-	}
+		old := asg.Nodes.GetByID(lc.NodeID)
+		if old == nil {
+			return nil
+		}
 
-	fmt.Printf("Setting up new Node for [%s] \n", asg.ID)
-	// API call
-	node := NewNode()
-	node.Setup(
-		ID(strconv.Itoa(newDroplet.ID)),
-		lc.Provider,
-		NetworkInterface{
-			IP: net.ParseIP(privateIP),
-		},
-		NetworkInterface{
-			IP: net.ParseIP(publicIP),
-		},
-	)
+		return asg.runLifecycleHooks(old, LifecycleTerminating, func(LifecycleResult) error {
+			// Remove bad one
+			err := driver.Delete(lc.NodeID)
+			asg.RemoveNode(lc.NodeID)
+			if err != nil {
+				return err
+			}
 
-	// Add new node
-	asg.AddNode(node)
+			asg.logf("Execute Relaunch [%s] \n", asg.ID)
 
-	// Only when health metrics are received then return
-
-	time.Sleep(time.Second * 3)
+			return nil
+		})
+	})
+}
 
-	nid, err := strconv.Atoi(string(lc.NodeID))
-	if err != nil {
-		fmt.Printf("Could not convert to int [%s]: %s\n\n", lc.NodeID, err)
-		return err
+// launchNode powers on warm node if there is one prepared with the same
+// provider, otherwise new node is created
+func launchNode(asg *AutoScalingGroup, driver Driver, provider Provider) (*Node, error) {
+	if asg.WarmPool != nil {
+		if node := asg.WarmPool.Take(asg.launchProvider(provider)); node != nil {
+			err := driver.PowerOn(node.ID)
+			if err == nil {
+				// Start from clean state, as any freshly created node
+				node.Setup(node.ID, node.Provider, node.PrivateIface, node.PublicIface)
+				return node, nil
+			}
+
+			asg.logf("Could not power on warm node [%s]: %s\n\n", node.ID, err)
+			driver.Delete(node.ID)
+		}
 	}
 
-	// Remove bad one
-	_, err = client.Droplets.Delete(nid)
-	asg.RemoveNode(lc.NodeID)
-	if err != nil {
-		fmt.Printf("Could not delete node [%s]: %s\n\n", lc.NodeID, err)
-		return err
-	}
+	return driver.Create()
+}
 
-	fmt.Printf("Execute Relaunch [%s] \n", asg.ID)
+// abandonLaunch removes node which launch hook has abandoned
+func abandonLaunch(asg *AutoScalingGroup, driver Driver, node *Node) error {
+	driver.Delete(node.ID)
+	asg.RemoveNode(node.ID)
 
-	return nil
+	err := errors.Errorf("Launch of node [%s] was abandoned by lifecycle hook", node.ID)
+	asg.launchFailed(node.ID, err.Error())
+
+	return err
 }
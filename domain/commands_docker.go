@@ -1,7 +1,6 @@
 package domain
 
 import (
-	"fmt"
 	"net"
 )
 
@@ -27,7 +26,7 @@ func (lc *LaunchLocal) Execute(asg *AutoScalingGroup) error {
 	publicIP := "1.1.1.2"
 	privateIP := "1.1.1.1"
 
-	fmt.Printf("Setting up new Node for [%s] \n", asg.ID)
+	asg.logf("Setting up new Node for [%s] \n", asg.ID)
 	// API call
 	node := NewNode()
 	node.Setup(
@@ -51,7 +50,7 @@ func (lc *TerminateLocal) Execute(asg *AutoScalingGroup) error {
 
 	asg.RemoveNode("drople-1")
 
-	fmt.Printf("Execute Terminate [%s] \n", asg.ID)
+	asg.logf("Execute Terminate [%s] \n", asg.ID)
 
 	return nil
 }
@@ -61,7 +60,7 @@ func (lc *RelaunchLocal) Execute(asg *AutoScalingGroup) error {
 	publicIP := "1.1.1.2"
 	privateIP := "1.1.1.1"
 
-	fmt.Printf("Setting up new Node for [%s] \n", asg.ID)
+	asg.logf("Setting up new Node for [%s] \n", asg.ID)
 	// API call
 	node := NewNode()
 	node.Setup(
@@ -80,7 +79,7 @@ func (lc *RelaunchLocal) Execute(asg *AutoScalingGroup) error {
 
 	asg.RemoveNode("droplet-1")
 
-	fmt.Printf("Execute Relaunch [%s] \n", asg.ID)
+	asg.logf("Execute Relaunch [%s] \n", asg.ID)
 
 	return nil
 }
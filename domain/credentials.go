@@ -0,0 +1,322 @@
+package domain
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+const (
+	// UserDataASGID and UserDataBootstrapToken are replaced in user data of
+	// launched nodes, so node can exchange bootstrap token for its own one
+	UserDataASGID          = "${ARTEMIS_ASG_ID}"
+	UserDataBootstrapToken = "${ARTEMIS_BOOTSTRAP_TOKEN}"
+
+	defaultTokenTTL      = 24 * time.Hour
+	defaultRotationGrace = 5 * time.Minute
+	tokenBytes           = 32
+)
+
+var (
+	// ErrInvalidToken is returned when token does not belong to the node
+	ErrInvalidToken = errors.New("Token is not valid for the node")
+
+	// defaultUserData is used if provider has none, it stores bootstrap
+	// settings where agent on node can read them
+	defaultUserData = "#!/bin/sh\nmkdir -p /etc/artemis\nprintf 'ASG_ID=%s\\nBOOTSTRAP_TOKEN=%s\\n' '" +
+		UserDataASGID + "' '" + UserDataBootstrapToken + "' > /etc/artemis/bootstrap\nchmod 600 /etc/artemis/bootstrap\n"
+)
+
+type (
+	// NodeCredentials are tokens nodes have to present when they report
+	// metrics, so node can only report for itself. Node gets its token by
+	// exchanging bootstrap token of ASG, which is delivered in user data.
+	// Metrics of ASG itself require group token which no node has
+	NodeCredentials struct {
+		// TTL of node and group token, node has to rotate it before it
+		// expires. Bootstrap token is rotated every TTL as well
+		TTL time.Duration
+		// RotationGrace is time previous token is still accepted after rotation
+		RotationGrace time.Duration
+
+		lock      sync.Mutex
+		bootstrap *bootstrapToken
+		group     *nodeToken
+		tokens    map[ID]*nodeToken
+	}
+
+	// bootstrapToken is kept as it is, it has to be put into user data
+	bootstrapToken struct {
+		token    string
+		issuedAt time.Time
+		// previous token is valid until previousUntil, so nodes launched
+		// right before rotation can still exchange it
+		previous      string
+		previousUntil time.Time
+	}
+
+	// NodeToken is token issued for node, Token itself is only known at issue
+	NodeToken struct {
+		Node      ID
+		Token     string
+		IssuedAt  time.Time
+		ExpiresAt time.Time
+	}
+
+	nodeToken struct {
+		hash      []byte
+		expiresAt time.Time
+		// previous token is valid until previousUntil
+		previous      []byte
+		previousUntil time.Time
+	}
+)
+
+// NewNodeCredentials constructor, ttl defaults to 24 hours and rotation grace
+// to 5 minutes
+func NewNodeCredentials(ttl, rotationGrace time.Duration) (*NodeCredentials, error) {
+	if ttl == 0 {
+		ttl = defaultTokenTTL
+	}
+
+	if rotationGrace == 0 {
+		rotationGrace = defaultRotationGrace
+	}
+
+	if ttl < 0 || rotationGrace < 0 {
+		return nil, errors.Errorf("TTL %s and RotationGrace %s can not be negative", ttl, rotationGrace)
+	}
+
+	if rotationGrace >= ttl {
+		return nil, errors.Errorf("RotationGrace %s has to be less than TTL %s", rotationGrace, ttl)
+	}
+
+	bootstrap, err := newToken()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return &NodeCredentials{
+		TTL:           ttl,
+		RotationGrace: rotationGrace,
+		bootstrap:     &bootstrapToken{token: bootstrap, issuedAt: time.Now()},
+		tokens:        map[ID]*nodeToken{},
+	}, nil
+}
+
+// BootstrapToken of ASG, it only allows to exchange it for node token
+func (nc *NodeCredentials) BootstrapToken() string {
+	nc.lock.Lock()
+	defer nc.lock.Unlock()
+
+	return nc.bootstrap.token
+}
+
+// RotateBootstrap issues new bootstrap token, nodes launched afterwards get
+// it in their user data. Previous one can be exchanged for RotationGrace
+func (nc *NodeCredentials) RotateBootstrap(now time.Time) (string, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+
+	nc.lock.Lock()
+	defer nc.lock.Unlock()
+
+	nc.bootstrap = &bootstrapToken{
+		token:         token,
+		issuedAt:      now,
+		previous:      nc.bootstrap.token,
+		previousUntil: now.Add(nc.RotationGrace),
+	}
+
+	return token, nil
+}
+
+// RefreshBootstrap rotates bootstrap token once it is older than TTL
+func (nc *NodeCredentials) RefreshBootstrap(now time.Time) error {
+	nc.lock.Lock()
+	due := now.Sub(nc.bootstrap.issuedAt) >= nc.TTL
+	nc.lock.Unlock()
+
+	if !due {
+		return nil
+	}
+
+	_, err := nc.RotateBootstrap(now)
+	return err
+}
+
+// UserData returns user data of node with bootstrap settings filled in,
+// default script which stores them in /etc/artemis/bootstrap is used if
+// there is none
+func (nc *NodeCredentials) UserData(asg ID, userData string) string {
+	if userData == "" {
+		userData = defaultUserData
+	}
+
+	return strings.NewReplacer(UserDataASGID, string(asg), UserDataBootstrapToken, nc.BootstrapToken()).Replace(userData)
+}
+
+// Issue new token for node, previous one is revoked right away
+func (nc *NodeCredentials) Issue(node ID, now time.Time) (NodeToken, error) {
+	token, err := newToken()
+	if err != nil {
+		return NodeToken{}, errors.Trace(err)
+	}
+
+	nc.lock.Lock()
+	defer nc.lock.Unlock()
+
+	nc.tokens[node] = &nodeToken{
+		hash:      hashToken(token),
+		expiresAt: now.Add(nc.TTL),
+	}
+
+	return NodeToken{Node: node, Token: token, IssuedAt: now, ExpiresAt: now.Add(nc.TTL)}, nil
+}
+
+// Exchange bootstrap token for token of given node. Request has to come
+// from one of IPs of the node, so bootstrap token alone is not enough to
+// get token of another node
+func (nc *NodeCredentials) Exchange(node *Node, bootstrap string, remote net.IP, now time.Time) (NodeToken, error) {
+	if !nc.validBootstrap(bootstrap, now) {
+		return NodeToken{}, errors.Errorf("Bootstrap token is not valid")
+	}
+
+	if remote == nil || !(remote.Equal(node.PrivateIface.IP) || remote.Equal(node.PublicIface.IP)) {
+		return NodeToken{}, errors.Errorf("Token of node [%s] can only be requested from its own IP, not %s", node.ID, remote)
+	}
+
+	return nc.Issue(node.ID, now)
+}
+
+func (nc *NodeCredentials) validBootstrap(token string, now time.Time) bool {
+	nc.lock.Lock()
+	defer nc.lock.Unlock()
+
+	b := nc.bootstrap
+	if subtle.ConstantTimeCompare([]byte(token), []byte(b.token)) == 1 {
+		return true
+	}
+
+	return b.previous != "" && subtle.ConstantTimeCompare([]byte(token), []byte(b.previous)) == 1 && now.Before(b.previousUntil)
+}
+
+// Rotate token of node, current token has to be valid. It is still accepted
+// for RotationGrace, so reports which are on their way are not rejected
+func (nc *NodeCredentials) Rotate(node ID, current string, now time.Time) (NodeToken, error) {
+	if err := nc.Verify(node, current, now); err != nil {
+		return NodeToken{}, err
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return NodeToken{}, errors.Trace(err)
+	}
+
+	nc.lock.Lock()
+	defer nc.lock.Unlock()
+
+	nc.tokens[node] = &nodeToken{
+		hash:          hashToken(token),
+		expiresAt:     now.Add(nc.TTL),
+		previous:      hashToken(current),
+		previousUntil: now.Add(nc.RotationGrace),
+	}
+
+	return NodeToken{Node: node, Token: token, IssuedAt: now, ExpiresAt: now.Add(nc.TTL)}, nil
+}
+
+// Verify token presented for node
+func (nc *NodeCredentials) Verify(node ID, token string, now time.Time) error {
+	nc.lock.Lock()
+	defer nc.lock.Unlock()
+
+	t, ok := nc.tokens[node]
+	if !ok || !t.valid(token, now) {
+		return ErrInvalidToken
+	}
+
+	return nil
+}
+
+// IssueGroupToken issues token for metrics of ASG itself, e.g. for exporter
+// of queue backlog. Tokens of nodes are not accepted for them, so node can not
+// report for the whole ASG. Previous group token is accepted for RotationGrace
+func (nc *NodeCredentials) IssueGroupToken(now time.Time) (NodeToken, error) {
+	token, err := newToken()
+	if err != nil {
+		return NodeToken{}, errors.Trace(err)
+	}
+
+	nc.lock.Lock()
+	defer nc.lock.Unlock()
+
+	group := &nodeToken{
+		hash:      hashToken(token),
+		expiresAt: now.Add(nc.TTL),
+	}
+	if nc.group != nil {
+		group.previous = nc.group.hash
+		group.previousUntil = now.Add(nc.RotationGrace)
+	}
+	nc.group = group
+
+	return NodeToken{Token: token, IssuedAt: now, ExpiresAt: now.Add(nc.TTL)}, nil
+}
+
+// VerifyGroup token presented for metrics of ASG itself
+func (nc *NodeCredentials) VerifyGroup(token string, now time.Time) error {
+	nc.lock.Lock()
+	defer nc.lock.Unlock()
+
+	if nc.group == nil || !nc.group.valid(token, now) {
+		return ErrInvalidToken
+	}
+
+	return nil
+}
+
+// Revoke token of node, e.g. when it is terminated
+func (nc *NodeCredentials) Revoke(node ID) {
+	nc.lock.Lock()
+	delete(nc.tokens, node)
+	nc.lock.Unlock()
+}
+
+// valid returns true if token is current one or previous one within grace
+func (t *nodeToken) valid(token string, now time.Time) bool {
+	if token == "" {
+		return false
+	}
+
+	hash := hashToken(token)
+	if subtle.ConstantTimeCompare(hash, t.hash) == 1 && now.Before(t.expiresAt) {
+		return true
+	}
+
+	return t.previous != nil && subtle.ConstantTimeCompare(hash, t.previous) == 1 && now.Before(t.previousUntil)
+}
+
+func newToken() (string, error) {
+	b := make([]byte, tokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Trace(err)
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// hashToken so tokens themselves are not kept in memory
+func hashToken(token string) []byte {
+	h := sha256.Sum256([]byte(token))
+	return h[:]
+}
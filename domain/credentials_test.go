@@ -0,0 +1,183 @@
+package domain
+
+import (
+	"net"
+	"strings"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type CredentialsSuite struct{}
+
+var _ = Suite(&CredentialsSuite{})
+
+func (s *CredentialsSuite) TestIfNodeCredentialsAreValidated(c *C) {
+	nc, err := NewNodeCredentials(0, 0)
+	c.Assert(err, IsNil)
+	c.Assert(nc.TTL, Equals, defaultTokenTTL)
+	c.Assert(nc.RotationGrace, Equals, defaultRotationGrace)
+	c.Assert(len(nc.BootstrapToken()), Equals, 2*tokenBytes)
+
+	_, err = NewNodeCredentials(time.Minute, time.Hour)
+	c.Assert(err, NotNil)
+
+	_, err = NewNodeCredentials(-time.Minute, 0)
+	c.Assert(err, NotNil)
+}
+
+func (s *CredentialsSuite) TestIfTokenIsOnlyValidForItsNode(c *C) {
+	nc, err := NewNodeCredentials(time.Hour, time.Minute)
+	c.Assert(err, IsNil)
+
+	now := time.Now()
+	token, err := nc.Issue(ID("node1"), now)
+	c.Assert(err, IsNil)
+	c.Assert(token.ExpiresAt, Equals, now.Add(time.Hour))
+
+	c.Assert(nc.Verify(ID("node1"), token.Token, now), IsNil)
+	c.Assert(nc.Verify(ID("node2"), token.Token, now), Equals, ErrInvalidToken)
+	c.Assert(nc.Verify(ID("node1"), "", now), Equals, ErrInvalidToken)
+	c.Assert(nc.Verify(ID("node1"), token.Token, now.Add(time.Hour)), Equals, ErrInvalidToken)
+
+	// Token of node is not valid for metrics of ASG itself
+	c.Assert(nc.VerifyGroup(token.Token, now), Equals, ErrInvalidToken)
+}
+
+func (s *CredentialsSuite) TestIfGroupTokenIsOnlyValidForASG(c *C) {
+	nc, err := NewNodeCredentials(time.Hour, time.Minute)
+	c.Assert(err, IsNil)
+
+	now := time.Now()
+	c.Assert(nc.VerifyGroup("", now), Equals, ErrInvalidToken)
+
+	group, err := nc.IssueGroupToken(now)
+	c.Assert(err, IsNil)
+	c.Assert(nc.VerifyGroup(group.Token, now), IsNil)
+	c.Assert(nc.VerifyGroup(group.Token, now.Add(time.Hour)), Equals, ErrInvalidToken)
+	c.Assert(nc.Verify(ID(""), group.Token, now), Equals, ErrInvalidToken)
+
+	// Previous group token is accepted for grace period
+	rotatedAt := now.Add(30 * time.Minute)
+	again, err := nc.IssueGroupToken(rotatedAt)
+	c.Assert(err, IsNil)
+	c.Assert(nc.VerifyGroup(group.Token, rotatedAt.Add(30*time.Second)), IsNil)
+	c.Assert(nc.VerifyGroup(group.Token, rotatedAt.Add(time.Minute)), Equals, ErrInvalidToken)
+	c.Assert(nc.VerifyGroup(again.Token, rotatedAt.Add(time.Minute)), IsNil)
+}
+
+func (s *CredentialsSuite) TestIfBootstrapTokenIsRotatedEveryTTL(c *C) {
+	nc, err := NewNodeCredentials(time.Hour, time.Minute)
+	c.Assert(err, IsNil)
+
+	node := prepareLocalNode(ID("node1"))
+	ip := net.ParseIP("127.0.0.1")
+	old := nc.BootstrapToken()
+	now := time.Now()
+
+	c.Assert(nc.RefreshBootstrap(now.Add(59*time.Minute)), IsNil)
+	c.Assert(nc.BootstrapToken(), Equals, old)
+
+	rotatedAt := now.Add(time.Hour)
+	c.Assert(nc.RefreshBootstrap(rotatedAt), IsNil)
+	c.Assert(nc.BootstrapToken(), Not(Equals), old)
+	c.Assert(strings.Contains(nc.UserData(ID("asg-1"), ""), nc.BootstrapToken()), Equals, true)
+
+	// Node launched right before rotation can still exchange previous one
+	_, err = nc.Exchange(node, old, ip, rotatedAt.Add(30*time.Second))
+	c.Assert(err, IsNil)
+	_, err = nc.Exchange(node, old, ip, rotatedAt.Add(time.Minute))
+	c.Assert(err, NotNil)
+	_, err = nc.Exchange(node, nc.BootstrapToken(), ip, rotatedAt.Add(time.Minute))
+	c.Assert(err, IsNil)
+
+	// Rotated on demand as well, e.g. when it has leaked
+	leaked := nc.BootstrapToken()
+	token, err := nc.RotateBootstrap(rotatedAt)
+	c.Assert(err, IsNil)
+	c.Assert(token, Equals, nc.BootstrapToken())
+	c.Assert(token, Not(Equals), leaked)
+}
+
+func (s *CredentialsSuite) TestIfBootstrapTokenIsOnlyExchangedFromIPOfNode(c *C) {
+	nc, err := NewNodeCredentials(0, 0)
+	c.Assert(err, IsNil)
+
+	node := prepareLocalNode(ID("node1"))
+	now := time.Now()
+
+	_, err = nc.Exchange(node, "wrong", net.ParseIP("127.0.0.1"), now)
+	c.Assert(err, NotNil)
+
+	_, err = nc.Exchange(node, nc.BootstrapToken(), net.ParseIP("10.1.1.1"), now)
+	c.Assert(err, NotNil)
+
+	_, err = nc.Exchange(node, nc.BootstrapToken(), nil, now)
+	c.Assert(err, NotNil)
+
+	token, err := nc.Exchange(node, nc.BootstrapToken(), net.ParseIP("10.255.255.1"), now)
+	c.Assert(err, IsNil)
+	c.Assert(nc.Verify(node.ID, token.Token, now), IsNil)
+
+	// Exchanging again revokes previous token right away
+	again, err := nc.Exchange(node, nc.BootstrapToken(), net.ParseIP("127.0.0.1"), now)
+	c.Assert(err, IsNil)
+	c.Assert(nc.Verify(node.ID, token.Token, now), Equals, ErrInvalidToken)
+	c.Assert(nc.Verify(node.ID, again.Token, now), IsNil)
+}
+
+func (s *CredentialsSuite) TestIfRotatedTokenIsAcceptedForGracePeriod(c *C) {
+	nc, err := NewNodeCredentials(time.Hour, time.Minute)
+	c.Assert(err, IsNil)
+
+	now := time.Now()
+	old, err := nc.Issue(ID("node1"), now)
+	c.Assert(err, IsNil)
+
+	_, err = nc.Rotate(ID("node1"), "wrong", now)
+	c.Assert(err, Equals, ErrInvalidToken)
+
+	rotatedAt := now.Add(30 * time.Minute)
+	token, err := nc.Rotate(ID("node1"), old.Token, rotatedAt)
+	c.Assert(err, IsNil)
+	c.Assert(token.ExpiresAt, Equals, rotatedAt.Add(time.Hour))
+
+	c.Assert(nc.Verify(ID("node1"), old.Token, rotatedAt.Add(30*time.Second)), IsNil)
+	c.Assert(nc.Verify(ID("node1"), old.Token, rotatedAt.Add(time.Minute)), Equals, ErrInvalidToken)
+	c.Assert(nc.Verify(ID("node1"), token.Token, rotatedAt.Add(59*time.Minute)), IsNil)
+}
+
+func (s *CredentialsSuite) TestIfTokenIsRevokedWhenNodeIsRemoved(c *C) {
+	nc, err := NewNodeCredentials(0, 0)
+	c.Assert(err, IsNil)
+
+	asg := NewAutoScalingGroup(ID("asg-1"))
+	asg.Setup(NewNodeSet(prepareLocalNode(ID("node1"))), NewPolicySet())
+	asg.Credentials = nc
+
+	now := time.Now()
+	token, err := nc.Issue(ID("node1"), now)
+	c.Assert(err, IsNil)
+
+	c.Assert(asg.RemoveNode(ID("node1")), IsNil)
+	c.Assert(nc.Verify(ID("node1"), token.Token, now), Equals, ErrInvalidToken)
+}
+
+func (s *CredentialsSuite) TestIfBootstrapSettingsAreFilledInUserData(c *C) {
+	asg := NewAutoScalingGroup(ID("asg-1"))
+	asg.Setup(NewNodeSet(), NewPolicySet())
+
+	provider := Provider{ID: testProviderID, UserData: "id=" + UserDataASGID + " token=" + UserDataBootstrapToken}
+	c.Assert(asg.launchProvider(provider).UserData, Equals, provider.UserData)
+
+	nc, err := NewNodeCredentials(0, 0)
+	c.Assert(err, IsNil)
+	asg.Credentials = nc
+
+	c.Assert(asg.launchProvider(provider).UserData, Equals, "id=asg-1 token="+nc.BootstrapToken())
+
+	userData := asg.launchProvider(Provider{ID: testProviderID}).UserData
+	c.Assert(strings.HasPrefix(userData, "#!/bin/sh"), Equals, true)
+	c.Assert(strings.Contains(userData, nc.BootstrapToken()), Equals, true)
+	c.Assert(strings.Contains(userData, UserDataASGID), Equals, false)
+}
@@ -0,0 +1,45 @@
+package domain
+
+import "github.com/juju/errors"
+
+type (
+	// Driver talks to provider API on behalf of commands
+	Driver interface {
+		// Create new node and wait until it is active
+		Create() (*Node, error)
+		Delete(ID) error
+		PowerOn(ID) error
+		PowerOff(ID) error
+		Reboot(ID) error
+		PowerCycle(ID) error
+		// Rebuild node from provider image
+		Rebuild(ID) error
+		// Statuses returns provider status of every node it knows about
+		Statuses() (map[ID]string, error)
+	}
+
+	// DriverFactory creates driver for given provider settings
+	DriverFactory func(Provider) Driver
+)
+
+var drivers = map[string]DriverFactory{
+	DigitalOcean: NewDigitalOceanDriver,
+}
+
+// RegisterDriver makes driver available for nodes of given provider
+func RegisterDriver(providerID string, factory DriverFactory) {
+	drivers[providerID] = factory
+}
+
+// NewDriver returns driver for given provider, its API calls are measured
+func NewDriver(provider Provider) (Driver, error) {
+	factory, ok := drivers[provider.ID]
+	if !ok {
+		return nil, errors.Errorf("Driver for provider [%s] is not registered", provider.ID)
+	}
+
+	return &instrumentedDriver{
+		driver:   factory(provider),
+		provider: provider.ID,
+	}, nil
+}
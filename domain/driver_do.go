@@ -0,0 +1,258 @@
+package domain
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/digitalocean/godo"
+	"github.com/juju/errors"
+	"golang.org/x/oauth2"
+)
+
+type (
+	// DigitalOceanDriver manages droplets
+	DigitalOceanDriver struct {
+		provider Provider
+		client   *godo.Client
+	}
+)
+
+// NewDigitalOceanDriver constructor
+func NewDigitalOceanDriver(provider Provider) Driver {
+	tokenSource := &TokenSource{
+		AccessToken: provider.APIKey,
+	}
+
+	oauthClient := oauth2.NewClient(oauth2.NoContext, tokenSource)
+
+	return &DigitalOceanDriver{
+		provider: provider,
+		client:   godo.NewClient(oauthClient),
+	}
+}
+
+// Create droplet and wait until it is active
+func (d *DigitalOceanDriver) Create() (*Node, error) {
+	dropletName := "auto-" + strconv.Itoa(time.Now().Nanosecond())
+
+	createRequest := &godo.DropletCreateRequest{
+		Name:   dropletName,
+		Region: d.provider.Region,
+		Size:   d.provider.Size,
+		Image: godo.DropletCreateImage{
+			Slug: d.provider.Image,
+		},
+		PrivateNetworking: true,
+		UserData:          d.provider.UserData,
+		SSHKeys: []godo.DropletCreateSSHKey{
+			godo.DropletCreateSSHKey{
+				Fingerprint: d.provider.SSHKey,
+			},
+		},
+	}
+
+	newDroplet, _, err := d.client.Droplets.Create(createRequest)
+	if err != nil {
+		fmt.Printf("Could not launch droplet : %s\n\n", err)
+		return nil, err
+	}
+
+	status := newDroplet.Status
+	for {
+		fmt.Printf("Droplet [%d] status [%s] : \n\n", newDroplet.ID, status)
+		if status == "active" {
+			break
+		}
+
+		dr, _, err := d.client.Droplets.Get(newDroplet.ID)
+		if err != nil {
+			fmt.Printf("Could not get status for droplet : %s\n\n", err)
+			return nil, err
+		}
+
+		newDroplet = dr
+		status = dr.Status
+		// timeout needed
+		time.Sleep(time.Second * 5)
+	}
+
+	publicIP, err := newDroplet.PublicIPv4()
+	if err != nil {
+		fmt.Printf("Could not get public IP : %s\n\n", err)
+		return nil, err
+	}
+
+	privateIP, err := newDroplet.PrivateIPv4()
+	if err != nil {
+		fmt.Printf("Could not get private IP : %s\n\n", err)
+		return nil, err
+	}
+
+	node := NewNode()
+	node.Setup(
+		ID(strconv.Itoa(newDroplet.ID)),
+		d.provider,
+		NetworkInterface{
+			IP: net.ParseIP(privateIP),
+		},
+		NetworkInterface{
+			IP: net.ParseIP(publicIP),
+		},
+	)
+
+	return node, nil
+}
+
+// Delete droplet
+func (d *DigitalOceanDriver) Delete(id ID) error {
+	nid, err := d.dropletID(id)
+	if err != nil {
+		return err
+	}
+
+	_, err = d.client.Droplets.Delete(nid)
+	if err != nil {
+		fmt.Printf("Could not delete node [%s]: %s\n\n", id, err)
+		return err
+	}
+
+	return nil
+}
+
+// PowerOn droplet and wait until action is completed
+func (d *DigitalOceanDriver) PowerOn(id ID) error {
+	nid, err := d.dropletID(id)
+	if err != nil {
+		return err
+	}
+
+	action, _, err := d.client.DropletActions.PowerOn(nid)
+	if err != nil {
+		fmt.Printf("Could not power on node [%s]: %s\n\n", id, err)
+		return err
+	}
+
+	return d.waitForAction(nid, action)
+}
+
+// PowerOff droplet and wait until action is completed
+func (d *DigitalOceanDriver) PowerOff(id ID) error {
+	nid, err := d.dropletID(id)
+	if err != nil {
+		return err
+	}
+
+	action, _, err := d.client.DropletActions.PowerOff(nid)
+	if err != nil {
+		fmt.Printf("Could not power off node [%s]: %s\n\n", id, err)
+		return err
+	}
+
+	return d.waitForAction(nid, action)
+}
+
+// Reboot droplet and wait until action is completed
+func (d *DigitalOceanDriver) Reboot(id ID) error {
+	nid, err := d.dropletID(id)
+	if err != nil {
+		return err
+	}
+
+	action, _, err := d.client.DropletActions.Reboot(nid)
+	if err != nil {
+		fmt.Printf("Could not reboot node [%s]: %s\n\n", id, err)
+		return err
+	}
+
+	return d.waitForAction(nid, action)
+}
+
+// PowerCycle droplet and wait until action is completed
+func (d *DigitalOceanDriver) PowerCycle(id ID) error {
+	nid, err := d.dropletID(id)
+	if err != nil {
+		return err
+	}
+
+	action, _, err := d.client.DropletActions.PowerCycle(nid)
+	if err != nil {
+		fmt.Printf("Could not power cycle node [%s]: %s\n\n", id, err)
+		return err
+	}
+
+	return d.waitForAction(nid, action)
+}
+
+// Rebuild droplet from provider image and wait until action is completed
+func (d *DigitalOceanDriver) Rebuild(id ID) error {
+	nid, err := d.dropletID(id)
+	if err != nil {
+		return err
+	}
+
+	action, _, err := d.client.DropletActions.RebuildByImageSlug(nid, d.provider.Image)
+	if err != nil {
+		fmt.Printf("Could not rebuild node [%s]: %s\n\n", id, err)
+		return err
+	}
+
+	return d.waitForAction(nid, action)
+}
+
+// Statuses of all droplets of the account
+func (d *DigitalOceanDriver) Statuses() (map[ID]string, error) {
+	statuses := map[ID]string{}
+
+	opt := &godo.ListOptions{Page: 1, PerPage: 200}
+	for {
+		droplets, _, err := d.client.Droplets.List(opt)
+		if err != nil {
+			fmt.Printf("Could not list droplets : %s\n\n", err)
+			return nil, err
+		}
+
+		for _, dr := range droplets {
+			statuses[ID(strconv.Itoa(dr.ID))] = dr.Status
+		}
+
+		if len(droplets) < opt.PerPage {
+			return statuses, nil
+		}
+		opt.Page++
+	}
+}
+
+func (d *DigitalOceanDriver) waitForAction(dropletID int, action *godo.Action) error {
+	status := action.Status
+	for {
+		fmt.Printf("Droplet [%d] action [%s] status [%s] : \n\n", dropletID, action.Type, status)
+		switch status {
+		case "completed":
+			return nil
+		case "errored":
+			return errors.Errorf("Action [%s] on droplet [%d] has failed", action.Type, dropletID)
+		}
+
+		// timeout needed
+		time.Sleep(time.Second * 5)
+
+		a, _, err := d.client.DropletActions.Get(dropletID, action.ID)
+		if err != nil {
+			fmt.Printf("Could not get status for action : %s\n\n", err)
+			return err
+		}
+		status = a.Status
+	}
+}
+
+func (d *DigitalOceanDriver) dropletID(id ID) (int, error) {
+	nid, err := strconv.Atoi(string(id))
+	if err != nil {
+		fmt.Printf("Could not convert to int [%s]: %s\n\n", id, err)
+		return 0, err
+	}
+
+	return nid, nil
+}
@@ -0,0 +1,195 @@
+package domain
+
+import (
+	"sort"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+const (
+	HealingReboot     = HealingAction("reboot")
+	HealingPowerCycle = HealingAction("power-cycle")
+	HealingRebuild    = HealingAction("rebuild")
+	HealingRelaunch   = HealingAction("relaunch")
+)
+
+type (
+	HealingAction string
+
+	// HealingStep is performed on unhealthy node, after Wait node health is
+	// checked over Recheck window which starts not earlier than the action
+	HealingStep struct {
+		Action  HealingAction
+		Wait    time.Duration
+		Recheck time.Duration
+	}
+
+	// HealingLadder defines steps which are tried one after another until
+	// node is healthy again
+	HealingLadder struct {
+		Steps []HealingStep
+	}
+
+	// Heal command goes through ASG healing ladder for given node
+	Heal struct {
+		BaseCommand
+
+		NodeID           ID
+		HealthyThreshold float64
+	}
+
+	// healingProgress of node which waits for its healing step to take effect
+	healingProgress struct {
+		heal      *Heal
+		step      int
+		started   time.Time
+		recheckAt time.Time
+	}
+)
+
+// NewHealingLadder constructor, relaunch can only be the last step
+func NewHealingLadder(steps ...HealingStep) (*HealingLadder, error) {
+	if len(steps) == 0 {
+		return nil, errors.Errorf("At least one healing step is required")
+	}
+
+	for i, step := range steps {
+		switch step.Action {
+		case HealingReboot, HealingPowerCycle, HealingRebuild:
+			if step.Wait < 0 {
+				return nil, errors.Errorf("Wait %s of [%s] step can not be less than 0", step.Wait, step.Action)
+			}
+
+			if step.Recheck <= 0 {
+				return nil, errors.Errorf("Recheck %s of [%s] step has to be more than 0", step.Recheck, step.Action)
+			}
+		case HealingRelaunch:
+			if i != len(steps)-1 {
+				return nil, errors.Errorf("Step [%s] can only be the last one", step.Action)
+			}
+		default:
+			return nil, errors.Errorf("Healing action [%s] is not supported", step.Action)
+		}
+	}
+
+	return &HealingLadder{
+		Steps: steps,
+	}, nil
+}
+
+// Execute first healing step, node is rechecked on later cycles of ASG once
+// Wait of the step has passed and next step is tried if it is still unhealthy
+func (hc *Heal) Execute(asg *AutoScalingGroup) error {
+	if asg.Healing == nil {
+		return errors.Errorf("ASG [%s] has no healing ladder", asg.ID)
+	}
+
+	node := asg.Nodes.GetByID(hc.NodeID)
+	if node == nil {
+		return errors.Errorf("Node by ID %s was not found", hc.NodeID)
+	}
+
+	return hc.heal(asg, node, 0)
+}
+
+// heal performs steps from given one until one of them is performed, it is
+// then left to take effect. Relaunch step is skipped while its processes are suspended
+func (hc *Heal) heal(asg *AutoScalingGroup, node *Node, from int) error {
+	delete(asg.healingNodes, node.ID)
+
+	driver, err := NewDriver(hc.Provider)
+	if err != nil {
+		return err
+	}
+
+	for i := from; i < len(asg.Healing.Steps); i++ {
+		step := asg.Healing.Steps[i]
+		if step.Action == HealingRelaunch {
+			relaunch := &Relaunch{BaseCommand: hc.BaseCommand, NodeID: hc.NodeID}
+			if p, ok := asg.suspendedProcessOf(relaunch); ok {
+				node.RecordEvent("Healing: %s skipped, process %s is suspended", step.Action, p)
+				return nil
+			}
+
+			node.RecordEvent("Healing: %s", step.Action)
+			return relaunch.Execute(asg)
+		}
+
+		started := asg.now()
+		if err := hc.perform(driver, step.Action); err != nil {
+			node.RecordEvent("Healing: %s has failed : %s", step.Action, err)
+			continue
+		}
+
+		asg.healingNodes[node.ID] = &healingProgress{
+			heal:      hc,
+			step:      i,
+			started:   started,
+			recheckAt: started.Add(step.Wait),
+		}
+		return nil
+	}
+
+	return errors.Errorf("Node [%s] could not be healed", hc.NodeID)
+}
+
+// continueHealing rechecks nodes whose healing step has had its Wait, those
+// which are still unhealthy go on with next step
+func (asg *AutoScalingGroup) continueHealing() {
+	ids := []ID{}
+	for id := range asg.healingNodes {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	now := asg.now()
+	for _, id := range ids {
+		progress := asg.healingNodes[id]
+		if now.Before(progress.recheckAt) {
+			continue
+		}
+
+		if err := progress.recheck(asg, now); err != nil {
+			asg.logf("[%s] %s \n", asg.ID, err)
+		}
+	}
+}
+
+// recheck node health over Recheck window which starts not earlier than the step
+func (hp *healingProgress) recheck(asg *AutoScalingGroup, now time.Time) error {
+	node := asg.Nodes.GetByID(hp.heal.NodeID)
+	if node == nil || asg.Healing == nil || hp.step >= len(asg.Healing.Steps) {
+		delete(asg.healingNodes, hp.heal.NodeID)
+		return errors.Errorf("Healing of node [%s] is abandoned", hp.heal.NodeID)
+	}
+
+	step := asg.Healing.Steps[hp.step]
+	from := now.Add(step.Recheck * -1)
+	if from.Before(hp.started) {
+		from = hp.started
+	}
+
+	if asg.IsHealthy(node, hp.heal.HealthyThreshold, from, now) {
+		delete(asg.healingNodes, node.ID)
+		node.RecordEvent("Healing: restored by %s", step.Action)
+		node.ChangeState(NodeStateInService)
+		return nil
+	}
+
+	node.RecordEvent("Healing: %s did not restore health", step.Action)
+	return hp.heal.heal(asg, node, hp.step+1)
+}
+
+func (hc *Heal) perform(driver Driver, action HealingAction) error {
+	switch action {
+	case HealingReboot:
+		return driver.Reboot(hc.NodeID)
+	case HealingPowerCycle:
+		return driver.PowerCycle(hc.NodeID)
+	case HealingRebuild:
+		return driver.Rebuild(hc.NodeID)
+	}
+
+	return errors.Errorf("Healing action [%s] is not supported", action)
+}
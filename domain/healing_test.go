@@ -0,0 +1,172 @@
+package domain
+
+import (
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type HealingSuite struct{}
+
+var _ = Suite(&HealingSuite{})
+
+func prepareHealingAsg(steps ...HealingStep) (*AutoScalingGroup, *Node, error) {
+	node := prepareLocalNode(ID("node1"))
+	node.AddMetrics(prepareMetrics(5, 5))
+
+	asg := NewAutoScalingGroup(ID("asg-1"))
+	asg.Setup(NewNodeSet(node), NewPolicySet())
+
+	ladder, err := NewHealingLadder(steps...)
+	asg.Healing = ladder
+
+	return asg, node, err
+}
+
+func (s *HealingSuite) TestIfLadderStopsAtStepWhichRestoresNode(c *C) {
+	drv := registerTestDriver()
+	asg, node, err := prepareHealingAsg(
+		HealingStep{Action: HealingReboot, Wait: time.Second * 10, Recheck: time.Minute},
+		HealingStep{Action: HealingPowerCycle, Wait: time.Second * 10, Recheck: time.Minute},
+		HealingStep{Action: HealingRelaunch},
+	)
+	c.Assert(err, IsNil)
+	clock := NewVirtualClock(time.Now())
+	asg.Clock = clock
+
+	// Only power cycle brings node back
+	drv.onAction = func(action string, id ID) {
+		if action == "power-cycle" {
+			node.AddMetrics(NewMetricSeries(NewHealthMetric(1, clock.Now().Add(time.Second))))
+		}
+	}
+
+	cmd := &Heal{
+		BaseCommand:      BaseCommand{Provider: Provider{ID: testProviderID}},
+		NodeID:           ID("node1"),
+		HealthyThreshold: 0.7,
+	}
+	err = cmd.Execute(asg)
+	c.Assert(err, IsNil)
+	c.Assert(drv.actions, DeepEquals, []string{"reboot:node1"})
+
+	// Node is not rechecked before Wait has passed
+	asg.continueHealing()
+	c.Assert(len(node.History), Equals, 0)
+
+	clock.Advance(time.Second * 10)
+	asg.continueHealing()
+	c.Assert(drv.actions, DeepEquals, []string{"reboot:node1", "power-cycle:node1"})
+
+	clock.Advance(time.Second * 10)
+	asg.continueHealing()
+	c.Assert(len(drv.created), Equals, 0)
+	c.Assert(node.State, Equals, NodeStateInService)
+	c.Assert(asg.healingNodes, HasLen, 0)
+	c.Assert(len(node.History), Equals, 2)
+	c.Assert(node.History[0].Message, Equals, "Healing: reboot did not restore health")
+	c.Assert(node.History[1].Message, Equals, "Healing: restored by power-cycle")
+}
+
+func (s *HealingSuite) TestIfNodeIsRelaunchedWhenLadderIsExhausted(c *C) {
+	drv := registerTestDriver()
+	asg, node, err := prepareHealingAsg(
+		HealingStep{Action: HealingRebuild, Wait: time.Second * 10, Recheck: time.Minute},
+		HealingStep{Action: HealingRelaunch},
+	)
+	c.Assert(err, IsNil)
+	clock := NewVirtualClock(time.Now())
+	asg.Clock = clock
+
+	cmd := &Heal{
+		BaseCommand:      BaseCommand{Provider: Provider{ID: testProviderID}},
+		NodeID:           ID("node1"),
+		HealthyThreshold: 0.7,
+	}
+	err = cmd.Execute(asg)
+	c.Assert(err, IsNil)
+
+	clock.Advance(time.Second * 10)
+	asg.continueHealing()
+	c.Assert(drv.actions, DeepEquals, []string{"rebuild:node1"})
+	c.Assert(drv.deleted, DeepEquals, []ID{ID("node1")})
+	c.Assert(node.History[len(node.History)-1].Message, Equals, "Healing: relaunch")
+	c.Assert(asg.Nodes.GetByID(ID("node1")), IsNil)
+	c.Assert(len(asg.Nodes), Equals, 1)
+
+	// Without relaunch step node is left as it is
+	asg, node, err = prepareHealingAsg(HealingStep{Action: HealingReboot, Wait: 0, Recheck: time.Second})
+	c.Assert(err, IsNil)
+	c.Assert(cmd.Execute(asg), IsNil)
+	c.Assert(cmd.heal(asg, node, 1), ErrorMatches, "Node \\[node1\\] could not be healed")
+	c.Assert(asg.healingNodes, HasLen, 0)
+	c.Assert(asg.Nodes.GetByID(ID("node1")), NotNil)
+}
+
+func (s *HealingSuite) TestIfHealingDoesNotBlockASGAndRespectsSuspendedLaunch(c *C) {
+	drv := registerTestDriver()
+	asg, node, err := prepareHealingAsg(
+		HealingStep{Action: HealingReboot, Wait: time.Minute, Recheck: time.Minute},
+		HealingStep{Action: HealingRelaunch},
+	)
+	c.Assert(err, IsNil)
+	clock := NewVirtualClock(time.Now())
+	asg.Clock = clock
+
+	plc, err := NewDesiredNodeAmountPerProviderPolicy(ID("policy-1"), 1, 1, 1, 1, 0.7, time.Duration(-5*time.Second), Provider{ID: testProviderID})
+	c.Assert(err, IsNil)
+	asg.Policies = NewPolicySet(plc)
+
+	c.Assert(asg.Evaluate(), IsNil)
+	c.Assert(len(asg.Commands), Equals, 1)
+
+	started := time.Now()
+	c.Assert(asg.Execute(), IsNil)
+	c.Assert(time.Since(started) < time.Minute, Equals, true)
+	c.Assert(asg.inTransition(node), Equals, true)
+
+	// Node which is being healed counts toward capacity and is not judged
+	c.Assert(asg.Evaluate(), IsNil)
+	c.Assert(len(asg.Commands), Equals, 0)
+
+	c.Assert(asg.SuspendProcess(ProcessLaunch, ""), IsNil)
+	clock.Advance(time.Minute)
+	asg.continueHealing()
+	c.Assert(len(drv.created), Equals, 0)
+	c.Assert(asg.Nodes.GetByID(ID("node1")), NotNil)
+	c.Assert(asg.inTransition(node), Equals, false)
+	c.Assert(node.History[len(node.History)-1].Message, Equals, "Healing: relaunch skipped, process Launch is suspended")
+}
+
+func (s *HealingSuite) TestIfPolicyHealsInsteadOfRelaunchingWhenLadderIsSet(c *C) {
+	asg, _, err := prepareHealingAsg(HealingStep{Action: HealingReboot, Wait: time.Minute, Recheck: time.Minute})
+	c.Assert(err, IsNil)
+
+	plc, err := NewDesiredNodeAmountPerProviderPolicy(ID("policy-1"), 1, 1, 1, 1, 0.7, time.Duration(-5*time.Second), Provider{ID: testProviderID})
+	c.Assert(err, IsNil)
+
+	err = plc.Evaluate(asg)
+	c.Assert(err, IsNil)
+	c.Assert(asg.Commands[Order(1)], DeepEquals, &Heal{
+		BaseCommand: BaseCommand{
+			Provider: Provider{ID: testProviderID},
+			Reason:   "Node [node1] has failed 1 consecutive health checks",
+		},
+		NodeID:           ID("node1"),
+		HealthyThreshold: 0.7,
+	})
+}
+
+func (s *HealingSuite) TestIfHealingLadderIsValidated(c *C) {
+	_, err := NewHealingLadder()
+	c.Assert(err, NotNil)
+
+	_, err = NewHealingLadder(HealingStep{Action: HealingRelaunch}, HealingStep{Action: HealingReboot, Recheck: time.Second})
+	c.Assert(err, NotNil)
+
+	_, err = NewHealingLadder(HealingStep{Action: HealingAction("reinstall"), Recheck: time.Second})
+	c.Assert(err, NotNil)
+
+	_, err = NewHealingLadder(HealingStep{Action: HealingReboot})
+	c.Assert(err, NotNil)
+}
@@ -0,0 +1,206 @@
+package domain
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+const (
+	HealthCheckHTTP = HealthCheckType("http")
+	HealthCheckTCP  = HealthCheckType("tcp")
+	HealthCheckICMP = HealthCheckType("icmp")
+
+	PublicInterface  = "public"
+	PrivateInterface = "private"
+)
+
+type (
+	HealthCheckType string
+
+	// HealthCheck is run by artemis against every node of ASG, result is
+	// recorded as HealthMetric so the same threshold logic applies to it
+	HealthCheck struct {
+		Type HealthCheckType
+		// Interface is either PublicInterface or PrivateInterface
+		Interface string
+		Port      int
+		// HTTP only, ExpectedStatus defaults to 200, ExpectedBody is a substring
+		Path           string
+		ExpectedStatus int
+		ExpectedBody   string
+		Interval       time.Duration
+		Timeout        time.Duration
+
+		lastRun time.Time
+	}
+)
+
+// NewHealthCheck constructor
+func NewHealthCheck(typ HealthCheckType, iface string, port int, path string, expectedStatus int, expectedBody string, interval, timeout time.Duration) (*HealthCheck, error) {
+	switch typ {
+	case HealthCheckHTTP, HealthCheckTCP:
+		if port <= 0 || port > 65535 {
+			return nil, errors.Errorf("Port %d is not valid for %s health check", port, typ)
+		}
+	case HealthCheckICMP:
+	default:
+		return nil, errors.Errorf("Health check type [%s] is not supported", typ)
+	}
+
+	if iface == "" {
+		iface = PublicInterface
+	}
+
+	if iface != PublicInterface && iface != PrivateInterface {
+		return nil, errors.Errorf("Interface [%s] is not supported, use %s or %s", iface, PublicInterface, PrivateInterface)
+	}
+
+	if interval <= 0 {
+		return nil, errors.Errorf("Interval %s has to be more than 0", interval)
+	}
+
+	if timeout <= 0 || timeout > interval {
+		return nil, errors.Errorf("Timeout %s has to be more than 0 and not more than interval %s", timeout, interval)
+	}
+
+	if expectedStatus == 0 {
+		expectedStatus = http.StatusOK
+	}
+
+	if path == "" {
+		path = "/"
+	}
+
+	return &HealthCheck{
+		Type:           typ,
+		Interface:      iface,
+		Port:           port,
+		Path:           path,
+		ExpectedStatus: expectedStatus,
+		ExpectedBody:   expectedBody,
+		Interval:       interval,
+		Timeout:        timeout,
+	}, nil
+}
+
+// Due returns true if interval has passed since last run
+func (hc *HealthCheck) Due(now time.Time) bool {
+	return now.Sub(hc.lastRun) >= hc.Interval
+}
+
+// Run check against all given nodes in parallel and record results
+func (hc *HealthCheck) Run(nodes NodeSet, now time.Time) {
+	hc.lastRun = now
+
+	results := map[ID]float64{}
+	var lock sync.Mutex
+	var wait sync.WaitGroup
+
+	for id, node := range nodes {
+		wait.Add(1)
+		go func(id ID, node *Node) {
+			defer wait.Done()
+
+			value := 1.0
+			if err := hc.Check(node); err != nil {
+				fmt.Printf("Health check [%s] of node [%s] has failed : %s \n", hc.Type, id, err)
+				value = 0
+			}
+
+			lock.Lock()
+			results[id] = value
+			lock.Unlock()
+		}(id, node)
+	}
+	wait.Wait()
+
+	for id, value := range results {
+		nodes[id].AddMetrics(NewMetricSeries(NewHealthMetricFrom(value, now, ProbeHealthSource)))
+	}
+}
+
+// Check probes given node, nil is returned if node is healthy
+func (hc *HealthCheck) Check(node *Node) error {
+	ip := node.PublicIface.IP
+	if hc.Interface == PrivateInterface {
+		ip = node.PrivateIface.IP
+	}
+
+	if ip == nil {
+		return errors.Errorf("Node has no %s IP", hc.Interface)
+	}
+
+	address := net.JoinHostPort(ip.String(), strconv.Itoa(hc.Port))
+
+	switch hc.Type {
+	case HealthCheckHTTP:
+		return hc.checkHTTP(address)
+	case HealthCheckTCP:
+		return hc.checkTCP(address)
+	case HealthCheckICMP:
+		return hc.checkICMP(ip)
+	}
+
+	return errors.Errorf("Health check type [%s] is not supported", hc.Type)
+}
+
+func (hc *HealthCheck) checkHTTP(address string) error {
+	client := &http.Client{Timeout: hc.Timeout}
+	resp, err := client.Get("http://" + address + hc.Path)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != hc.ExpectedStatus {
+		return errors.Errorf("Expected status %d, got %d", hc.ExpectedStatus, resp.StatusCode)
+	}
+
+	if hc.ExpectedBody == "" {
+		return nil
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	if !strings.Contains(string(body), hc.ExpectedBody) {
+		return errors.Errorf("Expected body to contain [%s]", hc.ExpectedBody)
+	}
+
+	return nil
+}
+
+func (hc *HealthCheck) checkTCP(address string) error {
+	conn, err := net.DialTimeout("tcp", address, hc.Timeout)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	return conn.Close()
+}
+
+// checkICMP uses system ping, as raw sockets require elevated privileges
+func (hc *HealthCheck) checkICMP(ip net.IP) error {
+	timeout := int(hc.Timeout.Seconds())
+	if timeout < 1 {
+		timeout = 1
+	}
+
+	out, err := exec.Command("ping", "-c", "1", "-W", strconv.Itoa(timeout), ip.String()).CombinedOutput()
+	if err != nil {
+		return errors.Errorf("Ping has failed : %s %s", err, strings.TrimSpace(string(out)))
+	}
+
+	return nil
+}
@@ -0,0 +1,113 @@
+package domain
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type HealthCheckSuite struct{}
+
+var _ = Suite(&HealthCheckSuite{})
+
+// prepareLocalNode returns node which public interface points to localhost
+func prepareLocalNode(id ID) *Node {
+	node := NewNode()
+	node.Setup(
+		id,
+		Provider{ID: testProviderID},
+		NetworkInterface{IP: net.ParseIP("10.255.255.1")},
+		NetworkInterface{IP: net.ParseIP("127.0.0.1")},
+	)
+
+	return node
+}
+
+func serverPort(c *C, address string) int {
+	_, port, err := net.SplitHostPort(address)
+	c.Assert(err, IsNil)
+	p, err := strconv.Atoi(port)
+	c.Assert(err, IsNil)
+
+	return p
+}
+
+func (s *HealthCheckSuite) TestIfHTTPHealthCheckVerifiesStatusAndBody(c *C) {
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/status" {
+			rw.WriteHeader(http.StatusNotFound)
+			return
+		}
+		rw.Write([]byte(`{"status":"ok"}`))
+	}))
+	defer srv.Close()
+	port := serverPort(c, srv.Listener.Addr().String())
+	node := prepareLocalNode(ID("node1"))
+
+	hc, err := NewHealthCheck(HealthCheckHTTP, PublicInterface, port, "/status", 0, `"ok"`, time.Second, time.Second)
+	c.Assert(err, IsNil)
+	c.Assert(hc.Check(node), IsNil)
+
+	hc, err = NewHealthCheck(HealthCheckHTTP, PublicInterface, port, "/status", 0, "degraded", time.Second, time.Second)
+	c.Assert(err, IsNil)
+	c.Assert(hc.Check(node), NotNil)
+
+	hc, err = NewHealthCheck(HealthCheckHTTP, PublicInterface, port, "/", 0, "", time.Second, time.Second)
+	c.Assert(err, IsNil)
+	c.Assert(hc.Check(node), ErrorMatches, "Expected status 200, got 404")
+
+	hc, err = NewHealthCheck(HealthCheckHTTP, PublicInterface, port, "/", http.StatusNotFound, "", time.Second, time.Second)
+	c.Assert(err, IsNil)
+	c.Assert(hc.Check(node), IsNil)
+}
+
+func (s *HealthCheckSuite) TestIfHealthCheckResultsAreRecordedAsHealthMetrics(c *C) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, IsNil)
+	port := serverPort(c, ln.Addr().String())
+
+	healthy := prepareLocalNode(ID("node1"))
+	// Node without public IP can not be reached
+	unreachable := prepareLocalNode(ID("node2"))
+	unreachable.PublicIface = NetworkInterface{}
+
+	asg := NewAutoScalingGroup(ID("asg-1"))
+	asg.Setup(NewNodeSet(healthy, unreachable), NewPolicySet())
+
+	hc, err := NewHealthCheck(HealthCheckTCP, PublicInterface, port, "", 0, "", time.Minute, time.Second)
+	c.Assert(err, IsNil)
+	asg.HealthChecks = []*HealthCheck{hc}
+
+	asg.RunHealthChecks()
+	ln.Close()
+
+	from := time.Now().Add(-time.Minute)
+	c.Assert(healthy.CalculateMetricValue(HealthMetricType, from, time.Now()), Equals, 1.0)
+	c.Assert(unreachable.Metrics.Len(), Equals, 1)
+	c.Assert(unreachable.CalculateMetricValue(HealthMetricType, from, time.Now()), Equals, 0.0)
+
+	// Check is not due yet, nothing is recorded
+	asg.RunHealthChecks()
+	c.Assert(healthy.Metrics.Len(), Equals, 1)
+}
+
+func (s *HealthCheckSuite) TestIfHealthCheckIsValidated(c *C) {
+	_, err := NewHealthCheck(HealthCheckType("udp"), PublicInterface, 80, "", 0, "", time.Second, time.Second)
+	c.Assert(err, NotNil)
+
+	_, err = NewHealthCheck(HealthCheckTCP, PublicInterface, 0, "", 0, "", time.Second, time.Second)
+	c.Assert(err, NotNil)
+
+	_, err = NewHealthCheck(HealthCheckTCP, "eth1", 80, "", 0, "", time.Second, time.Second)
+	c.Assert(err, NotNil)
+
+	_, err = NewHealthCheck(HealthCheckTCP, PrivateInterface, 80, "", 0, "", time.Second, time.Minute)
+	c.Assert(err, NotNil)
+
+	_, err = NewHealthCheck(HealthCheckICMP, PrivateInterface, 0, "", 0, "", time.Second, time.Second)
+	c.Assert(err, IsNil)
+}
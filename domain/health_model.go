@@ -0,0 +1,195 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+const (
+	// PushHealthSource is health reported by node itself
+	PushHealthSource = HealthSource("push")
+	// ProbeHealthSource is result of artemis health checks
+	ProbeHealthSource = HealthSource("probe")
+	// ProviderHealthSource is status of node reported by provider
+	ProviderHealthSource = HealthSource("provider")
+
+	HealthModeAll    = HealthMode("all")
+	HealthModeAny    = HealthMode("any")
+	HealthModeQuorum = HealthMode("quorum")
+)
+
+type (
+	HealthSource string
+
+	HealthMode string
+
+	// HealthSignal is one of the sources node health is calculated from
+	HealthSignal struct {
+		Source HealthSource
+		Weight float64
+	}
+
+	// SignalStatus is last verdict of a signal for the node
+	SignalStatus struct {
+		Source     HealthSource
+		Value      float64
+		DataPoints int
+		Healthy    bool
+	}
+
+	// HealthModel combines several signals into single node health verdict.
+	// All or any of signals has to be healthy, or in quorum mode sum of weights
+	// of healthy signals has to reach Quorum part of total weight
+	HealthModel struct {
+		Mode    HealthMode
+		Quorum  float64
+		Signals []HealthSignal
+		// ProviderStatusInterval defines how often provider is asked for node status
+		ProviderStatusInterval time.Duration
+
+		lastProviderStatus time.Time
+	}
+)
+
+// NewHealthModel constructor
+func NewHealthModel(mode HealthMode, quorum float64, signals []HealthSignal, providerStatusInterval time.Duration) (*HealthModel, error) {
+	switch mode {
+	case HealthModeAll, HealthModeAny:
+	case HealthModeQuorum:
+		if quorum <= 0 || quorum > 1 {
+			return nil, errors.Errorf("Quorum %v has to be more than 0 and not more than 1", quorum)
+		}
+	default:
+		return nil, errors.Errorf("Health mode [%s] is not supported", mode)
+	}
+
+	if len(signals) == 0 {
+		return nil, errors.Errorf("At least one health signal is required")
+	}
+
+	seen := map[HealthSource]bool{}
+	for _, s := range signals {
+		switch s.Source {
+		case PushHealthSource, ProbeHealthSource, ProviderHealthSource, ScrapeHealthSource, StreamHealthSource:
+		default:
+			return nil, errors.Errorf("Health source [%s] is not supported", s.Source)
+		}
+
+		if seen[s.Source] {
+			return nil, errors.Errorf("Health source [%s] is defined more than once", s.Source)
+		}
+		seen[s.Source] = true
+
+		if s.Weight <= 0 {
+			return nil, errors.Errorf("Weight %v of health source [%s] has to be more than 0", s.Weight, s.Source)
+		}
+	}
+
+	if seen[ProviderHealthSource] && providerStatusInterval <= 0 {
+		return nil, errors.Errorf("ProviderStatusInterval %s has to be more than 0", providerStatusInterval)
+	}
+
+	return &HealthModel{
+		Mode:                   mode,
+		Quorum:                 quorum,
+		Signals:                signals,
+		ProviderStatusInterval: providerStatusInterval,
+	}, nil
+}
+
+// Evaluate signals of given node, verdict of every signal is stored in node.HealthSignals
+func (hm *HealthModel) Evaluate(node *Node, threshold float64, from, to time.Time) bool {
+	statuses := []SignalStatus{}
+	totalWeight := 0.0
+	healthyWeight := 0.0
+	healthy := 0
+
+	// Sources report at the same time, so metrics are kept in a list rather
+	// than in series keyed by time
+	metrics := node.Metrics.Query(HealthMetricType, nil, from, to)
+	for _, s := range hm.Signals {
+		values := healthSourceMetrics(metrics, s.Source)
+		value := averageMetrics(values)
+		// Stream is state of connection, so only its last value counts
+		if s.Source == StreamHealthSource && len(values) > 0 {
+			value = values[len(values)-1].GetValue()
+		}
+
+		status := SignalStatus{
+			Source:     s.Source,
+			Value:      round(value, .5, 2),
+			DataPoints: len(values),
+		}
+		status.Healthy = status.DataPoints > 0 && status.Value >= threshold
+
+		totalWeight = totalWeight + s.Weight
+		if status.Healthy {
+			healthyWeight = healthyWeight + s.Weight
+			healthy++
+		}
+		statuses = append(statuses, status)
+	}
+	node.HealthSignals = statuses
+
+	switch hm.Mode {
+	case HealthModeAll:
+		return healthy == len(hm.Signals)
+	case HealthModeAny:
+		return healthy > 0
+	case HealthModeQuorum:
+		return healthyWeight >= hm.Quorum*totalWeight
+	}
+
+	return false
+}
+
+// usesSource returns true if model has signal of given source
+func (hm *HealthModel) usesSource(source HealthSource) bool {
+	for _, s := range hm.Signals {
+		if s.Source == source {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RecordProviderStatus asks provider for status of every node if it is time to
+// do so. Node which provider reports as off, archived or does not know about
+// at all is recorded as unhealthy
+func (hm *HealthModel) RecordProviderStatus(nodes NodeSet, now time.Time) {
+	if !hm.usesSource(ProviderHealthSource) || now.Sub(hm.lastProviderStatus) < hm.ProviderStatusInterval {
+		return
+	}
+	hm.lastProviderStatus = now
+
+	byProvider := map[Provider][]*Node{}
+	for _, node := range nodes {
+		byProvider[node.Provider] = append(byProvider[node.Provider], node)
+	}
+
+	for provider, providerNodes := range byProvider {
+		driver, err := NewDriver(provider)
+		if err != nil {
+			fmt.Printf("Could not get provider status : %s \n", err)
+			continue
+		}
+
+		statuses, err := driver.Statuses()
+		if err != nil {
+			fmt.Printf("Could not get provider status : %s \n", err)
+			continue
+		}
+
+		for _, node := range providerNodes {
+			value := 1.0
+			switch statuses[node.ID] {
+			case "", "off", "archive":
+				value = 0
+			}
+			node.AddMetrics(NewMetricSeries(NewHealthMetricFrom(value, now, ProviderHealthSource)))
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package domain
+
+import (
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type HealthModelSuite struct{}
+
+var _ = Suite(&HealthModelSuite{})
+
+func prepareSignals(node *Node, push, probe float64) {
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		b := now.Add(time.Duration(time.Second * time.Duration(-1*i)))
+		// Sources are reported separately at the very same time
+		node.AddMetrics(NewMetricSeries(NewHealthMetricFrom(push, b, PushHealthSource)))
+		node.AddMetrics(NewMetricSeries(NewHealthMetricFrom(probe, b, ProbeHealthSource)))
+	}
+}
+
+func (s *HealthModelSuite) TestIfHealthModelCombinesSignalsByMode(c *C) {
+	signals := []HealthSignal{
+		{Source: PushHealthSource, Weight: 1},
+		{Source: ProbeHealthSource, Weight: 3},
+	}
+
+	// Watcher has crashed, but application still responds
+	node := prepareLocalNode(ID("node1"))
+	prepareSignals(node, 0, 1)
+	from, to := time.Now().Add(-10*time.Second), time.Now()
+
+	all, err := NewHealthModel(HealthModeAll, 0, signals, 0)
+	c.Assert(err, IsNil)
+	c.Assert(all.Evaluate(node, 0.7, from, to), Equals, false)
+	c.Assert(node.HealthSignals, DeepEquals, []SignalStatus{
+		{Source: PushHealthSource, Value: 0, DataPoints: 5, Healthy: false},
+		{Source: ProbeHealthSource, Value: 1, DataPoints: 5, Healthy: true},
+	})
+
+	any, err := NewHealthModel(HealthModeAny, 0, signals, 0)
+	c.Assert(err, IsNil)
+	c.Assert(any.Evaluate(node, 0.7, from, to), Equals, true)
+
+	quorum, err := NewHealthModel(HealthModeQuorum, 0.75, signals, 0)
+	c.Assert(err, IsNil)
+	c.Assert(quorum.Evaluate(node, 0.7, from, to), Equals, true)
+
+	// Application is dead while watcher keeps reporting
+	node = prepareLocalNode(ID("node2"))
+	prepareSignals(node, 1, 0)
+	c.Assert(quorum.Evaluate(node, 0.7, from, to), Equals, false)
+}
+
+func (s *HealthModelSuite) TestIfProviderStatusIsRecordedAsSignal(c *C) {
+	drv := registerTestDriver()
+	drv.statuses = map[ID]string{
+		ID("node1"): "active",
+		ID("node2"): "off",
+	}
+
+	node1 := prepareLocalNode(ID("node1"))
+	node2 := prepareLocalNode(ID("node2"))
+	node3 := prepareLocalNode(ID("node3"))
+
+	model, err := NewHealthModel(HealthModeAll, 0, []HealthSignal{{Source: ProviderHealthSource, Weight: 1}}, time.Minute)
+	c.Assert(err, IsNil)
+
+	model.RecordProviderStatus(NewNodeSet(node1, node2, node3), time.Now())
+	from, to := time.Now().Add(-time.Minute), time.Now().Add(time.Second)
+
+	c.Assert(model.Evaluate(node1, 1, from, to), Equals, true)
+	c.Assert(model.Evaluate(node2, 1, from, to), Equals, false)
+	c.Assert(model.Evaluate(node3, 1, from, to), Equals, false)
+}
+
+func (s *HealthModelSuite) TestIfPolicyUsesCompositeVerdict(c *C) {
+	node := prepareLocalNode(ID("node1"))
+	prepareSignals(node, 1, 0)
+
+	asg := NewAutoScalingGroup(ID("asg-1"))
+	asg.Setup(NewNodeSet(node), NewPolicySet())
+
+	plc, err := NewDesiredNodeAmountPerProviderPolicy(ID("policy-1"), 1, 1, 1, 1, 0.7, time.Duration(-10*time.Second), Provider{ID: testProviderID})
+	c.Assert(err, IsNil)
+
+	// Average of all health metrics is 0.5
+	err = plc.Evaluate(asg)
+	c.Assert(err, IsNil)
+	c.Assert(len(asg.Commands), Equals, 1)
+
+	asg.Commands = NewCommandSet()
+	asg.HealthModel, err = NewHealthModel(HealthModeAny, 0, []HealthSignal{
+		{Source: PushHealthSource, Weight: 1},
+		{Source: ProbeHealthSource, Weight: 1},
+	}, 0)
+	c.Assert(err, IsNil)
+
+	err = plc.Evaluate(asg)
+	c.Assert(err, IsNil)
+	c.Assert(len(asg.Commands), Equals, 0)
+	c.Assert(node.State, Equals, NodeStateInService)
+}
+
+func (s *HealthModelSuite) TestIfHealthModelIsValidated(c *C) {
+	signals := []HealthSignal{{Source: PushHealthSource, Weight: 1}}
+
+	_, err := NewHealthModel(HealthMode("most"), 0, signals, 0)
+	c.Assert(err, NotNil)
+
+	_, err = NewHealthModel(HealthModeQuorum, 0, signals, 0)
+	c.Assert(err, NotNil)
+
+	_, err = NewHealthModel(HealthModeAll, 0, []HealthSignal{}, 0)
+	c.Assert(err, NotNil)
+
+	_, err = NewHealthModel(HealthModeAll, 0, []HealthSignal{{Source: PushHealthSource, Weight: 1}, {Source: PushHealthSource, Weight: 1}}, 0)
+	c.Assert(err, NotNil)
+
+	_, err = NewHealthModel(HealthModeAll, 0, []HealthSignal{{Source: ProviderHealthSource, Weight: 1}}, 0)
+	c.Assert(err, NotNil)
+
+	_, err = NewHealthModel(HealthModeAll, 0, []HealthSignal{{Source: ProbeHealthSource, Weight: 0}}, 0)
+	c.Assert(err, NotNil)
+}
@@ -0,0 +1,261 @@
+package domain
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+const (
+	LifecycleLaunching   = LifecycleTransition("launching")
+	LifecycleTerminating = LifecycleTransition("terminating")
+
+	LifecycleContinue = LifecycleResult("CONTINUE")
+	LifecycleAbandon  = LifecycleResult("ABANDON")
+)
+
+type (
+	LifecycleTransition string
+
+	LifecycleResult string
+
+	// LifecycleHook pauses node in transition until hook is completed over API
+	// or until heartbeat timeout passes, then DefaultResult is used
+	LifecycleHook struct {
+		Name             string
+		Transition       LifecycleTransition
+		HeartbeatTimeout time.Duration
+		DefaultResult    LifecycleResult
+		NotificationURL  string
+	}
+
+	// LifecycleNotification is sent to hook NotificationURL
+	LifecycleNotification struct {
+		ASGID      ID
+		NodeID     ID
+		HookName   string
+		Transition LifecycleTransition
+		PublicIP   string
+		PrivateIP  string
+	}
+
+	// LifecycleHooks of ASG together with actions which are waiting to be completed
+	LifecycleHooks struct {
+		sync.Mutex
+		Hooks []LifecycleHook
+
+		actions map[lifecycleActionKey]*lifecycleAction
+	}
+
+	// lifecycleResult of hooks which ASG loop has to act on
+	lifecycleResult struct {
+		node   *Node
+		state  NodeState
+		result LifecycleResult
+		then   func(LifecycleResult) error
+	}
+
+	lifecycleActionKey struct {
+		NodeID   ID
+		HookName string
+	}
+
+	lifecycleAction struct {
+		heartbeat chan bool
+		result    chan LifecycleResult
+	}
+)
+
+// NewLifecycleHook constructor
+func NewLifecycleHook(name string, transition LifecycleTransition, heartbeatTimeout time.Duration, defaultResult LifecycleResult, notificationURL string) (LifecycleHook, error) {
+	if name == "" {
+		return LifecycleHook{}, errors.Errorf("Lifecycle hook name can not be empty")
+	}
+
+	if transition != LifecycleLaunching && transition != LifecycleTerminating {
+		return LifecycleHook{}, errors.Errorf("Lifecycle transition [%s] is not supported", transition)
+	}
+
+	if defaultResult == "" {
+		defaultResult = LifecycleAbandon
+	}
+
+	if defaultResult != LifecycleContinue && defaultResult != LifecycleAbandon {
+		return LifecycleHook{}, errors.Errorf("Lifecycle result [%s] is not supported", defaultResult)
+	}
+
+	if heartbeatTimeout <= 0 {
+		return LifecycleHook{}, errors.Errorf("HeartbeatTimeout %s has to be more than 0", heartbeatTimeout)
+	}
+
+	return LifecycleHook{
+		Name:             name,
+		Transition:       transition,
+		HeartbeatTimeout: heartbeatTimeout,
+		DefaultResult:    defaultResult,
+		NotificationURL:  notificationURL,
+	}, nil
+}
+
+// NewLifecycleHooks constructor
+func NewLifecycleHooks(hooks ...LifecycleHook) *LifecycleHooks {
+	return &LifecycleHooks{
+		Hooks:   hooks,
+		actions: map[lifecycleActionKey]*lifecycleAction{},
+	}
+}
+
+// Has returns true if any hook pauses nodes in given transition
+func (lh *LifecycleHooks) Has(transition LifecycleTransition) bool {
+	for _, hook := range lh.Hooks {
+		if hook.Transition == transition {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Run all hooks of given transition one after another, waits until each of them
+// is completed. ABANDON result stops running remaining hooks
+func (lh *LifecycleHooks) Run(asgID ID, node *Node, transition LifecycleTransition) LifecycleResult {
+	for _, hook := range lh.Hooks {
+		if hook.Transition != transition {
+			continue
+		}
+
+		result := lh.run(asgID, node, hook)
+		fmt.Printf("[%s] Lifecycle hook [%s] for node [%s] completed with [%s] \n", asgID, hook.Name, node.ID, result)
+		if result == LifecycleAbandon {
+			return result
+		}
+	}
+
+	return LifecycleContinue
+}
+
+// Complete action of the hook which is waiting for given node
+func (lh *LifecycleHooks) Complete(nodeID ID, hookName string, result LifecycleResult) error {
+	if result != LifecycleContinue && result != LifecycleAbandon {
+		return errors.Errorf("Lifecycle result [%s] is not supported", result)
+	}
+
+	action, err := lh.get(nodeID, hookName)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case action.result <- result:
+		return nil
+	default:
+		return errors.Errorf("Lifecycle hook [%s] for node [%s] is already being completed", hookName, nodeID)
+	}
+}
+
+// Heartbeat extends timeout of the hook which is waiting for given node
+func (lh *LifecycleHooks) Heartbeat(nodeID ID, hookName string) error {
+	action, err := lh.get(nodeID, hookName)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case action.heartbeat <- true:
+	default:
+		// heartbeat is already pending, timeout will be extended anyway
+	}
+
+	return nil
+}
+
+func (lh *LifecycleHooks) run(asgID ID, node *Node, hook LifecycleHook) LifecycleResult {
+	key := lifecycleActionKey{NodeID: node.ID, HookName: hook.Name}
+	action := &lifecycleAction{
+		heartbeat: make(chan bool, 1),
+		result:    make(chan LifecycleResult, 1),
+	}
+
+	lh.Lock()
+	lh.actions[key] = action
+	lh.Unlock()
+
+	defer func() {
+		lh.Lock()
+		delete(lh.actions, key)
+		lh.Unlock()
+	}()
+
+	err := notify(hook.NotificationURL, LifecycleNotification{
+		ASGID:      asgID,
+		NodeID:     node.ID,
+		HookName:   hook.Name,
+		Transition: hook.Transition,
+		PublicIP:   node.PublicIface.IP.String(),
+		PrivateIP:  node.PrivateIface.IP.String(),
+	})
+	if err != nil {
+		// Nobody knows that node is waiting, so there is no point to wait
+		fmt.Printf("[%s] Could not notify lifecycle hook [%s] : %s \n", asgID, hook.Name, err)
+		return hook.DefaultResult
+	}
+
+	timer := time.NewTimer(hook.HeartbeatTimeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case result := <-action.result:
+			return result
+		case <-action.heartbeat:
+			if !timer.Stop() {
+				<-timer.C
+			}
+			timer.Reset(hook.HeartbeatTimeout)
+		case <-timer.C:
+			return hook.DefaultResult
+		}
+	}
+}
+
+func (lh *LifecycleHooks) get(nodeID ID, hookName string) (*lifecycleAction, error) {
+	lh.Lock()
+	defer lh.Unlock()
+
+	action, ok := lh.actions[lifecycleActionKey{NodeID: nodeID, HookName: hookName}]
+	if !ok {
+		return nil, errors.Errorf("Lifecycle hook [%s] is not waiting for node [%s]", hookName, nodeID)
+	}
+
+	return action, nil
+}
+
+// notify posts given notification as JSON, nothing is done if url is empty
+func notify(url string, notification interface{}) error {
+	if url == "" {
+		return nil
+	}
+
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	client := &http.Client{Timeout: time.Second * 10}
+	resp, err := client.Post(url, "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return errors.Errorf("Notification was rejected with status [%d]", resp.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,176 @@
+package domain
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type LifecycleSuite struct{}
+
+var _ = Suite(&LifecycleSuite{})
+
+// prepareHookServer returns server which passes received notifications to the channel
+func prepareHookServer() (*httptest.Server, chan LifecycleNotification) {
+	notifications := make(chan LifecycleNotification, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		n := LifecycleNotification{}
+		json.NewDecoder(r.Body).Decode(&n)
+		notifications <- n
+	}))
+
+	return srv, notifications
+}
+
+func prepareHookAsg(hooks ...LifecycleHook) *AutoScalingGroup {
+	asg := NewAutoScalingGroup(ID("asg-1"))
+	asg.Setup(NewNodeSet(), NewPolicySet())
+	asg.Hooks = NewLifecycleHooks(hooks...)
+
+	return asg
+}
+
+// completeLifecycle waits until given amount of lifecycle actions are
+// completed in background and lets ASG act on them
+func completeLifecycle(c *C, asg *AutoScalingGroup, expected int) {
+	for i := 0; i < 200; i++ {
+		asg.lock.Lock()
+		done := len(asg.lifecycleResults)
+		asg.lock.Unlock()
+
+		if done >= expected {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	asg.completeLifecycleActions()
+}
+
+func (s *LifecycleSuite) TestIfLaunchWaitsForHookToBeCompleted(c *C) {
+	drv := registerTestDriver()
+	srv, notifications := prepareHookServer()
+	defer srv.Close()
+
+	hook, err := NewLifecycleHook("warm-up", LifecycleLaunching, time.Minute, LifecycleAbandon, srv.URL)
+	c.Assert(err, IsNil)
+	asg := prepareHookAsg(hook)
+
+	// Launch does not wait for hook, so ASG loop is not blocked
+	err = (&Launch{BaseCommand: BaseCommand{Provider: Provider{ID: testProviderID}}}).Execute(asg)
+	c.Assert(err, IsNil)
+	c.Assert(len(asg.Nodes), Equals, 1)
+	c.Assert(asg.Nodes.GetByID(ID("test-1")).State, Equals, NodeStatePendingWait)
+
+	n := <-notifications
+	c.Assert(n.ASGID, Equals, ID("asg-1"))
+	c.Assert(n.HookName, Equals, "warm-up")
+	c.Assert(n.Transition, Equals, LifecycleLaunching)
+	c.Assert(asg.RecordLifecycleActionHeartbeat(n.NodeID, "warm-up"), IsNil)
+
+	// Node stays waiting until ASG acts on completed hook
+	c.Assert(asg.CompleteLifecycleAction(n.NodeID, "warm-up", LifecycleContinue), IsNil)
+	c.Assert(asg.Nodes.GetByID(ID("test-1")).State, Equals, NodeStatePendingWait)
+
+	completeLifecycle(c, asg, 1)
+	c.Assert(asg.Nodes.GetByID(ID("test-1")).State, Equals, NodeStatePending)
+	c.Assert(len(drv.deleted), Equals, 0)
+}
+
+func (s *LifecycleSuite) TestIfAbandonedLaunchRemovesNode(c *C) {
+	drv := registerTestDriver()
+	srv, notifications := prepareHookServer()
+	defer srv.Close()
+
+	hook, err := NewLifecycleHook("warm-up", LifecycleLaunching, time.Minute, LifecycleContinue, srv.URL)
+	c.Assert(err, IsNil)
+	asg := prepareHookAsg(hook)
+
+	err = (&Launch{BaseCommand: BaseCommand{Provider: Provider{ID: testProviderID}}}).Execute(asg)
+	c.Assert(err, IsNil)
+
+	n := <-notifications
+	c.Assert(asg.CompleteLifecycleAction(n.NodeID, "warm-up", LifecycleAbandon), IsNil)
+
+	completeLifecycle(c, asg, 1)
+	c.Assert(len(asg.Nodes), Equals, 0)
+	c.Assert(drv.deleted, DeepEquals, []ID{ID("test-1")})
+}
+
+func (s *LifecycleSuite) TestIfDefaultResultIsUsedAfterHeartbeatTimeout(c *C) {
+	registerTestDriver()
+	srv, _ := prepareHookServer()
+	defer srv.Close()
+
+	hook, err := NewLifecycleHook("drain", LifecycleTerminating, time.Millisecond*100, LifecycleContinue, srv.URL)
+	c.Assert(err, IsNil)
+	asg := prepareHookAsg(hook)
+
+	node := prepareNode(0, ID("node1"), 5)
+	node.Provider.ID = testProviderID
+	asg.AddNode(node)
+
+	started := time.Now()
+	err = (&Terminate{BaseCommand: BaseCommand{Provider: Provider{ID: testProviderID}}, NodeID: ID("node1")}).Execute(asg)
+	c.Assert(err, IsNil)
+	c.Assert(node.State, Equals, NodeStateTerminatingWait)
+
+	completeLifecycle(c, asg, 1)
+	c.Assert(time.Since(started) >= time.Millisecond*100, Equals, true)
+	c.Assert(len(asg.Nodes), Equals, 0)
+
+	// Nothing is waiting any more
+	err = asg.CompleteLifecycleAction(ID("node1"), "drain", LifecycleContinue)
+	c.Assert(err, NotNil)
+}
+
+func (s *LifecycleSuite) TestIfReplacedNodeIsKeptUntilNewOneIsReady(c *C) {
+	drv := registerTestDriver()
+	srv, notifications := prepareHookServer()
+	defer srv.Close()
+
+	hook, err := NewLifecycleHook("warm-up", LifecycleLaunching, time.Minute, LifecycleAbandon, srv.URL)
+	c.Assert(err, IsNil)
+	asg := prepareHookAsg(hook)
+
+	old := prepareNode(0, ID("node1"), 5)
+	old.Provider.ID = testProviderID
+	old.ChangeState(NodeStateUnhealthy)
+	asg.AddNode(old)
+
+	err = (&Relaunch{BaseCommand: BaseCommand{Provider: Provider{ID: testProviderID}}, NodeID: ID("node1")}).Execute(asg)
+	c.Assert(err, IsNil)
+	c.Assert(asg.inTransition(old), Equals, true)
+
+	// Neither of nodes is judged or replaced while new one waits
+	policy, err := NewDesiredNodeAmountPerProviderPolicy(ID("p1"), 0, 2, 1, 1, 0.5, -time.Minute, Provider{ID: testProviderID})
+	c.Assert(err, IsNil)
+	c.Assert(policy.Evaluate(asg), IsNil)
+	c.Assert(policy.(*DesiredHealthyNodeAmountPerProviderPolicy).Current, Equals, 1)
+	c.Assert(len(asg.Commands), Equals, 0)
+
+	n := <-notifications
+	c.Assert(asg.CompleteLifecycleAction(n.NodeID, "warm-up", LifecycleContinue), IsNil)
+
+	completeLifecycle(c, asg, 1)
+	c.Assert(asg.Nodes.GetByID(ID("node1")), IsNil)
+	c.Assert(drv.deleted, DeepEquals, []ID{ID("node1")})
+	c.Assert(asg.Nodes.GetByID(n.NodeID).State, Equals, NodeStatePending)
+}
+
+func (s *LifecycleSuite) TestIfLifecycleHookIsValidated(c *C) {
+	_, err := NewLifecycleHook("", LifecycleLaunching, time.Minute, LifecycleContinue, "")
+	c.Assert(err, NotNil)
+
+	_, err = NewLifecycleHook("hook", LifecycleTransition("rebooting"), time.Minute, LifecycleContinue, "")
+	c.Assert(err, NotNil)
+
+	_, err = NewLifecycleHook("hook", LifecycleLaunching, time.Minute, LifecycleResult("MAYBE"), "")
+	c.Assert(err, NotNil)
+
+	_, err = NewLifecycleHook("hook", LifecycleLaunching, 0, LifecycleContinue, "")
+	c.Assert(err, NotNil)
+}
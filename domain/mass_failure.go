@@ -0,0 +1,202 @@
+package domain
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+const maxGuardEvents = 50
+
+type (
+	// MassFailureGuard freezes replacement of unhealthy nodes when more than
+	// MaxUnhealthyFraction of nodes turn unhealthy within Window. So many
+	// failures at once are more likely caused by artemis not seeing nodes
+	// than by nodes themselves. Replacements stay frozen until unhealthy part
+	// of nodes drops back to MaxUnhealthyFraction or until they are confirmed.
+	// Guard can watch single ASG or all of them at once
+	MassFailureGuard struct {
+		sync.Mutex
+		MaxUnhealthyFraction float64
+		Window               time.Duration
+		// MinUnhealthy nodes have to turn unhealthy before guard is tripped,
+		// so single failure in small ASG does not freeze it
+		MinUnhealthy int
+		// AlertURL is optional, freeze and unfreeze are posted to it
+		AlertURL string
+
+		Frozen   bool
+		FrozenAt time.Time
+		Events   []NodeEvent
+
+		totals    map[ID]int
+		unhealthy map[ID]map[ID]time.Time
+	}
+
+	// MassFailureAlert is sent to AlertURL when replacements are frozen or unfrozen
+	MassFailureAlert struct {
+		ASGID     ID
+		Frozen    bool
+		Unhealthy int
+		Total     int
+		Message   string
+	}
+)
+
+// NewMassFailureGuard constructor
+func NewMassFailureGuard(maxUnhealthyFraction float64, window time.Duration, minUnhealthy int, alertURL string) (*MassFailureGuard, error) {
+	if maxUnhealthyFraction <= 0 || maxUnhealthyFraction >= 1 {
+		return nil, errors.Errorf("MaxUnhealthyFraction %v has to be more than 0 and less than 1", maxUnhealthyFraction)
+	}
+
+	if window <= 0 {
+		return nil, errors.Errorf("Window %s has to be more than 0", window)
+	}
+
+	if minUnhealthy == 0 {
+		minUnhealthy = 1
+	}
+
+	if minUnhealthy < 0 {
+		return nil, errors.Errorf("MinUnhealthy %d can not be negative", minUnhealthy)
+	}
+
+	return &MassFailureGuard{
+		MaxUnhealthyFraction: maxUnhealthyFraction,
+		Window:               window,
+		MinUnhealthy:         minUnhealthy,
+		AlertURL:             alertURL,
+		totals:               map[ID]int{},
+		unhealthy:            map[ID]map[ID]time.Time{},
+	}, nil
+}
+
+// Observe nodes of given ASG, returns true if replacements are frozen
+func (g *MassFailureGuard) Observe(asgID ID, nodes NodeSet, now time.Time) bool {
+	g.Lock()
+	defer g.Unlock()
+
+	seen, ok := g.unhealthy[asgID]
+	if !ok {
+		seen = map[ID]time.Time{}
+		g.unhealthy[asgID] = seen
+	}
+
+	for id := range seen {
+		if node, ok := nodes[id]; !ok || node.State != NodeStateUnhealthy {
+			delete(seen, id)
+		}
+	}
+
+	for id, node := range nodes {
+		if _, ok := seen[id]; !ok && node.State == NodeStateUnhealthy {
+			seen[id] = now
+		}
+	}
+	g.totals[asgID] = len(nodes)
+
+	total, unhealthy, recent := g.count(now.Add(-g.Window))
+	if total == 0 {
+		return g.Frozen
+	}
+
+	if !g.Frozen && recent >= g.MinUnhealthy && float64(recent)/float64(total) > g.MaxUnhealthyFraction {
+		g.Frozen = true
+		g.FrozenAt = now
+		g.change(asgID, unhealthy, total, fmt.Sprintf("%d of %d nodes have turned unhealthy within %s, replacements are frozen", recent, total, g.Window))
+	} else if g.Frozen && float64(unhealthy)/float64(total) <= g.MaxUnhealthyFraction {
+		g.Frozen = false
+		g.change(asgID, unhealthy, total, fmt.Sprintf("%d of %d nodes are unhealthy, replacements are resumed", unhealthy, total))
+	}
+
+	return g.Frozen
+}
+
+// IsFrozen returns true if replacements are frozen
+func (g *MassFailureGuard) IsFrozen() bool {
+	g.Lock()
+	defer g.Unlock()
+
+	return g.Frozen
+}
+
+// Confirm that nodes which are unhealthy now have to be replaced, they are
+// not taken into account when guard decides to freeze replacements again
+func (g *MassFailureGuard) Confirm(asgID ID) {
+	g.Lock()
+	defer g.Unlock()
+
+	for _, seen := range g.unhealthy {
+		for id := range seen {
+			seen[id] = time.Time{}
+		}
+	}
+
+	total, unhealthy, _ := g.count(time.Now().Add(-g.Window))
+	g.Frozen = false
+	g.change(asgID, unhealthy, total, "Replacements are confirmed")
+}
+
+// Forget nodes of removed ASG, replacements are resumed if remaining nodes
+// do not keep guard tripped
+func (g *MassFailureGuard) Forget(asgID ID) {
+	g.Lock()
+	defer g.Unlock()
+
+	delete(g.totals, asgID)
+	delete(g.unhealthy, asgID)
+
+	total, unhealthy, _ := g.count(time.Now().Add(-g.Window))
+	if g.Frozen && (total == 0 || float64(unhealthy)/float64(total) <= g.MaxUnhealthyFraction) {
+		g.Frozen = false
+		g.change(asgID, unhealthy, total, fmt.Sprintf("ASG is removed, %d of %d nodes are unhealthy, replacements are resumed", unhealthy, total))
+	}
+}
+
+// count all nodes, unhealthy ones and those which have turned unhealthy after given time
+func (g *MassFailureGuard) count(from time.Time) (total, unhealthy, recent int) {
+	for _, t := range g.totals {
+		total = total + t
+	}
+
+	for _, seen := range g.unhealthy {
+		for _, since := range seen {
+			unhealthy++
+			if !since.Before(from) {
+				recent++
+			}
+		}
+	}
+
+	return total, unhealthy, recent
+}
+
+// change records event and alerts about it in background
+func (g *MassFailureGuard) change(asgID ID, unhealthy, total int, message string) {
+	g.Events = append(g.Events, NodeEvent{
+		Time:    time.Now(),
+		Message: message,
+	})
+
+	if len(g.Events) > maxGuardEvents {
+		g.Events = g.Events[len(g.Events)-maxGuardEvents:]
+	}
+
+	fmt.Printf("[%s] Mass failure guard: %s \n", asgID, message)
+
+	alert := MassFailureAlert{
+		ASGID:     asgID,
+		Frozen:    g.Frozen,
+		Unhealthy: unhealthy,
+		Total:     total,
+		Message:   message,
+	}
+
+	go func(url string) {
+		if err := notify(url, alert); err != nil {
+			fmt.Printf("[%s] Could not send mass failure alert : %s \n", asgID, err)
+		}
+	}(g.AlertURL)
+}
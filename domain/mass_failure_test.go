@@ -0,0 +1,169 @@
+package domain
+
+import (
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type MassFailureSuite struct{}
+
+var _ = Suite(&MassFailureSuite{})
+
+func prepareGuardedAsg(id ID, failing, healthy int) *AutoScalingGroup {
+	nodes := NewNodeSet()
+	for i := 0; i < failing+healthy; i++ {
+		node := prepareLocalNode(ID(string(id) + "-node" + string(rune('a'+i))))
+		if i < failing {
+			node.AddMetrics(prepareMetrics(5, 5))
+		} else {
+			node.AddMetrics(prepareMetrics(0, 5))
+		}
+		nodes[node.ID] = node
+	}
+
+	asg := NewAutoScalingGroup(id)
+	asg.Setup(nodes, NewPolicySet())
+
+	return asg
+}
+
+func (s *MassFailureSuite) TestIfReplacementsAreFrozenWhenMostNodesFailAtOnce(c *C) {
+	asg := prepareGuardedAsg(ID("asg-1"), 3, 1)
+	guard, err := NewMassFailureGuard(0.5, time.Minute, 2, "")
+	c.Assert(err, IsNil)
+	asg.Guard = guard
+
+	plc, err := NewDesiredNodeAmountPerProviderPolicy(ID("policy-1"), 4, 4, 4, 1, 0.7, time.Duration(-5*time.Second), Provider{ID: testProviderID})
+	c.Assert(err, IsNil)
+
+	err = plc.Evaluate(asg)
+	c.Assert(err, IsNil)
+	c.Assert(guard.Frozen, Equals, true)
+	c.Assert(guard.Events[0].Message, Equals, "3 of 4 nodes have turned unhealthy within 1m0s, replacements are frozen")
+
+	// Failing nodes are neither replaced nor compensated by new ones
+	c.Assert(len(asg.Commands), Equals, 0)
+	err = plc.Evaluate(asg)
+	c.Assert(err, IsNil)
+	c.Assert(len(asg.Commands), Equals, 0)
+
+	// Once confirmed, nodes are replaced
+	c.Assert(asg.ConfirmReplacements(), IsNil)
+	c.Assert(guard.Frozen, Equals, false)
+	err = plc.Evaluate(asg)
+	c.Assert(err, IsNil)
+	c.Assert(guard.Frozen, Equals, false)
+	c.Assert(len(asg.Commands), Equals, 3)
+}
+
+func (s *MassFailureSuite) TestIfReplacementsAreResumedWhenNodesRecover(c *C) {
+	asg := prepareGuardedAsg(ID("asg-1"), 2, 2)
+	guard, err := NewMassFailureGuard(0.25, time.Minute, 1, "")
+	c.Assert(err, IsNil)
+	asg.Guard = guard
+
+	plc, err := NewDesiredNodeAmountPerProviderPolicy(ID("policy-1"), 4, 4, 4, 1, 0.7, time.Duration(-5*time.Second), Provider{ID: testProviderID})
+	c.Assert(err, IsNil)
+
+	err = plc.Evaluate(asg)
+	c.Assert(err, IsNil)
+	c.Assert(guard.Frozen, Equals, true)
+	c.Assert(len(asg.Commands), Equals, 0)
+
+	// Metrics pipeline is back, one node really is broken
+	asg.Nodes[ID("asg-1-nodea")].Metrics.Reset()
+	asg.Nodes[ID("asg-1-nodea")].AddMetrics(prepareMetrics(0, 5))
+	err = plc.Evaluate(asg)
+	c.Assert(err, IsNil)
+	c.Assert(guard.Frozen, Equals, false)
+	c.Assert(len(asg.Commands), Equals, 1)
+	c.Assert(asg.Commands[Order(1)], DeepEquals, &Relaunch{
+		BaseCommand: BaseCommand{
+			Provider: Provider{ID: testProviderID},
+			Reason:   "Node [asg-1-nodeb] has failed 2 consecutive health checks",
+		},
+		NodeID: ID("asg-1-nodeb"),
+	})
+}
+
+func (s *MassFailureSuite) TestIfGlobalGuardWatchesAllASGs(c *C) {
+	guard, err := NewMassFailureGuard(0.5, time.Minute, 1, "")
+	c.Assert(err, IsNil)
+
+	first := prepareGuardedAsg(ID("asg-1"), 2, 0)
+	second := prepareGuardedAsg(ID("asg-2"), 1, 3)
+	for _, asg := range []*AutoScalingGroup{first, second} {
+		asg.GlobalGuard = guard
+		c.Assert(asg.replacementsFrozen(), Equals, false)
+	}
+
+	for _, asg := range []*AutoScalingGroup{first, second} {
+		for _, node := range asg.Nodes {
+			if node.CalculateMetricValue(HealthMetricType, time.Now().Add(-5*time.Second), time.Now()) < 0.7 {
+				node.ChangeState(NodeStateUnhealthy)
+			}
+		}
+	}
+
+	// 2 of 2 nodes, but only 2 of 6 in total
+	c.Assert(first.replacementsFrozen(), Equals, false)
+	c.Assert(second.replacementsFrozen(), Equals, false)
+
+	second.Nodes[ID("asg-2-nodeb")].ChangeState(NodeStateUnhealthy)
+	c.Assert(second.replacementsFrozen(), Equals, true)
+	c.Assert(first.replacementsFrozen(), Equals, true)
+
+	// ASG without own guard can not be confirmed, global one is confirmed on supervisor
+	c.Assert(first.ConfirmReplacements(), NotNil)
+	guard.Confirm(ID(""))
+	c.Assert(first.replacementsFrozen(), Equals, false)
+}
+
+func (s *MassFailureSuite) TestIfRemovedASGIsForgottenByGlobalGuard(c *C) {
+	guard, err := NewMassFailureGuard(0.5, time.Minute, 1, "")
+	c.Assert(err, IsNil)
+
+	supervisor := MakeMultiSupervisor()
+	supervisor.SetGuard(guard)
+
+	first := prepareGuardedAsg(ID("asg-1"), 2, 0)
+	second := prepareGuardedAsg(ID("asg-2"), 0, 1)
+	for _, asg := range []*AutoScalingGroup{first, second} {
+		asg.GlobalGuard = guard
+		supervisor.autoScalingGroups[asg.ID] = asg
+		c.Assert(asg.replacementsFrozen(), Equals, false)
+	}
+
+	for _, node := range first.Nodes {
+		node.ChangeState(NodeStateUnhealthy)
+	}
+	c.Assert(first.replacementsFrozen(), Equals, true)
+
+	supervisor.Remove(first.ID)
+	c.Assert(guard.Frozen, Equals, false)
+	c.Assert(guard.totals, DeepEquals, map[ID]int{ID("asg-2"): 1})
+	c.Assert(guard.unhealthy, HasLen, 1)
+
+	supervisor.Remove(second.ID)
+	c.Assert(guard.totals, HasLen, 0)
+	c.Assert(guard.unhealthy, HasLen, 0)
+}
+
+func (s *MassFailureSuite) TestIfGuardSettingsAreValidated(c *C) {
+	_, err := NewMassFailureGuard(0, time.Minute, 1, "")
+	c.Assert(err, NotNil)
+
+	_, err = NewMassFailureGuard(1, time.Minute, 1, "")
+	c.Assert(err, NotNil)
+
+	_, err = NewMassFailureGuard(0.5, 0, 1, "")
+	c.Assert(err, NotNil)
+
+	_, err = NewMassFailureGuard(0.5, time.Minute, -1, "")
+	c.Assert(err, NotNil)
+
+	guard, err := NewMassFailureGuard(0.5, time.Minute, 0, "")
+	c.Assert(err, IsNil)
+	c.Assert(guard.MinUnhealthy, Equals, 1)
+}
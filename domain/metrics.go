@@ -1,6 +1,10 @@
 package domain
 
-import "time"
+import (
+	"sort"
+	"strings"
+	"time"
+)
 
 type (
 	// Metric type
@@ -11,10 +15,38 @@ type (
 
 	// HealthMetric metric
 	HealthMetric struct {
+		Value  float64
+		Time   time.Time
+		Source HealthSource
+	}
+
+	// BacklogMetric metric, amount of work waiting to be processed by ASG
+	BacklogMetric struct {
 		Value float64
 		Time  time.Time
 	}
 
+	// NamedMetric is metric of any name, e.g. cpu or memory, labels tell
+	// apart series of the same name, e.g. cpu of every core
+	NamedMetric struct {
+		Name   MetricType
+		Unit   string
+		Labels Labels
+		Value  float64
+		Time   time.Time
+	}
+
+	// Labels of named metric
+	Labels map[string]string
+
+	// queuedMetrics of node, or of ASG itself if node is empty, which ASG
+	// loop has to add, then gets the result
+	queuedMetrics struct {
+		node    ID
+		metrics []Metric
+		then    func(error)
+	}
+
 	// MetricSeries type
 	MetricSeries map[time.Time]Metric
 )
@@ -29,11 +61,17 @@ func NewMetricSeries(m ...Metric) MetricSeries {
 	return ms
 }
 
-// NewHealthMetric constructor
+// NewHealthMetric constructor, metric is reported by node itself
 func NewHealthMetric(val float64, t time.Time) Metric {
+	return NewHealthMetricFrom(val, t, PushHealthSource)
+}
+
+// NewHealthMetricFrom constructor for metric of given source
+func NewHealthMetricFrom(val float64, t time.Time, source HealthSource) Metric {
 	return HealthMetric{
-		Value: val,
-		Time:  t,
+		Value:  val,
+		Time:   t,
+		Source: source,
 	}
 }
 
@@ -46,3 +84,164 @@ func (hm HealthMetric) GetValue() float64 {
 func (hm HealthMetric) GetTimestamp() time.Time {
 	return hm.Time
 }
+
+// NewBacklogMetric constructor
+func NewBacklogMetric(val float64, t time.Time) Metric {
+	return BacklogMetric{
+		Value: val,
+		Time:  t,
+	}
+}
+
+// GetValue ...
+func (bm BacklogMetric) GetValue() float64 {
+	return bm.Value
+}
+
+// GetTimestamp ...
+func (bm BacklogMetric) GetTimestamp() time.Time {
+	return bm.Time
+}
+
+// NewNamedMetric constructor
+func NewNamedMetric(name MetricType, unit string, labels Labels, val float64, t time.Time) NamedMetric {
+	return NamedMetric{
+		Name:   name,
+		Unit:   unit,
+		Labels: labels,
+		Value:  val,
+		Time:   t,
+	}
+}
+
+// GetValue ...
+func (nm NamedMetric) GetValue() float64 {
+	return nm.Value
+}
+
+// GetTimestamp ...
+func (nm NamedMetric) GetTimestamp() time.Time {
+	return nm.Time
+}
+
+// Matches returns true if labels contain all of given ones
+func (l Labels) Matches(required Labels) bool {
+	for k, v := range required {
+		if l[k] != v {
+			return false
+		}
+	}
+
+	return true
+}
+
+// String representation of labels, sorted by name
+func (l Labels) String() string {
+	pairs := []string{}
+	for k, v := range l {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// sortMetrics by time they were reported
+func sortMetrics(metrics []Metric) {
+	sort.Slice(metrics, func(i, j int) bool {
+		return metrics[i].GetTimestamp().Before(metrics[j].GetTimestamp())
+	})
+}
+
+// Filter returns metrics of given type which were reported between from and to
+func (ms MetricSeries) Filter(metricType MetricType, from, to time.Time) MetricSeries {
+	rez := NewMetricSeries()
+	for t, m := range ms {
+		if isRequiredMetric(m, metricType) && t.After(from) && t.Before(to) {
+			rez[t] = m
+		}
+	}
+
+	return rez
+}
+
+// FilterHealthSource returns health metrics of given source
+func (ms MetricSeries) FilterHealthSource(source HealthSource) MetricSeries {
+	rez := NewMetricSeries()
+	for t, m := range ms {
+		if hm, ok := m.(HealthMetric); ok && hm.Source == source {
+			rez[t] = m
+		}
+	}
+
+	return rez
+}
+
+// healthSourceMetrics returns health metrics of given source, order is kept
+func healthSourceMetrics(metrics []Metric, source HealthSource) []Metric {
+	rez := []Metric{}
+	for _, m := range metrics {
+		if hm, ok := m.(HealthMetric); ok && hm.Source == source {
+			rez = append(rez, m)
+		}
+	}
+
+	return rez
+}
+
+// averageMetrics returns avg value of metrics, 0 if there are none
+func averageMetrics(metrics []Metric) float64 {
+	if len(metrics) == 0 {
+		return 0
+	}
+
+	value := 0.0
+	for _, m := range metrics {
+		value = value + m.GetValue()
+	}
+
+	return value / float64(len(metrics))
+}
+
+// Average returns avg value of metrics in series, 0 if series is empty
+func (ms MetricSeries) Average() float64 {
+	if len(ms) == 0 {
+		return 0
+	}
+
+	value := 0.0
+	for _, m := range ms {
+		value = value + m.GetValue()
+	}
+
+	return value / float64(len(ms))
+}
+
+// List returns metrics of series sorted by time
+func (ms MetricSeries) List() []Metric {
+	rez := []Metric{}
+	for _, m := range ms {
+		rez = append(rez, m)
+	}
+	sortMetrics(rez)
+
+	return rez
+}
+
+// namedMetrics returns health and backlog metrics as metrics of their own
+// type, so they are treated as before, all other metrics stay named
+func namedMetrics(metrics []NamedMetric) []Metric {
+	rez := []Metric{}
+	for _, m := range metrics {
+		switch m.Name {
+		case HealthMetricType:
+			rez = append(rez, NewHealthMetric(m.Value, m.Time))
+		case BacklogMetricType:
+			rez = append(rez, NewBacklogMetric(m.Value, m.Time))
+		default:
+			rez = append(rez, m)
+		}
+	}
+
+	return rez
+}
@@ -1,6 +1,10 @@
 package domain
 
-import "sync"
+import (
+	"sort"
+	"sync"
+	"time"
+)
 
 var wg sync.WaitGroup
 
@@ -10,6 +14,8 @@ type (
 		sync.RWMutex
 		autoScalingGroups AutoScalingGroupSet
 		stop              chan bool
+		guard             *MassFailureGuard
+		shadow            bool
 	}
 )
 
@@ -35,6 +41,21 @@ func (s *MultiSupervisor) Add(asg *AutoScalingGroup) {
 	s.runASG(asg)
 }
 
+// SetGuard watches nodes of all ASGs added afterwards for mass failure
+func (s *MultiSupervisor) SetGuard(guard *MassFailureGuard) {
+	s.guard = guard
+}
+
+// SetShadow runs all ASGs added afterwards in shadow mode
+func (s *MultiSupervisor) SetShadow(shadow bool) {
+	s.shadow = shadow
+}
+
+// Guard returns global mass failure guard, nil if it is not set
+func (s *MultiSupervisor) Guard() *MassFailureGuard {
+	return s.guard
+}
+
 // Get ASG
 func (s *MultiSupervisor) Get(id ID) *AutoScalingGroup {
 	if !s.exists(id) {
@@ -44,12 +65,34 @@ func (s *MultiSupervisor) Get(id ID) *AutoScalingGroup {
 	return s.get(id)
 }
 
+// List returns all ASGs sorted by ID
+func (s *MultiSupervisor) List() []*AutoScalingGroup {
+	s.RLock()
+	defer s.RUnlock()
+
+	rez := []*AutoScalingGroup{}
+	for _, asg := range s.autoScalingGroups {
+		rez = append(rez, asg)
+	}
+
+	sort.Slice(rez, func(i, j int) bool {
+		return rez[i].ID < rez[j].ID
+	})
+
+	return rez
+}
+
 // Remove ASG
 func (s *MultiSupervisor) Remove(id ID) {
 	if s.exists(id) {
 		s.get(id).Stop()
 		delete(s.autoScalingGroups, id)
 	}
+
+	// Nodes of removed ASG are not there anymore
+	if s.guard != nil {
+		s.guard.Forget(id)
+	}
 }
 
 // Private stuff
@@ -62,6 +105,16 @@ func (s *MultiSupervisor) runASG(asg *AutoScalingGroup) {
 		return
 	}
 
+	if s.shadow && asg.Shadow == nil {
+		asg.Shadow = NewShadowLog()
+	}
+
+	// Global guard has to know about all nodes before any of them fails
+	asg.GlobalGuard = s.guard
+	if s.guard != nil {
+		s.guard.Observe(asg.ID, asg.Nodes, time.Now())
+	}
+
 	// add to map
 	s.add(asg)
 
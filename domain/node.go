@@ -6,16 +6,33 @@ import (
 	"time"
 )
 
+const maxNodeHistory = 50
+
 type (
 	// Node type
 	Node struct {
-		ID            ID
-		Provider      Provider
-		PrivateIface  NetworkInterface
-		PublicIface   NetworkInterface
-		State         NodeState
-		Metrics       MetricSeries
-		KeepMetricFor time.Duration
+		ID           ID
+		Provider     Provider
+		PrivateIface NetworkInterface
+		PublicIface  NetworkInterface
+		State        NodeState
+		// Metrics reported by node, health as well as any named ones
+		Metrics    *MetricStore
+		LaunchedAt time.Time
+		// HealthSignals are set when ASG uses HealthModel
+		HealthSignals []SignalStatus
+		// LastReportedAt is time when node itself reported metrics last time
+		LastReportedAt time.Time
+		// MissingData is set when there was no data to judge node health
+		MissingData bool
+		// History of what has been done to the node
+		History []NodeEvent
+	}
+
+	// NodeEvent type
+	NodeEvent struct {
+		Time    time.Time
+		Message string
 	}
 
 	// NodeSet set
@@ -48,13 +65,13 @@ func NewNode() *Node {
 
 // Create new node
 func (n *Node) Setup(id ID, provider Provider, prIface, puIface NetworkInterface) error {
-	n.State = NodeStateUnhealthy
+	n.State = NodeStatePending
+	n.LaunchedAt = time.Now()
 	n.ID = id
 	n.Provider = provider
 	n.PrivateIface = prIface
 	n.PublicIface = puIface
-	n.KeepMetricFor = time.Minute * -1
-	n.Metrics = NewMetricSeries()
+	n.Metrics = NewMetricStore(DefaultRetention)
 
 	return nil
 }
@@ -71,6 +88,70 @@ func (n *Node) ChangeState(State NodeState) error {
 	return nil
 }
 
+// InGracePeriod returns true if node has not reported healthy yet and
+// was launched less than grace period ago
+func (n *Node) InGracePeriod(grace time.Duration) bool {
+	return n.InGracePeriodAt(grace, time.Now())
+}
+
+// InGracePeriodAt is InGracePeriod at given time
+func (n *Node) InGracePeriodAt(grace time.Duration, now time.Time) bool {
+	return n.State == NodeStatePending && now.Sub(n.LaunchedAt) < grace
+}
+
+// clone returns copy of node which can be changed without affecting original
+func (n *Node) clone() *Node {
+	clone := *n
+	clone.Metrics = n.Metrics.Clone()
+	clone.HealthSignals = append([]SignalStatus{}, n.HealthSignals...)
+	clone.History = append([]NodeEvent{}, n.History...)
+
+	return &clone
+}
+
+// RecordEvent adds event to node history, only last maxNodeHistory events are kept
+func (n *Node) RecordEvent(format string, args ...interface{}) {
+	n.History = append(n.History, NodeEvent{
+		Time:    time.Now(),
+		Message: fmt.Sprintf(format, args...),
+	})
+
+	if len(n.History) > maxNodeHistory {
+		n.History = n.History[len(n.History)-maxNodeHistory:]
+	}
+}
+
+// RecordReport marks time when node has reported last time
+func (n *Node) RecordReport(t time.Time) {
+	if t.After(n.LastReportedAt) {
+		n.LastReportedAt = t
+	}
+}
+
+// IsStale returns true if node has not reported for longer than heartbeat
+// timeout, node which never reported is measured from its launch
+func (n *Node) IsStale(heartbeatTimeout time.Duration, now time.Time) bool {
+	if heartbeatTimeout <= 0 {
+		return false
+	}
+
+	last := n.LastReportedAt
+	if last.IsZero() {
+		last = n.LaunchedAt
+	}
+
+	return now.Sub(last) > heartbeatTimeout
+}
+
+// IsMissingData returns true if node is stale or has no health data in given range
+func (n *Node) IsMissingData(heartbeatTimeout time.Duration, from, to time.Time) bool {
+	if n.IsStale(heartbeatTimeout, to) {
+		return true
+	}
+
+	return len(n.Metrics.Query(HealthMetricType, nil, from, to)) == 0
+}
+
 // ChangeNetworkInterfaces ...
 func (n *Node) ChangeNetworkInterfaces(prIface, puIface *NetworkInterface) error {
 	if prIface != nil {
@@ -92,51 +173,39 @@ func (n *Node) Remove() error {
 
 // AddMetrics ...
 func (n *Node) AddMetrics(metrics MetricSeries) error {
+	return n.addMetrics(metrics.List(), time.Now())
+}
+
+// addMetrics adds metrics, those which are too old at given time are removed
+func (n *Node) addMetrics(metrics []Metric, now time.Time) error {
 	if n.Metrics == nil {
-		n.Metrics = NewMetricSeries()
+		n.Metrics = NewMetricStore(DefaultRetention)
 	}
 
-	n.clearMetrics()
-	for t, m := range metrics {
-		n.Metrics[t] = m
-	}
+	return n.Metrics.Add(now, metrics...)
+}
 
-	return nil
+// AddNamedMetrics adds metrics of any name, health metrics are added the
+// same way as by AddMetrics
+func (n *Node) AddNamedMetrics(metrics []NamedMetric) error {
+	return n.addMetrics(namedMetrics(metrics), time.Now())
+}
+
+// QueryMetrics returns metrics of given name reported between from and to,
+// labels are only matched by named metrics
+func (n *Node) QueryMetrics(metricType MetricType, labels Labels, from, to time.Time) []Metric {
+	return n.Metrics.Query(metricType, labels, from, to)
 }
 
 // CalculateMetricValue calculates avg of requested metric
 func (n *Node) CalculateMetricValue(metricType MetricType, from, to time.Time) float64 {
-	rez := 0.0
-	value := 0.0
-	dataPoints := 0
-
-	for t, m := range n.Metrics {
-		if isRequiredMetric(m, metricType) {
-			if t.After(from) && t.Before(to) {
-				fmt.Printf("Metric:[%s] [%v] [%v]<[%v]<[%v]\n", n.ID, m.GetValue(), from, m.GetTimestamp(), to)
-				value = value + m.GetValue()
-				dataPoints = dataPoints + 1
-			}
-		}
-	}
-
-	if value > 0 {
-		rez = value / float64(dataPoints)
-	}
-	fmt.Printf("Rez:[%s] [%v]\n", n.ID, rez)
-	return round(rez, .5, 2)
+	return round(n.AggregateMetric(metricType, nil, nil, from, to), .5, 2)
 }
 
-// clearMetrics removes metrics that are older than n.KeepMetricFor
-func (n *Node) clearMetrics() error {
-	requiredTime := time.Now().Add(n.KeepMetricFor)
-	for t, _ := range n.Metrics {
-		if t.Before(requiredTime) {
-			delete(n.Metrics, t)
-		}
-	}
-
-	return nil
+// AggregateMetric aggregates metrics of given name and labels reported between
+// from and to, without aggregator avg is calculated
+func (n *Node) AggregateMetric(metricType MetricType, labels Labels, aggregator *Aggregator, from, to time.Time) float64 {
+	return aggregator.Aggregate(n.QueryMetrics(metricType, labels, from, to))
 }
 
 func round(val float64, roundOn float64, places int) (newVal float64) {
@@ -43,7 +43,7 @@ func (s *NodeSuite) TestIfNodeMetricsAreAddedCorrectly(c *C) {
 
 	node.AddMetrics(healthMetricSeries)
 
-	c.Assert(len(node.Metrics), Equals, 60)
+	c.Assert(node.Metrics.Len(), Equals, 60)
 }
 
 func (s *NodeSuite) TestIfNodeMetricsAreCleared(c *C) {
@@ -86,7 +86,63 @@ func (s *NodeSuite) TestIfNodeMetricsAreCleared(c *C) {
 
 	node.AddMetrics(healthMetricSeries)
 
-	for _, m := range node.Metrics {
+	for _, m := range node.QueryMetrics(HealthMetricType, nil, time.Time{}, now.Add(time.Second)) {
 		c.Assert(m.GetTimestamp().After(required), Equals, true)
 	}
 }
+
+func (s *NodeSuite) TestIfNodeIsStaleAfterHeartbeatTimeout(c *C) {
+	node := NewNode()
+	node.Setup(ID("node1"), Provider{ID: DigitalOcean}, NetworkInterface{}, NetworkInterface{})
+
+	now := time.Now()
+	c.Assert(node.IsStale(0, now.Add(time.Hour)), Equals, false)
+
+	// Never reported, measured from launch
+	c.Assert(node.IsStale(time.Minute, now), Equals, false)
+	c.Assert(node.IsStale(time.Minute, now.Add(2*time.Minute)), Equals, true)
+
+	node.RecordReport(now.Add(2 * time.Minute))
+	c.Assert(node.IsStale(time.Minute, now.Add(2*time.Minute)), Equals, false)
+	c.Assert(node.LastReportedAt, Equals, now.Add(2*time.Minute))
+
+	// Older report does not move it back
+	node.RecordReport(now)
+	c.Assert(node.LastReportedAt, Equals, now.Add(2*time.Minute))
+
+	// Node has reported recently, but no health data in window
+	c.Assert(node.IsMissingData(time.Minute, now.Add(time.Minute), now.Add(2*time.Minute)), Equals, true)
+	node.AddMetrics(NewMetricSeries(NewHealthMetric(0, now.Add(90*time.Second))))
+	c.Assert(node.IsMissingData(time.Minute, now.Add(time.Minute), now.Add(2*time.Minute)), Equals, false)
+}
+
+func (s *NodeSuite) TestIfNamedMetricsCanBeQueriedByNameAndLabels(c *C) {
+	node := prepareLocalNode(ID("node1"))
+
+	now := time.Now()
+	t := now.Add(-10 * time.Second)
+	node.AddNamedMetrics([]NamedMetric{
+		NewNamedMetric(HealthMetricType, "", nil, 1, t),
+		NewNamedMetric("cpu", "percent", Labels{"core": "0"}, 40, t),
+		NewNamedMetric("cpu", "percent", Labels{"core": "1"}, 80, t),
+		NewNamedMetric("memory", "bytes", nil, 1024, t),
+	})
+
+	from := now.Add(-time.Minute)
+	c.Assert(len(node.QueryMetrics("cpu", nil, from, now)), Equals, 2)
+	c.Assert(len(node.QueryMetrics("cpu", Labels{"core": "1"}, from, now)), Equals, 1)
+	c.Assert(node.QueryMetrics("memory", nil, from, now)[0].(NamedMetric).Unit, Equals, "bytes")
+	c.Assert(node.CalculateMetricValue("cpu", from, now), Equals, 60.0)
+
+	// Health keeps working as before
+	c.Assert(node.Metrics.Len(), Equals, 4)
+	c.Assert(node.CalculateMetricValue(HealthMetricType, from, now), Equals, 1.0)
+	c.Assert(len(node.QueryMetrics(HealthMetricType, nil, from, now)), Equals, 1)
+}
+
+func (s *NodeSuite) TestIfDeprecatedActiveStateIsInService(c *C) {
+	node := NewNode()
+	node.ChangeState(NodeStateActive)
+	c.Assert(node.State, Equals, NodeStateInService)
+	c.Assert(node.State.String(), Equals, "InService")
+}
@@ -0,0 +1,121 @@
+package domain
+
+import (
+	"sort"
+
+	"github.com/juju/errors"
+)
+
+type (
+	// PlannedCommand is command which would be executed on next cycle
+	PlannedCommand struct {
+		Order   Order
+		Command Command
+		Reason  string
+	}
+)
+
+// Plan evaluates policies of ASG against copy of its nodes and returns commands
+// which next cycle would execute. Neither ASG, its nodes nor policies are changed.
+// If proposed policy is given, it is applied the same way as ChangePolicy does,
+// or added if ASG has no policy with the same ID. ASG is planned between its
+// cycles, while nothing is being changed
+func (asg *AutoScalingGroup) Plan(proposed Policy) ([]PlannedCommand, error) {
+	asg.cycle.Lock()
+	defer asg.cycle.Unlock()
+
+	if asg.State == ASGStateNew {
+		return nil, errors.Errorf("ASG is in ASGStateNew state, use Setup() first!")
+	}
+
+	shadow := asg.planningCopy()
+	if proposed != nil {
+		if _, ok := shadow.Policies[proposed.GetID()]; ok {
+			if err := shadow.Policies.Update(proposed); err != nil {
+				return nil, errors.Trace(err)
+			}
+		} else {
+			shadow.Policies[proposed.GetID()] = proposed.Clone()
+		}
+	}
+
+	if err := shadow.Evaluate(); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	keys := []int{}
+	for k := range shadow.Commands {
+		keys = append(keys, int(k))
+	}
+	sort.Ints(keys)
+
+	planned := []PlannedCommand{}
+	for _, k := range keys {
+		cmd := shadow.Commands[Order(k)]
+		pc := PlannedCommand{Order: Order(k), Command: cmd}
+		if r, ok := cmd.(interface {
+			GetReason() string
+		}); ok {
+			pc.Reason = r.GetReason()
+		}
+
+		if p, ok := shadow.suspendedProcessOf(cmd); ok {
+			pc.Reason = pc.Reason + ", but process " + string(p) + " is suspended"
+		}
+		planned = append(planned, pc)
+	}
+
+	return planned, nil
+}
+
+// planningCopy returns ASG which shares settings with this one, but has its
+// own copy of everything evaluation changes
+func (asg *AutoScalingGroup) planningCopy() *AutoScalingGroup {
+	shadow := &AutoScalingGroup{
+		ID:                     asg.ID,
+		State:                  ASGStateActive,
+		Nodes:                  NewNodeSet(),
+		Policies:               NewPolicySet(),
+		Commands:               NewCommandSet(),
+		Metrics:                asg.Metrics,
+		MetricRetention:        asg.MetricRetention,
+		Cooldown:               asg.Cooldown,
+		LastScalingActivity:    asg.LastScalingActivity,
+		WarmPool:               asg.WarmPool,
+		HealthCheckGracePeriod: asg.HealthCheckGracePeriod,
+		HeartbeatTimeout:       asg.HeartbeatTimeout,
+		HealthChecks:           asg.HealthChecks,
+		Scrapers:               asg.Scrapers,
+		HealthModel:            asg.HealthModel,
+		Healing:                asg.Healing,
+		Hooks:                  asg.Hooks,
+		Breaker:                asg.Breaker,
+		Guard:                  asg.Guard,
+		GlobalGuard:            asg.GlobalGuard,
+		Rollout:                asg.Rollout,
+		Clock:                  asg.Clock,
+		Log:                    asg.Log,
+		planning:               true,
+		suspended:              map[Process]Suspension{},
+		replacing:              asg.replacing,
+		healingNodes:           asg.healingNodes,
+	}
+
+	for id, node := range asg.Nodes {
+		shadow.Nodes[id] = node.clone()
+	}
+
+	for id, policy := range asg.Policies {
+		shadow.Policies[id] = policy.Clone()
+	}
+
+	for order, cmd := range asg.Commands {
+		shadow.Commands[order] = cmd
+	}
+
+	for _, s := range asg.Suspensions() {
+		shadow.suspended[s.Process] = s
+	}
+
+	return shadow
+}
@@ -0,0 +1,111 @@
+package domain
+
+import (
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type PlanSuite struct{}
+
+var _ = Suite(&PlanSuite{})
+
+func (s *PlanSuite) TestIfPlanDoesNotChangeASGNorPolicies(c *C) {
+	node := prepareLocalNode(ID("node1"))
+	node.AddMetrics(prepareMetrics(5, 5))
+
+	plc, err := NewDesiredNodeAmountPerProviderPolicy(ID("policy-1"), 1, 2, 1, 3, 0.7, time.Duration(-5*time.Second), Provider{ID: testProviderID})
+	c.Assert(err, IsNil)
+
+	asg := NewAutoScalingGroup(ID("asg-1"))
+	asg.Setup(NewNodeSet(node), NewPolicySet(plc))
+
+	// Node has failed only once, it is not replaced yet
+	planned, err := asg.Plan(nil)
+	c.Assert(err, IsNil)
+	c.Assert(len(planned), Equals, 0)
+
+	// With stricter policy it would be
+	proposed, err := NewDesiredNodeAmountPerProviderPolicy(ID("policy-1"), 1, 2, 2, 1, 0.7, time.Duration(-5*time.Second), Provider{ID: testProviderID})
+	c.Assert(err, IsNil)
+
+	planned, err = asg.Plan(proposed)
+	c.Assert(err, IsNil)
+	c.Assert(len(planned), Equals, 2)
+	c.Assert(planned[0].Order, Equals, Order(1))
+	c.Assert(planned[0].Command, FitsTypeOf, &Relaunch{})
+	c.Assert(planned[0].Reason, Equals, "Node [node1] has failed 1 consecutive health checks")
+	c.Assert(planned[1].Command, FitsTypeOf, &Launch{})
+	c.Assert(planned[1].Reason, Equals, "Healthy nodes 0 are less than desired 2")
+
+	c.Assert(len(asg.Commands), Equals, 0)
+	c.Assert(node.State, Equals, NodeStatePending)
+	c.Assert(plc.(*DesiredHealthyNodeAmountPerProviderPolicy).Desired, Equals, 1)
+	c.Assert(plc.(*DesiredHealthyNodeAmountPerProviderPolicy).ConsecutiveChecksNum, DeepEquals, map[ID]int{})
+
+	// Real evaluation is not affected by planning
+	err = asg.Evaluate()
+	c.Assert(err, IsNil)
+	c.Assert(len(asg.Commands), Equals, 0)
+	c.Assert(plc.(*DesiredHealthyNodeAmountPerProviderPolicy).ConsecutiveChecksNum, DeepEquals, map[ID]int{ID("node1"): 1})
+}
+
+func (s *PlanSuite) TestIfPlanWaitsForCycleOfASG(c *C) {
+	registerTestDriver()
+	node := prepareLocalNode(ID("node1"))
+	node.AddMetrics(prepareMetrics(5, 5))
+
+	plc, err := NewDesiredNodeAmountPerProviderPolicy(ID("policy-1"), 1, 2, 1, 100, 0.7, time.Duration(-5*time.Second), Provider{ID: testProviderID})
+	c.Assert(err, IsNil)
+
+	asg := NewAutoScalingGroup(ID("asg-1"))
+	asg.Setup(NewNodeSet(node), NewPolicySet(plc))
+
+	done := make(chan error, 1)
+	go func() {
+		for i := 0; i < 50; i++ {
+			if err := asg.runCycle(); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+
+	for {
+		select {
+		case err := <-done:
+			c.Assert(err, IsNil)
+			c.Assert(plc.(*DesiredHealthyNodeAmountPerProviderPolicy).ConsecutiveChecksNum, DeepEquals, map[ID]int{ID("node1"): 50})
+			return
+		default:
+			_, err := asg.Plan(nil)
+			c.Assert(err, IsNil)
+		}
+	}
+}
+
+func (s *PlanSuite) TestIfPlanAddsProposedPolicyAndShowsSuspendedProcesses(c *C) {
+	asg := NewAutoScalingGroup(ID("asg-1"))
+	asg.Setup(NewNodeSet(), NewPolicySet())
+	asg.AddGroupMetrics(prepareBacklog(250, 5))
+
+	c.Assert(asg.SuspendProcess(ProcessLaunch, "incident"), IsNil)
+
+	proposed, err := NewBacklogPerInstancePolicy(ID("backlog"), 0, 5, 100, time.Duration(-5*time.Second), Provider{ID: testProviderID})
+	c.Assert(err, IsNil)
+
+	planned, err := asg.Plan(proposed)
+	c.Assert(err, IsNil)
+	c.Assert(len(planned), Equals, 3)
+	c.Assert(planned[2].Reason, Equals, "Backlog 250.00 per node needs 3 nodes, current 0, but process Launch is suspended")
+	c.Assert(len(asg.Policies), Equals, 0)
+
+	// Policy of another type can not replace existing one
+	plc, err := NewDesiredNodeAmountPerProviderPolicy(ID("backlog"), 1, 1, 1, 1, 0.7, time.Duration(-5*time.Second), Provider{ID: testProviderID})
+	c.Assert(err, IsNil)
+	asg.Policies = NewPolicySet(plc)
+
+	_, err = asg.Plan(proposed)
+	c.Assert(err, NotNil)
+}
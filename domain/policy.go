@@ -1,6 +1,7 @@
 package domain
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/juju/errors"
@@ -16,6 +17,14 @@ type (
 		Evaluate(*AutoScalingGroup) error
 		Update(Policy) error
 		GetID() ID
+		// Clone returns copy of policy together with its state
+		Clone() Policy
+	}
+
+	// CapacityPolicy is a policy which desired capacity can be changed directly
+	CapacityPolicy interface {
+		Policy
+		SetDesiredCapacity(int) error
 	}
 
 	// DesiredNodeAmountPerProviderPolicy evaluates current state and creates Commands per provider
@@ -27,6 +36,9 @@ type (
 		Provider                   Provider
 		ConsecutiveChecks          int
 		ConsecutiveChecksNum       map[ID]int
+		MissingData                MissingDataTreatment
+		// Aggregator of node health within CheckInterval, avg if not set
+		Aggregator *Aggregator
 	}
 )
 
@@ -69,6 +81,7 @@ func NewDesiredNodeAmountPerProviderPolicy(id ID, min, max, desired, consecutive
 		CheckInterval:        checkInterval,
 		Provider:             provider,
 		ConsecutiveChecks:    consecutiveChecks,
+		MissingData:          MissingDataBreaching,
 	}, nil
 }
 
@@ -91,6 +104,45 @@ func (dsp *DesiredHealthyNodeAmountPerProviderPolicy) Update(plc Policy) error {
 	dsp.Provider = v.Provider
 	dsp.ConsecutiveChecks = v.ConsecutiveChecks
 	dsp.ConsecutiveChecksNum = map[ID]int{}
+	dsp.MissingData = v.MissingData
+	dsp.Aggregator = v.Aggregator
+
+	return nil
+}
+
+// Clone policy together with consecutive checks of nodes
+func (dsp *DesiredHealthyNodeAmountPerProviderPolicy) Clone() Policy {
+	clone := *dsp
+	clone.ConsecutiveChecksNum = map[ID]int{}
+	for id, num := range dsp.ConsecutiveChecksNum {
+		clone.ConsecutiveChecksNum[id] = num
+	}
+
+	return &clone
+}
+
+// SetMissingData defines how nodes without data are treated, by default they are breaching
+func (dsp *DesiredHealthyNodeAmountPerProviderPolicy) SetMissingData(treatment MissingDataTreatment) error {
+	switch treatment {
+	case MissingDataBreaching, MissingDataNotBreaching, MissingDataIgnore:
+		dsp.MissingData = treatment
+		return nil
+	}
+
+	return errors.Errorf("Missing data treatment [%s] is not supported", treatment)
+}
+
+// SetDesiredCapacity changes desired amount of nodes, unlike Update checks state is kept
+func (dsp *DesiredHealthyNodeAmountPerProviderPolicy) SetDesiredCapacity(desired int) error {
+	if desired > dsp.Max {
+		return errors.Errorf("Desired %d can not be more than max %d", desired, dsp.Max)
+	}
+
+	if desired < dsp.Min {
+		return errors.Errorf("Desired %d can not be less than min %d", desired, dsp.Min)
+	}
+
+	dsp.Desired = desired
 
 	return nil
 }
@@ -99,7 +151,8 @@ func (dsp *DesiredHealthyNodeAmountPerProviderPolicy) Update(plc Policy) error {
 func (dsp *DesiredHealthyNodeAmountPerProviderPolicy) Evaluate(asg *AutoScalingGroup) error {
 
 	dsp.Current = 0
-	dsp.countCurrent(asg.Nodes)
+	dsp.countCurrent(asg)
+	frozen := asg.replacementsFrozen() || asg.IsSuspended(ProcessReplaceUnhealthy)
 
 	if dsp.Current == dsp.Desired {
 		return nil
@@ -113,16 +166,17 @@ func (dsp *DesiredHealthyNodeAmountPerProviderPolicy) Evaluate(asg *AutoScalingG
 		handled := 0
 		for nodeID, v := range dsp.ConsecutiveChecksNum {
 			// Relaunch those nodes which has failed checks
-			if v == dsp.ConsecutiveChecks {
-				commandOrder++
-				asg.Commands[Order(commandOrder)] = &Relaunch{
-					BaseCommand: BaseCommand{
-						Provider: dsp.Provider,
-					},
-					NodeID: nodeID,
+			if v >= dsp.ConsecutiveChecks {
+				// Failing nodes are neither replaced nor compensated while frozen
+				if !frozen {
+					commandOrder++
+					asg.Commands[Order(commandOrder)] = dsp.replace(asg, nodeID)
+
+					// In shadow mode node is not replaced, so it keeps its failed checks
+					if asg.Shadow == nil {
+						delete(dsp.ConsecutiveChecksNum, nodeID)
+					}
 				}
-
-				delete(dsp.ConsecutiveChecksNum, nodeID)
 				handled++
 			}
 		}
@@ -135,6 +189,7 @@ func (dsp *DesiredHealthyNodeAmountPerProviderPolicy) Evaluate(asg *AutoScalingG
 				asg.Commands[Order(commandOrder)] = &Launch{
 					BaseCommand: BaseCommand{
 						Provider: dsp.Provider,
+						Reason:   fmt.Sprintf("Healthy nodes %d are less than desired %d", dsp.Current, dsp.Desired),
 					},
 				}
 			}
@@ -146,14 +201,20 @@ func (dsp *DesiredHealthyNodeAmountPerProviderPolicy) Evaluate(asg *AutoScalingG
 		amt := dsp.Current - dsp.Desired
 
 		handled := 0
-		for nodeID := range asg.Nodes {
+		for nodeID, node := range asg.Nodes {
 			if handled == amt {
 				break
 			}
+
+			if asg.inTransition(node) {
+				continue
+			}
+
 			commandOrder++
 			asg.Commands[Order(commandOrder)] = &Terminate{
 				BaseCommand: BaseCommand{
 					Provider: dsp.Provider,
+					Reason:   fmt.Sprintf("Healthy nodes %d are more than desired %d", dsp.Current, dsp.Desired),
 				},
 				NodeID: nodeID,
 			}
@@ -164,8 +225,31 @@ func (dsp *DesiredHealthyNodeAmountPerProviderPolicy) Evaluate(asg *AutoScalingG
 	return nil
 }
 
-func (dsp *DesiredHealthyNodeAmountPerProviderPolicy) countCurrent(nodes NodeSet) error {
-	for _, node := range nodes {
+// replace returns command which heals node if ASG has healing ladder, otherwise relaunches it
+func (dsp *DesiredHealthyNodeAmountPerProviderPolicy) replace(asg *AutoScalingGroup, nodeID ID) Command {
+	reason := fmt.Sprintf("Node [%s] has failed %d consecutive health checks", nodeID, dsp.ConsecutiveChecksNum[nodeID])
+	if asg.Healing != nil {
+		return &Heal{
+			BaseCommand: BaseCommand{
+				Provider: dsp.Provider,
+				Reason:   reason,
+			},
+			NodeID:           nodeID,
+			HealthyThreshold: dsp.HealthyThreshold,
+		}
+	}
+
+	return &Relaunch{
+		BaseCommand: BaseCommand{
+			Provider: dsp.Provider,
+			Reason:   reason,
+		},
+		NodeID: nodeID,
+	}
+}
+
+func (dsp *DesiredHealthyNodeAmountPerProviderPolicy) countCurrent(asg *AutoScalingGroup) error {
+	for _, node := range asg.Nodes {
 		if _, ok := dsp.ConsecutiveChecksNum[node.ID]; !ok {
 			dsp.ConsecutiveChecksNum[node.ID] = 0
 		}
@@ -174,21 +258,69 @@ func (dsp *DesiredHealthyNodeAmountPerProviderPolicy) countCurrent(nodes NodeSet
 			continue
 		}
 
-		now := time.Now()
+		// Nodes waiting for hooks or healing are not judged, launched and
+		// healed ones count toward capacity
+		if asg.inTransition(node) {
+			if node.State == NodeStatePendingWait || asg.healingNodes[node.ID] != nil {
+				dsp.Current++
+			}
+			continue
+		}
+
+		// Nodes keep their state while health is not judged
+		if asg.IsSuspended(ProcessHealthCheck) {
+			if node.State != NodeStateUnhealthy {
+				dsp.Current++
+			}
+			continue
+		}
+
+		now := asg.now()
 		before := now.Add(dsp.CheckInterval)
-		val := node.CalculateMetricValue(HealthMetricType, before, now)
 
-		if val >= dsp.HealthyThreshold {
-			node.ChangeState(NodeStateActive)
+		healthy := asg.isHealthy(node, dsp.HealthyThreshold, dsp.Aggregator, before, now)
+		node.MissingData = node.IsMissingData(asg.HeartbeatTimeout, before, now)
+
+		if !healthy && node.MissingData && !node.InGracePeriodAt(asg.HealthCheckGracePeriod, now) {
+			switch dsp.MissingData {
+			case MissingDataNotBreaching:
+				healthy = true
+			case MissingDataIgnore:
+				// Keep checks as they are, node which was not failing yet still counts
+				if node.State != NodeStateUnhealthy {
+					dsp.Current++
+				}
+				continue
+			}
+		}
+
+		if healthy {
+			if node.State == NodeStatePending {
+				asg.launchSucceeded(node.ID)
+			}
+			node.ChangeState(NodeStateInService)
 			dsp.Current++
 			// reset
 			dsp.ConsecutiveChecksNum[node.ID] = 0
+		} else if node.InGracePeriodAt(asg.HealthCheckGracePeriod, now) {
+			// New node counts toward capacity but is not judged yet
+			dsp.Current++
+			dsp.ConsecutiveChecksNum[node.ID] = 0
 		} else {
 			dsp.ConsecutiveChecksNum[node.ID]++
-			node.ChangeState(NodeStateUnhealthy)
 			if dsp.ConsecutiveChecksNum[node.ID] < dsp.ConsecutiveChecks {
 				dsp.Current++
-				node.ChangeState(NodeStateActive)
+				// Node which has never been healthy stays pending, unhealthy
+				// one stays unhealthy until it is healthy again
+				if node.State != NodeStatePending && node.State != NodeStateUnhealthy {
+					node.ChangeState(NodeStateInService)
+				}
+			} else {
+				// Node which has never become healthy is failed launch
+				if node.State == NodeStatePending {
+					asg.launchFailed(node.ID, fmt.Sprintf("Node [%s] has never become healthy", node.ID))
+				}
+				node.ChangeState(NodeStateUnhealthy)
 			}
 		}
 	}
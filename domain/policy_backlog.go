@@ -0,0 +1,194 @@
+package domain
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+type (
+	// BacklogPerInstancePolicy scales ASG so that every in-service node has to
+	// handle at most AcceptableBacklog of work. Backlog is read from metrics
+	// reported for ASG as a whole with BacklogMetricType
+	BacklogPerInstancePolicy struct {
+		ID                ID
+		Min, Max, Desired int
+		Current           int
+		AcceptableBacklog float64
+		BacklogPerNode    float64
+		CheckInterval     time.Duration
+		Provider          Provider
+		// Aggregator of backlog within CheckInterval, avg if not set
+		Aggregator *Aggregator
+	}
+)
+
+// NewBacklogPerInstancePolicy constructor
+func NewBacklogPerInstancePolicy(id ID, min, max int, acceptableBacklog float64, checkInterval time.Duration, provider Provider) (Policy, error) {
+	if min < 0 {
+		return nil, errors.Errorf("Min %d can not be less than 0", min)
+	}
+
+	if min > max {
+		return nil, errors.Errorf("Min %d can not be more than max %d", min, max)
+	}
+
+	if acceptableBacklog <= 0 {
+		return nil, errors.Errorf("AcceptableBacklog %v can not be less or equal to 0", acceptableBacklog)
+	}
+
+	return &BacklogPerInstancePolicy{
+		ID:                id,
+		Min:               min,
+		Max:               max,
+		Desired:           min,
+		Current:           0,
+		AcceptableBacklog: acceptableBacklog,
+		CheckInterval:     checkInterval,
+		Provider:          provider,
+	}, nil
+}
+
+// GetID ...
+func (bp *BacklogPerInstancePolicy) GetID() ID {
+	return bp.ID
+}
+
+// Clone policy together with calculated capacity
+func (bp *BacklogPerInstancePolicy) Clone() Policy {
+	clone := *bp
+	return &clone
+}
+
+// Update policy settings, calculated desired capacity is kept within new limits
+func (bp *BacklogPerInstancePolicy) Update(plc Policy) error {
+	v, ok := plc.(*BacklogPerInstancePolicy)
+	if !ok {
+		return errors.Errorf("Given policy is not *BacklogPerInstancePolicy")
+	}
+
+	bp.Min = v.Min
+	bp.Max = v.Max
+	bp.AcceptableBacklog = v.AcceptableBacklog
+	bp.CheckInterval = v.CheckInterval
+	bp.Provider = v.Provider
+	bp.Aggregator = v.Aggregator
+	bp.Desired = clamp(bp.Desired, bp.Min, bp.Max)
+
+	return nil
+}
+
+// Evaluate backlog and create commands to reach desired capacity
+func (bp *BacklogPerInstancePolicy) Evaluate(asg *AutoScalingGroup) error {
+	bp.Current = bp.countCurrent(asg.Nodes)
+
+	from, to := checkWindow(bp.CheckInterval, asg.now())
+	backlog := asg.QueryGroupMetrics(BacklogMetricType, nil, from, to)
+
+	// Without data we can not tell if there is any work, so keep what we have
+	// as long as it is within limits
+	if len(backlog) == 0 {
+		bp.Desired = clamp(bp.Current, bp.Min, bp.Max)
+	} else {
+		total := bp.Aggregator.Aggregate(backlog)
+
+		bp.BacklogPerNode = total
+		if bp.Current > 0 {
+			bp.BacklogPerNode = total / float64(bp.Current)
+		}
+
+		bp.Desired = clamp(int(math.Ceil(total/bp.AcceptableBacklog)), bp.Min, bp.Max)
+	}
+
+	if bp.Current == bp.Desired {
+		return nil
+	}
+
+	commandOrder := len(asg.Commands)
+	if bp.Current < bp.Desired {
+		for i := 0; i < bp.Desired-bp.Current; i++ {
+			commandOrder++
+			asg.Commands[Order(commandOrder)] = &Launch{
+				BaseCommand: BaseCommand{
+					Provider: bp.Provider,
+					Reason:   bp.reason(),
+				},
+			}
+		}
+	}
+
+	if bp.Current > bp.Desired {
+		amt := bp.Current - bp.Desired
+
+		handled := 0
+		for nodeID, node := range asg.Nodes {
+			if handled == amt {
+				break
+			}
+
+			if !bp.isInService(node) || asg.inTransition(node) {
+				continue
+			}
+
+			commandOrder++
+			asg.Commands[Order(commandOrder)] = &Terminate{
+				BaseCommand: BaseCommand{
+					Provider: bp.Provider,
+					Reason:   bp.reason(),
+				},
+				NodeID: nodeID,
+			}
+			handled++
+		}
+	}
+
+	return nil
+}
+
+// reason of capacity change
+func (bp *BacklogPerInstancePolicy) reason() string {
+	return fmt.Sprintf("Backlog %.2f per node needs %d nodes, current %d", bp.BacklogPerNode, bp.Desired, bp.Current)
+}
+
+func (bp *BacklogPerInstancePolicy) countCurrent(nodes NodeSet) int {
+	current := 0
+	for _, node := range nodes {
+		if bp.isInService(node) {
+			current++
+		}
+	}
+
+	return current
+}
+
+func (bp *BacklogPerInstancePolicy) isInService(node *Node) bool {
+	if node.Provider.ID != bp.Provider.ID {
+		return false
+	}
+
+	return node.State != NodeStateTerminated && node.State != NodeStateDeleted && node.State != NodeStateTerminatingWait
+}
+
+// checkWindow returns time range of last interval until now, interval can be
+// given either as negative or positive duration
+func checkWindow(interval time.Duration, now time.Time) (time.Time, time.Time) {
+	if interval > 0 {
+		interval = interval * -1
+	}
+
+	return now.Add(interval), now
+}
+
+func clamp(val, min, max int) int {
+	if val < min {
+		return min
+	}
+
+	if val > max {
+		return max
+	}
+
+	return val
+}
@@ -98,7 +98,162 @@ func (s *PolicySuite) TestIfDesiredNodeAmountPerProviderPolicyEvaluatesCorrectly
 				ID:     DigitalOcean,
 				APIKey: "some-key",
 			},
+			Reason: "Node [node1] has failed 1 consecutive health checks",
 		},
 		NodeID: ID("node1"),
 	})
 }
+
+func prepareBacklog(value float64, period int) MetricSeries {
+	now := time.Now()
+	backlogMetricSeries := NewMetricSeries()
+	for i := 0; i < period; i++ {
+		b := now.Add(time.Duration(time.Second * time.Duration(-1*i)))
+		backlogMetricSeries[b] = NewBacklogMetric(value, b)
+	}
+
+	return backlogMetricSeries
+}
+
+func (s *PolicySuite) TestIfBacklogPerInstancePolicyScalesOutByBacklog(c *C) {
+	asg := NewAutoScalingGroup(ID("test"))
+	asg.Setup(NewNodeSet(prepareNode(0, ID("node1"), 5), prepareNode(0, ID("node2"), 5)), PolicySet{})
+
+	plc, err := NewBacklogPerInstancePolicy(ID("policy-1"), 0, 10, 10, time.Duration(-5*time.Second), Provider{
+		ID:     DigitalOcean,
+		APIKey: "some-key",
+	})
+	c.Assert(err, IsNil)
+
+	err = asg.AddGroupMetrics(prepareBacklog(45, 5))
+	c.Assert(err, IsNil)
+
+	err = plc.Evaluate(asg)
+	c.Assert(err, IsNil)
+
+	c.Assert(plc.(*BacklogPerInstancePolicy).Desired, Equals, 5)
+	c.Assert(plc.(*BacklogPerInstancePolicy).BacklogPerNode, Equals, 22.5)
+	c.Assert(len(asg.Commands), Equals, 3)
+	for _, cmd := range asg.Commands {
+		c.Assert(cmd, FitsTypeOf, &Launch{})
+	}
+}
+
+func (s *PolicySuite) TestIfBacklogPerInstancePolicyScalesToZero(c *C) {
+	asg := NewAutoScalingGroup(ID("test"))
+	asg.Setup(NewNodeSet(prepareNode(0, ID("node1"), 5), prepareNode(0, ID("node2"), 5)), PolicySet{})
+
+	plc, err := NewBacklogPerInstancePolicy(ID("policy-1"), 0, 10, 10, time.Duration(-5*time.Second), Provider{
+		ID:     DigitalOcean,
+		APIKey: "some-key",
+	})
+	c.Assert(err, IsNil)
+
+	// No backlog reported, nothing should change
+	err = plc.Evaluate(asg)
+	c.Assert(err, IsNil)
+	c.Assert(len(asg.Commands), Equals, 0)
+
+	err = asg.AddGroupMetrics(prepareBacklog(0, 5))
+	c.Assert(err, IsNil)
+
+	err = plc.Evaluate(asg)
+	c.Assert(err, IsNil)
+	c.Assert(len(asg.Commands), Equals, 2)
+	for _, cmd := range asg.Commands {
+		c.Assert(cmd, FitsTypeOf, &Terminate{})
+	}
+}
+
+func (s *PolicySuite) TestIfBacklogPerInstancePolicyValidatesLimits(c *C) {
+	_, err := NewBacklogPerInstancePolicy(ID("policy-1"), 2, 1, 10, time.Duration(-5*time.Second), Provider{})
+	c.Assert(err, NotNil)
+
+	_, err = NewBacklogPerInstancePolicy(ID("policy-1"), 0, 1, 0, time.Duration(-5*time.Second), Provider{})
+	c.Assert(err, NotNil)
+}
+
+func (s *PolicySuite) TestIfNewNodeIsNotJudgedDuringGracePeriod(c *C) {
+	provider := Provider{
+		ID:     DigitalOcean,
+		APIKey: "some-key",
+	}
+
+	// Node has not reported anything yet
+	node := prepareNode(0, ID("node1"), 0)
+	asg := NewAutoScalingGroup(ID("test"))
+	asg.Setup(NewNodeSet(node), PolicySet{})
+	asg.HealthCheckGracePeriod = time.Minute
+
+	plc, err := NewDesiredNodeAmountPerProviderPolicy(ID("policy-1"), 1, 1, 1, 1, 0.9, time.Duration(-5*time.Second), provider)
+	c.Assert(err, IsNil)
+
+	err = plc.Evaluate(asg)
+	c.Assert(err, IsNil)
+	c.Assert(len(asg.Commands), Equals, 0)
+	c.Assert(node.State, Equals, NodeStatePending)
+
+	// First healthy report moves it in service
+	node.AddMetrics(prepareMetrics(0, 5))
+	err = plc.Evaluate(asg)
+	c.Assert(err, IsNil)
+	c.Assert(len(asg.Commands), Equals, 0)
+	c.Assert(node.State, Equals, NodeStateInService)
+
+	// Without grace period the same node would be relaunched right away
+	node = prepareNode(0, ID("node1"), 0)
+	asg = NewAutoScalingGroup(ID("test"))
+	asg.Setup(NewNodeSet(node), PolicySet{})
+
+	plc, err = NewDesiredNodeAmountPerProviderPolicy(ID("policy-1"), 1, 1, 1, 1, 0.9, time.Duration(-5*time.Second), provider)
+	c.Assert(err, IsNil)
+
+	err = plc.Evaluate(asg)
+	c.Assert(err, IsNil)
+	c.Assert(len(asg.Commands), Equals, 1)
+	c.Assert(node.State, Equals, NodeStateUnhealthy)
+}
+
+func (s *PolicySuite) TestIfMissingDataIsTreatedAsConfigured(c *C) {
+	provider := Provider{
+		ID:     DigitalOcean,
+		APIKey: "some-key",
+	}
+
+	evaluate := func(treatment MissingDataTreatment, fail int, period int) (*AutoScalingGroup, *Node) {
+		node := prepareNode(fail, ID("node1"), period)
+		node.ChangeState(NodeStateInService)
+		asg := NewAutoScalingGroup(ID("test"))
+		asg.Setup(NewNodeSet(node), PolicySet{})
+
+		plc, err := NewDesiredNodeAmountPerProviderPolicy(ID("policy-1"), 1, 1, 1, 1, 0.9, time.Duration(-5*time.Second), provider)
+		c.Assert(err, IsNil)
+		c.Assert(plc.(*DesiredHealthyNodeAmountPerProviderPolicy).SetMissingData(treatment), IsNil)
+
+		c.Assert(plc.Evaluate(asg), IsNil)
+		return asg, node
+	}
+
+	// No data at all
+	asg, node := evaluate(MissingDataBreaching, 0, 0)
+	c.Assert(len(asg.Commands), Equals, 1)
+	c.Assert(node.MissingData, Equals, true)
+	c.Assert(node.State, Equals, NodeStateUnhealthy)
+
+	asg, node = evaluate(MissingDataNotBreaching, 0, 0)
+	c.Assert(len(asg.Commands), Equals, 0)
+	c.Assert(node.State, Equals, NodeStateInService)
+
+	asg, node = evaluate(MissingDataIgnore, 0, 0)
+	c.Assert(len(asg.Commands), Equals, 0)
+	c.Assert(node.MissingData, Equals, true)
+
+	// Node reports that it is unhealthy, this is not missing data
+	asg, node = evaluate(MissingDataNotBreaching, 5, 5)
+	c.Assert(len(asg.Commands), Equals, 1)
+	c.Assert(node.MissingData, Equals, false)
+	c.Assert(node.State, Equals, NodeStateUnhealthy)
+
+	plc, _ := NewDesiredNodeAmountPerProviderPolicy(ID("policy-1"), 1, 1, 1, 1, 0.9, time.Duration(-5*time.Second), provider)
+	c.Assert(plc.(*DesiredHealthyNodeAmountPerProviderPolicy).SetMissingData(MissingDataTreatment("maybe")), NotNil)
+}
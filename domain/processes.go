@@ -0,0 +1,170 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+const (
+	// ProcessLaunch adds nodes, relaunch and warm pool refill need it as well
+	ProcessLaunch = Process("Launch")
+	// ProcessTerminate removes nodes, relaunch needs it as well
+	ProcessTerminate = Process("Terminate")
+	// ProcessHealthCheck runs health checks and judges node health
+	ProcessHealthCheck = Process("HealthCheck")
+	// ProcessReplaceUnhealthy relaunches or heals unhealthy nodes
+	ProcessReplaceUnhealthy = Process("ReplaceUnhealthy")
+	// ProcessScheduledActions is reserved for scheduled capacity changes, there
+	// are no scheduled actions yet so suspending it has no effect
+	ProcessScheduledActions = Process("ScheduledActions")
+	// ProcessReconcile evaluates policies against current nodes
+	ProcessReconcile = Process("Reconcile")
+)
+
+type (
+	Process string
+
+	// Suspension of ASG process
+	Suspension struct {
+		Process Process
+		Since   time.Time
+		Reason  string
+	}
+)
+
+// Processes returns all processes which can be suspended
+func Processes() []Process {
+	return []Process{
+		ProcessLaunch,
+		ProcessTerminate,
+		ProcessHealthCheck,
+		ProcessReplaceUnhealthy,
+		ProcessScheduledActions,
+		ProcessReconcile,
+	}
+}
+
+// validateProcess returns error if process is not known
+func validateProcess(process Process) error {
+	for _, p := range Processes() {
+		if p == process {
+			return nil
+		}
+	}
+
+	return errors.Errorf("Process [%s] is not supported", process)
+}
+
+// commandProcesses returns processes which given command belongs to
+func commandProcesses(cmd Command) []Process {
+	switch cmd.(type) {
+	case *Launch, *LaunchLocal:
+		return []Process{ProcessLaunch}
+	case *Terminate, *TerminateLocal:
+		return []Process{ProcessTerminate}
+	case *Relaunch, *RelaunchLocal:
+		return []Process{ProcessLaunch, ProcessTerminate}
+	case *Heal:
+		return []Process{ProcessReplaceUnhealthy}
+	}
+
+	return []Process{}
+}
+
+// SuspendProcess stops given process of ASG until it is resumed
+func (asg *AutoScalingGroup) SuspendProcess(process Process, reason string) error {
+	if err := validateProcess(process); err != nil {
+		return err
+	}
+
+	asg.lock.Lock()
+	defer asg.lock.Unlock()
+
+	if _, ok := asg.suspended[process]; ok {
+		return nil
+	}
+
+	if asg.suspended == nil {
+		asg.suspended = map[Process]Suspension{}
+	}
+
+	asg.suspended[process] = Suspension{Process: process, Since: time.Now(), Reason: reason}
+	asg.recordEvent("Process %s suspended: %s", process, reason)
+	asg.logf("[%s] Process [%s] suspended \n", asg.ID, process)
+
+	return nil
+}
+
+// ResumeProcess which has been suspended
+func (asg *AutoScalingGroup) ResumeProcess(process Process) error {
+	if err := validateProcess(process); err != nil {
+		return err
+	}
+
+	asg.lock.Lock()
+	defer asg.lock.Unlock()
+
+	if _, ok := asg.suspended[process]; !ok {
+		return nil
+	}
+
+	delete(asg.suspended, process)
+	asg.recordEvent("Process %s resumed", process)
+	asg.logf("[%s] Process [%s] resumed \n", asg.ID, process)
+
+	return nil
+}
+
+// IsSuspended returns true if given process is suspended
+func (asg *AutoScalingGroup) IsSuspended(process Process) bool {
+	asg.lock.Lock()
+	defer asg.lock.Unlock()
+
+	_, ok := asg.suspended[process]
+	return ok
+}
+
+// Suspensions returns suspended processes in the order of Processes()
+func (asg *AutoScalingGroup) Suspensions() []Suspension {
+	asg.lock.Lock()
+	defer asg.lock.Unlock()
+
+	suspensions := []Suspension{}
+	for _, p := range Processes() {
+		if s, ok := asg.suspended[p]; ok {
+			suspensions = append(suspensions, s)
+		}
+	}
+
+	return suspensions
+}
+
+// Events returns copy of ASG activity history
+func (asg *AutoScalingGroup) Events() []NodeEvent {
+	asg.lock.Lock()
+	defer asg.lock.Unlock()
+
+	return append([]NodeEvent{}, asg.history...)
+}
+
+// RecordEvent adds event to ASG activity history
+func (asg *AutoScalingGroup) RecordEvent(format string, args ...interface{}) {
+	asg.lock.Lock()
+	defer asg.lock.Unlock()
+
+	asg.recordEvent(format, args...)
+}
+
+// recordEvent expects lock to be held, only last maxNodeHistory events are kept
+func (asg *AutoScalingGroup) recordEvent(format string, args ...interface{}) {
+	asg.history = append(asg.history, NodeEvent{
+		Time:    time.Now(),
+		Message: fmt.Sprintf(format, args...),
+	})
+
+	if len(asg.history) > maxNodeHistory {
+		asg.history = asg.history[len(asg.history)-maxNodeHistory:]
+	}
+}
@@ -0,0 +1,106 @@
+package domain
+
+import (
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type ProcessesSuite struct{}
+
+var _ = Suite(&ProcessesSuite{})
+
+func (s *ProcessesSuite) TestIfSuspendedCommandsAreSkipped(c *C) {
+	drv := registerTestDriver()
+
+	node := prepareLocalNode(ID("node1"))
+	asg := NewAutoScalingGroup(ID("asg-1"))
+	asg.Setup(NewNodeSet(node), NewPolicySet())
+
+	c.Assert(asg.SuspendProcess(ProcessTerminate, "incident 42"), IsNil)
+	c.Assert(asg.IsSuspended(ProcessTerminate), Equals, true)
+
+	provider := BaseCommand{Provider: Provider{ID: testProviderID}}
+	asg.Commands[Order(1)] = &Terminate{BaseCommand: provider, NodeID: ID("node1")}
+	asg.Commands[Order(2)] = &Relaunch{BaseCommand: provider, NodeID: ID("node1")}
+
+	err := asg.Execute()
+	c.Assert(err, IsNil)
+	c.Assert(len(asg.Commands), Equals, 0)
+	c.Assert(len(drv.deleted), Equals, 0)
+	c.Assert(len(drv.created), Equals, 0)
+	c.Assert(asg.Nodes.GetByID(ID("node1")), NotNil)
+
+	events := asg.Events()
+	c.Assert(len(events), Equals, 3)
+	c.Assert(events[0].Message, Equals, "Process Terminate suspended: incident 42")
+	c.Assert(events[1].Message, Equals, "Command *domain.Terminate skipped, process Terminate is suspended")
+	c.Assert(events[2].Message, Equals, "Command *domain.Relaunch skipped, process Terminate is suspended")
+
+	c.Assert(asg.ResumeProcess(ProcessTerminate), IsNil)
+	c.Assert(asg.IsSuspended(ProcessTerminate), Equals, false)
+	c.Assert(asg.Events()[3].Message, Equals, "Process Terminate resumed")
+
+	asg.Commands[Order(1)] = &Terminate{BaseCommand: provider, NodeID: ID("node1")}
+	err = asg.Execute()
+	c.Assert(err, IsNil)
+	c.Assert(drv.deleted, DeepEquals, []ID{ID("node1")})
+}
+
+func (s *ProcessesSuite) TestIfPolicyRespectsSuspendedProcesses(c *C) {
+	node := prepareLocalNode(ID("node1"))
+	node.AddMetrics(prepareMetrics(5, 5))
+
+	asg := NewAutoScalingGroup(ID("asg-1"))
+	asg.Setup(NewNodeSet(node), NewPolicySet())
+
+	plc, err := NewDesiredNodeAmountPerProviderPolicy(ID("policy-1"), 1, 1, 1, 1, 0.7, time.Duration(-5*time.Second), Provider{ID: testProviderID})
+	c.Assert(err, IsNil)
+	asg.Policies = NewPolicySet(plc)
+
+	// Nothing is evaluated at all
+	c.Assert(asg.SuspendProcess(ProcessReconcile, ""), IsNil)
+	c.Assert(asg.Evaluate(), IsNil)
+	c.Assert(node.State, Equals, NodeStatePending)
+	c.Assert(len(asg.Commands), Equals, 0)
+	c.Assert(asg.ResumeProcess(ProcessReconcile), IsNil)
+
+	// Health is not judged
+	c.Assert(asg.SuspendProcess(ProcessHealthCheck, ""), IsNil)
+	c.Assert(asg.Evaluate(), IsNil)
+	c.Assert(node.State, Equals, NodeStatePending)
+	c.Assert(len(asg.Commands), Equals, 0)
+	c.Assert(asg.ResumeProcess(ProcessHealthCheck), IsNil)
+
+	// Unhealthy node is neither replaced nor compensated
+	c.Assert(asg.SuspendProcess(ProcessReplaceUnhealthy, ""), IsNil)
+	c.Assert(asg.Evaluate(), IsNil)
+	c.Assert(node.State, Equals, NodeStateUnhealthy)
+	c.Assert(len(asg.Commands), Equals, 0)
+	c.Assert(asg.ResumeProcess(ProcessReplaceUnhealthy), IsNil)
+
+	c.Assert(asg.Evaluate(), IsNil)
+	c.Assert(len(asg.Commands), Equals, 1)
+
+	c.Assert(asg.Suspensions(), DeepEquals, []Suspension{})
+}
+
+func (s *ProcessesSuite) TestIfOnlyKnownProcessesCanBeSuspended(c *C) {
+	asg := NewAutoScalingGroup(ID("asg-1"))
+	asg.Setup(NewNodeSet(), NewPolicySet())
+
+	c.Assert(asg.SuspendProcess(Process("AZRebalance"), ""), NotNil)
+	c.Assert(asg.ResumeProcess(Process("AZRebalance")), NotNil)
+
+	c.Assert(asg.SuspendProcess(ProcessScheduledActions, ""), IsNil)
+	c.Assert(asg.SuspendProcess(ProcessLaunch, ""), IsNil)
+	// Suspending twice keeps the first suspension
+	c.Assert(asg.SuspendProcess(ProcessLaunch, "again"), IsNil)
+
+	suspensions := asg.Suspensions()
+	c.Assert(len(suspensions), Equals, 2)
+	c.Assert(suspensions[0].Process, Equals, ProcessLaunch)
+	c.Assert(suspensions[0].Reason, Equals, "")
+	c.Assert(suspensions[1].Process, Equals, ProcessScheduledActions)
+	c.Assert(len(asg.Events()), Equals, 2)
+}
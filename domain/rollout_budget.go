@@ -0,0 +1,151 @@
+package domain
+
+import "github.com/juju/errors"
+
+type (
+	// RolloutBudget limits how much of ASG can be disrupted at once. MaxSurge
+	// is amount of extra nodes allowed during replacement, MaxUnavailable is
+	// amount of nodes allowed to be out of service. Commands which exceed the
+	// budget are deferred to later cycles
+	RolloutBudget struct {
+		MaxSurge       int
+		MaxUnavailable int
+	}
+
+	// rolloutAllowance is what is left of budget for single batch, nodes
+	// which are already out of service or surging take their part of it
+	rolloutAllowance struct {
+		surgeAllowed bool
+		surge        int
+		unavailable  int
+		// replacing is how many more nodes can be replaced at once when
+		// surge is not allowed
+		replacing    int
+		outOfService map[ID]bool
+	}
+)
+
+// NewRolloutBudget constructor
+func NewRolloutBudget(maxSurge, maxUnavailable int) (*RolloutBudget, error) {
+	if maxSurge < 0 || maxUnavailable < 0 {
+		return nil, errors.Errorf("MaxSurge %d and MaxUnavailable %d can not be negative", maxSurge, maxUnavailable)
+	}
+
+	if maxSurge == 0 && maxUnavailable == 0 {
+		return nil, errors.Errorf("MaxSurge and MaxUnavailable can not be both 0, nodes could not be replaced")
+	}
+
+	return &RolloutBudget{
+		MaxSurge:       maxSurge,
+		MaxUnavailable: maxUnavailable,
+	}, nil
+}
+
+// allowance for new batch of commands. Replacements which are not in service
+// yet, those whose old node is already gone included, take surge, or
+// unavailable if surge is not allowed at all. Nodes which are not in service,
+// pending ones included, take unavailable. It is only called by ASG loop, so
+// replacements which are in service by now are forgotten here
+func (rb *RolloutBudget) allowance(asg *AutoScalingGroup) *rolloutAllowance {
+	ra := &rolloutAllowance{
+		surgeAllowed: rb.MaxSurge > 0,
+		surge:        rb.MaxSurge,
+		unavailable:  rb.MaxUnavailable,
+		replacing:    rb.MaxUnavailable,
+		outOfService: map[ID]bool{},
+	}
+
+	replacements := map[ID]bool{}
+	for old, replacement := range asg.replacing {
+		replacements[replacement] = true
+		ra.outOfService[old] = true
+		ra.takeReplacement()
+	}
+
+	for id := range asg.surging {
+		node := asg.Nodes.GetByID(id)
+		if node == nil || (node.State == NodeStateInService && !asg.inTransition(node)) {
+			delete(asg.surging, id)
+			continue
+		}
+
+		if !replacements[id] {
+			replacements[id] = true
+			ra.takeReplacement()
+		}
+	}
+
+	for id, node := range asg.Nodes {
+		if _, ok := asg.replacing[id]; ok || replacements[id] {
+			continue
+		}
+
+		if node.State != NodeStateInService || asg.inTransition(node) {
+			ra.outOfService[id] = true
+			ra.unavailable--
+		}
+	}
+
+	return ra
+}
+
+// takeReplacement charges replacement which is already going on
+func (ra *rolloutAllowance) takeReplacement() {
+	if ra.surgeAllowed {
+		ra.surge--
+		return
+	}
+
+	ra.unavailable--
+	ra.replacing--
+}
+
+// take part of allowance required by given command, false is returned if
+// there is not enough of it left. Relaunch starts new node before old one is
+// removed, so it takes surge. If surge is not allowed at all, it takes
+// unavailable and at most MaxUnavailable nodes are replaced at once, even
+// when they are all out of service already. Terminate and heal take node out
+// of service, unless it is out already. Launch is always allowed
+func (ra *rolloutAllowance) take(cmd Command) bool {
+	id, _ := commandNode(cmd)
+
+	switch cmd.(type) {
+	case *Relaunch, *RelaunchLocal:
+		if ra.surgeAllowed {
+			return ra.takeSurge()
+		}
+		if ra.replacing <= 0 || !ra.takeUnavailable(id) {
+			return false
+		}
+		ra.replacing--
+
+		return true
+	case *Terminate, *TerminateLocal, *Heal:
+		return ra.takeUnavailable(id)
+	}
+
+	return true
+}
+
+func (ra *rolloutAllowance) takeSurge() bool {
+	if ra.surge <= 0 {
+		return false
+	}
+	ra.surge--
+
+	return true
+}
+
+func (ra *rolloutAllowance) takeUnavailable(node ID) bool {
+	if ra.outOfService[node] {
+		return true
+	}
+
+	if ra.unavailable <= 0 {
+		return false
+	}
+	ra.unavailable--
+	ra.outOfService[node] = true
+
+	return true
+}
@@ -0,0 +1,182 @@
+package domain
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+type RolloutBudgetSuite struct{}
+
+var _ = Suite(&RolloutBudgetSuite{})
+
+func prepareRolloutAsg(ids ...string) *AutoScalingGroup {
+	nodes := NewNodeSet()
+	for _, id := range ids {
+		nodes[ID(id)] = prepareLocalNode(ID(id))
+		nodes[ID(id)].ChangeState(NodeStateInService)
+	}
+
+	asg := NewAutoScalingGroup(ID("asg-1"))
+	asg.Setup(nodes, NewPolicySet())
+
+	return asg
+}
+
+func (s *RolloutBudgetSuite) TestIfCommandsOverBudgetAreDeferred(c *C) {
+	drv := registerTestDriver()
+	asg := prepareRolloutAsg("node1", "node2", "node3", "node4")
+
+	budget, err := NewRolloutBudget(1, 1)
+	c.Assert(err, IsNil)
+	asg.Rollout = budget
+
+	provider := BaseCommand{Provider: Provider{ID: testProviderID}}
+	asg.Commands[Order(1)] = &Relaunch{BaseCommand: provider, NodeID: ID("node1")}
+	asg.Commands[Order(2)] = &Relaunch{BaseCommand: provider, NodeID: ID("node2")}
+	asg.Commands[Order(3)] = &Terminate{BaseCommand: provider, NodeID: ID("node3")}
+	asg.Commands[Order(4)] = &Terminate{BaseCommand: provider, NodeID: ID("node4")}
+
+	err = asg.Execute()
+	c.Assert(err, IsNil)
+	c.Assert(drv.deleted, DeepEquals, []ID{ID("node1"), ID("node3")})
+	c.Assert(asg.Commands, DeepEquals, CommandSet{
+		Order(1): &Relaunch{BaseCommand: provider, NodeID: ID("node2")},
+		Order(2): &Terminate{BaseCommand: provider, NodeID: ID("node4")},
+	})
+	c.Assert(asg.Events()[0].Message, Equals, "Command *domain.Relaunch deferred, MaxSurge 1 and MaxUnavailable 1 are reached")
+
+	// Deferred relaunch of node which is still unhealthy is kept, terminate
+	// is left to policies which are evaluated every cycle
+	asg.Nodes.GetByID(ID("node2")).ChangeState(NodeStateUnhealthy)
+	c.Assert(asg.Evaluate(), IsNil)
+	c.Assert(asg.Commands, DeepEquals, CommandSet{
+		Order(1): &Relaunch{BaseCommand: provider, NodeID: ID("node2")},
+	})
+
+	// Node launched by previous relaunch is still pending and takes surge,
+	// even though node1 it has replaced is gone
+	c.Assert(asg.Nodes.GetByID(ID("test-1")).State, Equals, NodeStatePending)
+	err = asg.Execute()
+	c.Assert(err, IsNil)
+	c.Assert(drv.deleted, DeepEquals, []ID{ID("node1"), ID("node3")})
+	c.Assert(len(asg.Commands), Equals, 1)
+
+	asg.Nodes.GetByID(ID("test-1")).ChangeState(NodeStateInService)
+	err = asg.Execute()
+	c.Assert(err, IsNil)
+	c.Assert(drv.deleted, DeepEquals, []ID{ID("node1"), ID("node3"), ID("node2")})
+	c.Assert(len(asg.Commands), Equals, 0)
+}
+
+func (s *RolloutBudgetSuite) TestIfRelaunchOfNodesOutOfServiceIsLimitedWithoutSurge(c *C) {
+	asg := prepareRolloutAsg("node1", "node2", "node3", "node4")
+	for _, id := range []ID{ID("node1"), ID("node2"), ID("node3")} {
+		asg.Nodes.GetByID(id).ChangeState(NodeStateUnhealthy)
+	}
+
+	budget, err := NewRolloutBudget(0, 2)
+	c.Assert(err, IsNil)
+
+	allowance := budget.allowance(asg)
+	c.Assert(allowance.take(&Relaunch{NodeID: ID("node1")}), Equals, true)
+	c.Assert(allowance.take(&Relaunch{NodeID: ID("node2")}), Equals, true)
+	c.Assert(allowance.take(&Relaunch{NodeID: ID("node3")}), Equals, false)
+
+	// Replacement of node1 is still pending after node1 is gone
+	delete(asg.Nodes, ID("node1"))
+	asg.Nodes[ID("node5")] = prepareLocalNode(ID("node5"))
+	asg.Nodes.GetByID(ID("node5")).ChangeState(NodeStatePending)
+	asg.surging[ID("node5")] = true
+
+	allowance = budget.allowance(asg)
+	c.Assert(allowance.take(&Relaunch{NodeID: ID("node2")}), Equals, true)
+	c.Assert(allowance.take(&Relaunch{NodeID: ID("node3")}), Equals, false)
+
+	// Replacement which is in service is not surging anymore
+	asg.Nodes.GetByID(ID("node5")).ChangeState(NodeStateInService)
+	allowance = budget.allowance(asg)
+	c.Assert(len(asg.surging), Equals, 0)
+	c.Assert(allowance.take(&Relaunch{NodeID: ID("node2")}), Equals, true)
+	c.Assert(allowance.take(&Relaunch{NodeID: ID("node3")}), Equals, true)
+}
+
+func (s *RolloutBudgetSuite) TestIfNodesOutOfServiceAndSurgingTakeBudget(c *C) {
+	asg := prepareRolloutAsg("node1", "node2", "node3", "node4", "node5")
+	asg.Nodes.GetByID(ID("node2")).ChangeState(NodeStatePending)
+	asg.Nodes.GetByID(ID("node5")).ChangeState(NodeStatePending)
+	// node5 replaces node4
+	asg.replacing[ID("node4")] = ID("node5")
+
+	budget, err := NewRolloutBudget(1, 1)
+	c.Assert(err, IsNil)
+
+	allowance := budget.allowance(asg)
+	c.Assert(allowance.take(&Relaunch{NodeID: ID("node1")}), Equals, false)
+	c.Assert(allowance.take(&Terminate{NodeID: ID("node1")}), Equals, false)
+	// Node which is out of service already does not take any more
+	c.Assert(allowance.take(&Heal{NodeID: ID("node2")}), Equals, true)
+	c.Assert(allowance.take(&Launch{}), Equals, true)
+
+	// Replacement takes unavailable when surge is not allowed
+	budget, err = NewRolloutBudget(0, 3)
+	c.Assert(err, IsNil)
+
+	allowance = budget.allowance(asg)
+	c.Assert(allowance.take(&Relaunch{NodeID: ID("node1")}), Equals, true)
+	c.Assert(allowance.take(&Terminate{NodeID: ID("node1")}), Equals, true)
+	c.Assert(allowance.take(&Terminate{NodeID: ID("node3")}), Equals, false)
+}
+
+func (s *RolloutBudgetSuite) TestIfRelaunchTakesUnavailableWhenSurgeIsNotAllowed(c *C) {
+	asg := prepareRolloutAsg("node1", "node2", "node3")
+
+	budget, err := NewRolloutBudget(0, 1)
+	c.Assert(err, IsNil)
+
+	allowance := budget.allowance(asg)
+	c.Assert(allowance.take(&Launch{}), Equals, true)
+	c.Assert(allowance.take(&Relaunch{NodeID: ID("node1")}), Equals, true)
+	c.Assert(allowance.take(&Terminate{NodeID: ID("node2")}), Equals, false)
+	c.Assert(allowance.take(&Heal{NodeID: ID("node3")}), Equals, false)
+	c.Assert(allowance.take(&Launch{}), Equals, true)
+
+	budget, err = NewRolloutBudget(2, 0)
+	c.Assert(err, IsNil)
+
+	allowance = budget.allowance(asg)
+	c.Assert(allowance.take(&Heal{NodeID: ID("node3")}), Equals, false)
+	c.Assert(allowance.take(&Relaunch{NodeID: ID("node1")}), Equals, true)
+	c.Assert(allowance.take(&Relaunch{NodeID: ID("node2")}), Equals, true)
+	c.Assert(allowance.take(&Relaunch{NodeID: ID("node3")}), Equals, false)
+}
+
+func (s *RolloutBudgetSuite) TestIfDeferredCommandsAreDroppedWhenNotValid(c *C) {
+	asg := prepareRolloutAsg("node1", "node2", "node3")
+	asg.Nodes.GetByID(ID("node1")).ChangeState(NodeStateUnhealthy)
+
+	provider := BaseCommand{Provider: Provider{ID: testProviderID}}
+	asg.Commands[Order(1)] = &Relaunch{BaseCommand: provider, NodeID: ID("node1")}
+	asg.Commands[Order(2)] = &Relaunch{BaseCommand: provider, NodeID: ID("node1")}
+	// Node has recovered while its replacement was deferred
+	asg.Commands[Order(3)] = &Heal{BaseCommand: provider, NodeID: ID("node2")}
+	// Node is not there anymore
+	asg.Commands[Order(4)] = &Relaunch{BaseCommand: provider, NodeID: ID("node9")}
+	asg.Commands[Order(5)] = &Terminate{BaseCommand: provider, NodeID: ID("node3")}
+
+	c.Assert(asg.Evaluate(), IsNil)
+	c.Assert(asg.Commands, DeepEquals, CommandSet{
+		Order(1): &Relaunch{BaseCommand: provider, NodeID: ID("node1")},
+	})
+	c.Assert(len(asg.Events()), Equals, 4)
+	c.Assert(asg.Events()[0].Message, Equals, "Deferred command *domain.Relaunch dropped, it is not valid anymore")
+}
+
+func (s *RolloutBudgetSuite) TestIfRolloutBudgetIsValidated(c *C) {
+	_, err := NewRolloutBudget(0, 0)
+	c.Assert(err, NotNil)
+
+	_, err = NewRolloutBudget(-1, 1)
+	c.Assert(err, NotNil)
+
+	_, err = NewRolloutBudget(1, -1)
+	c.Assert(err, NotNil)
+}
@@ -0,0 +1,398 @@
+package domain
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+const (
+	// ScrapeHealthSource is result of scraping node exporter, node which can
+	// not be scraped is unhealthy
+	ScrapeHealthSource = HealthSource("scrape")
+
+	// ScrapeGauge is sum of values of matching series
+	ScrapeGauge = ScrapeFunction("gauge")
+	// ScrapeRate is change per second of sum of matching counters between scrapes
+	ScrapeRate = ScrapeFunction("rate")
+	// ScrapeBusy is part of time matching counters were not idle between scrapes,
+	// e.g. CPU busy fraction from node_cpu_seconds_total
+	ScrapeBusy = ScrapeFunction("busy")
+
+	defaultScrapePath = "/metrics"
+)
+
+type (
+	ScrapeFunction string
+
+	// ScrapeMapping maps series exported by node into artemis metric
+	ScrapeMapping struct {
+		// Series is name of exported series, e.g. node_cpu_seconds_total
+		Series string
+		// Labels series has to have to be taken into account
+		Labels Labels
+		// Metric is name of artemis metric value is recorded as
+		Metric   MetricType
+		Unit     string
+		Function ScrapeFunction
+		// IdleLabels tell apart idle time of ScrapeBusy, mode=idle by default
+		IdleLabels Labels
+	}
+
+	// Scraper pulls metrics from Prometheus exporter on every node of ASG,
+	// e.g. node_exporter, and maps selected series into artemis metrics
+	Scraper struct {
+		// Interface is either PublicInterface or PrivateInterface
+		Interface string
+		Port      int
+		Path      string
+		Interval  time.Duration
+		Timeout   time.Duration
+		Mappings  []ScrapeMapping
+
+		lastRun time.Time
+		lock    sync.Mutex
+		// previous counter values per node and mapping, rates need two scrapes
+		previous map[ID][]scrapedCounter
+	}
+
+	// ExportedSample is single sample of Prometheus text format
+	ExportedSample struct {
+		Name   string
+		Labels Labels
+		Value  float64
+	}
+
+	scrapedCounter struct {
+		time  time.Time
+		value float64
+		idle  float64
+		ok    bool
+	}
+)
+
+// NewScraper constructor, interface defaults to private and path to /metrics
+func NewScraper(iface string, port int, path string, interval, timeout time.Duration, mappings []ScrapeMapping) (*Scraper, error) {
+	if iface == "" {
+		iface = PrivateInterface
+	}
+
+	if iface != PublicInterface && iface != PrivateInterface {
+		return nil, errors.Errorf("Interface [%s] is not supported, use %s or %s", iface, PublicInterface, PrivateInterface)
+	}
+
+	if port <= 0 || port > 65535 {
+		return nil, errors.Errorf("Port %d is not valid for scraper", port)
+	}
+
+	if interval <= 0 {
+		return nil, errors.Errorf("Interval %s has to be more than 0", interval)
+	}
+
+	if timeout <= 0 || timeout > interval {
+		return nil, errors.Errorf("Timeout %s has to be more than 0 and not more than interval %s", timeout, interval)
+	}
+
+	if path == "" {
+		path = defaultScrapePath
+	}
+
+	mappings = append([]ScrapeMapping{}, mappings...)
+	for i, m := range mappings {
+		if m.Series == "" {
+			return nil, errors.Errorf("Mapping %d has no series", i)
+		}
+
+		if m.Metric == "" {
+			return nil, errors.Errorf("Mapping of series [%s] has no metric", m.Series)
+		}
+
+		switch m.Function {
+		case "":
+			mappings[i].Function = ScrapeGauge
+		case ScrapeGauge, ScrapeRate:
+		case ScrapeBusy:
+			if len(m.IdleLabels) == 0 {
+				mappings[i].IdleLabels = Labels{"mode": "idle"}
+			}
+		default:
+			return nil, errors.Errorf("Scrape function [%s] of series [%s] is not supported", m.Function, m.Series)
+		}
+	}
+
+	return &Scraper{
+		Interface: iface,
+		Port:      port,
+		Path:      path,
+		Interval:  interval,
+		Timeout:   timeout,
+		Mappings:  mappings,
+		previous:  map[ID][]scrapedCounter{},
+	}, nil
+}
+
+// Due returns true if interval has passed since last run
+func (s *Scraper) Due(now time.Time) bool {
+	return now.Sub(s.lastRun) >= s.Interval
+}
+
+// Run scrapes all given nodes in parallel and records mapped metrics, result
+// of scrape is recorded as health metric of ScrapeHealthSource. Exporter which
+// answers with no samples is still healthy, failures are written with logf
+func (s *Scraper) Run(nodes NodeSet, now time.Time, logf func(format string, args ...interface{})) {
+	s.lastRun = now
+
+	type scrapeResult struct {
+		samples []ExportedSample
+		err     error
+	}
+
+	results := map[ID]scrapeResult{}
+	var lock sync.Mutex
+	var wait sync.WaitGroup
+
+	for id, node := range nodes {
+		wait.Add(1)
+		go func(id ID, node *Node) {
+			defer wait.Done()
+
+			samples, err := s.Scrape(node)
+
+			lock.Lock()
+			results[id] = scrapeResult{samples: samples, err: err}
+			lock.Unlock()
+		}(id, node)
+	}
+	wait.Wait()
+
+	for id, result := range results {
+		if result.err != nil {
+			logf("Scrape of node [%s] has failed : %s \n", id, result.err)
+			nodes[id].AddMetrics(NewMetricSeries(NewHealthMetricFrom(0, now, ScrapeHealthSource)))
+			continue
+		}
+
+		nodes[id].AddMetrics(NewMetricSeries(NewHealthMetricFrom(1, now, ScrapeHealthSource)))
+		nodes[id].AddNamedMetrics(s.Map(id, result.samples, now))
+	}
+
+	// Counters of nodes which are gone are not needed anymore
+	s.lock.Lock()
+	for id := range s.previous {
+		if _, ok := nodes[id]; !ok {
+			delete(s.previous, id)
+		}
+	}
+	s.lock.Unlock()
+}
+
+// Scrape exporter of given node
+func (s *Scraper) Scrape(node *Node) ([]ExportedSample, error) {
+	ip := node.PrivateIface.IP
+	if s.Interface == PublicInterface {
+		ip = node.PublicIface.IP
+	}
+
+	if ip == nil {
+		return nil, errors.Errorf("Node has no %s IP", s.Interface)
+	}
+
+	client := &http.Client{Timeout: s.Timeout}
+	resp, err := client.Get("http://" + net.JoinHostPort(ip.String(), strconv.Itoa(s.Port)) + s.Path)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("Expected status %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	return ParseExposition(resp.Body)
+}
+
+// Map scraped samples of node into metrics, rates are calculated against
+// previous scrape of the node, so there are none after the first one
+func (s *Scraper) Map(node ID, samples []ExportedSample, now time.Time) []NamedMetric {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.previous == nil {
+		s.previous = map[ID][]scrapedCounter{}
+	}
+
+	previous := s.previous[node]
+	current := make([]scrapedCounter, len(s.Mappings))
+	rez := []NamedMetric{}
+
+	for i, m := range s.Mappings {
+		counter := scrapedCounter{time: now}
+		for _, sample := range samples {
+			if sample.Name != m.Series || !sample.Labels.Matches(m.Labels) {
+				continue
+			}
+
+			counter.ok = true
+			counter.value = counter.value + sample.Value
+			if m.Function == ScrapeBusy && sample.Labels.Matches(m.IdleLabels) {
+				counter.idle = counter.idle + sample.Value
+			}
+		}
+		current[i] = counter
+
+		if !counter.ok {
+			continue
+		}
+
+		if m.Function == ScrapeGauge {
+			rez = append(rez, NewNamedMetric(m.Metric, m.Unit, nil, counter.value, now))
+			continue
+		}
+
+		if i >= len(previous) || !previous[i].ok {
+			continue
+		}
+
+		last := previous[i]
+		seconds := now.Sub(last.time).Seconds()
+		delta := counter.value - last.value
+		// Counter has been reset, e.g. exporter restarted
+		if seconds <= 0 || delta < 0 {
+			continue
+		}
+
+		switch m.Function {
+		case ScrapeRate:
+			rez = append(rez, NewNamedMetric(m.Metric, m.Unit, nil, delta/seconds, now))
+		case ScrapeBusy:
+			if delta == 0 {
+				continue
+			}
+			idle := counter.idle - last.idle
+			rez = append(rez, NewNamedMetric(m.Metric, m.Unit, nil, 1-idle/delta, now))
+		}
+	}
+
+	s.previous[node] = current
+
+	return rez
+}
+
+// ParseExposition parses metrics in Prometheus text format, comments and
+// timestamps are ignored
+func ParseExposition(r io.Reader) ([]ExportedSample, error) {
+	samples := []ExportedSample{}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		sample, err := parseSample(text)
+		if err != nil {
+			return nil, errors.Annotatef(err, "Line %d", line)
+		}
+		samples = append(samples, sample)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return samples, nil
+}
+
+// parseSample parses `name{label="value",...} value [timestamp]`
+func parseSample(text string) (ExportedSample, error) {
+	sample := ExportedSample{Labels: Labels{}}
+
+	end := strings.IndexAny(text, "{ \t")
+	if end <= 0 {
+		return sample, errors.Errorf("Sample [%s] has no value", text)
+	}
+	sample.Name = text[:end]
+	rest := text[end:]
+
+	if rest[0] == '{' {
+		labels, remaining, err := parseLabels(rest[1:])
+		if err != nil {
+			return sample, errors.Trace(err)
+		}
+		sample.Labels = labels
+		rest = remaining
+	}
+
+	fields := strings.Fields(rest)
+	if len(fields) == 0 || len(fields) > 2 {
+		return sample, errors.Errorf("Sample [%s] has no valid value", text)
+	}
+
+	value, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return sample, errors.Annotatef(err, "Value of sample [%s]", sample.Name)
+	}
+	sample.Value = value
+
+	return sample, nil
+}
+
+// parseLabels parses labels until closing brace and returns what follows it
+func parseLabels(text string) (Labels, string, error) {
+	labels := Labels{}
+	for {
+		text = strings.TrimLeft(text, " \t,")
+		if text == "" {
+			return nil, "", errors.Errorf("Labels are not closed")
+		}
+
+		if text[0] == '}' {
+			return labels, text[1:], nil
+		}
+
+		eq := strings.Index(text, "=")
+		if eq <= 0 {
+			return nil, "", errors.Errorf("Label [%s] has no value", text)
+		}
+		name := strings.TrimSpace(text[:eq])
+		text = strings.TrimLeft(text[eq+1:], " \t")
+
+		if text == "" || text[0] != '"' {
+			return nil, "", errors.Errorf("Value of label [%s] is not quoted", name)
+		}
+
+		value := []byte{}
+		i := 1
+		for ; i < len(text) && text[i] != '"'; i++ {
+			if text[i] == '\\' && i+1 < len(text) {
+				i++
+				switch text[i] {
+				case 'n':
+					value = append(value, '\n')
+				default:
+					value = append(value, text[i])
+				}
+				continue
+			}
+			value = append(value, text[i])
+		}
+
+		if i == len(text) {
+			return nil, "", errors.Errorf("Value of label [%s] is not closed", name)
+		}
+
+		labels[name] = string(value)
+		text = text[i+1:]
+	}
+}
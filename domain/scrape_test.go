@@ -0,0 +1,160 @@
+package domain
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type ScrapeSuite struct{}
+
+var _ = Suite(&ScrapeSuite{})
+
+const nodeExporterOutput = `# HELP node_cpu_seconds_total Seconds the CPUs spent in each mode.
+# TYPE node_cpu_seconds_total counter
+node_cpu_seconds_total{cpu="0",mode="idle"} %v
+node_cpu_seconds_total{cpu="0",mode="user"} %v
+node_cpu_seconds_total{cpu="1",mode="idle"} %v
+node_cpu_seconds_total{cpu="1",mode="user"} %v
+# HELP node_load1 1m load average.
+# TYPE node_load1 gauge
+node_load1 %v
+node_filesystem_avail_bytes{device="/dev/vda1",mountpoint="/",label="a \"quoted\", value"} 1e+09 1500000000000
+`
+
+func exporterOutput(idle0, user0, idle1, user1, load float64) string {
+	return fmt.Sprintf(nodeExporterOutput, idle0, user0, idle1, user1, load)
+}
+
+func (s *ScrapeSuite) TestIfExpositionIsParsed(c *C) {
+	samples, err := ParseExposition(strings.NewReader(exporterOutput(10, 5, 20, 1, 0.5)))
+	c.Assert(err, IsNil)
+	c.Assert(len(samples), Equals, 6)
+	c.Assert(samples[0], DeepEquals, ExportedSample{Name: "node_cpu_seconds_total", Labels: Labels{"cpu": "0", "mode": "idle"}, Value: 10})
+	c.Assert(samples[4], DeepEquals, ExportedSample{Name: "node_load1", Labels: Labels{}, Value: 0.5})
+	c.Assert(samples[5].Labels["label"], Equals, `a "quoted", value`)
+	c.Assert(samples[5].Value, Equals, 1e9)
+
+	_, err = ParseExposition(strings.NewReader("node_load1{cpu=\"0\" 1"))
+	c.Assert(err, ErrorMatches, "Line 1: .*")
+
+	_, err = ParseExposition(strings.NewReader("node_load1 high"))
+	c.Assert(err, NotNil)
+}
+
+func (s *ScrapeSuite) TestIfSeriesAreMappedIntoMetrics(c *C) {
+	scraper, err := NewScraper("", 9100, "", 10*time.Second, time.Second, []ScrapeMapping{
+		{Series: "node_cpu_seconds_total", Metric: "cpu", Unit: "fraction", Function: ScrapeBusy},
+		{Series: "node_cpu_seconds_total", Labels: Labels{"cpu": "0", "mode": "user"}, Metric: "cpu0_user", Function: ScrapeRate},
+		{Series: "node_load1", Metric: "load"},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(scraper.Interface, Equals, PrivateInterface)
+	c.Assert(scraper.Path, Equals, "/metrics")
+
+	now := time.Now()
+	samples, err := ParseExposition(strings.NewReader(exporterOutput(10, 5, 20, 1, 0.5)))
+	c.Assert(err, IsNil)
+
+	// Rates need previous scrape
+	metrics := scraper.Map(ID("node1"), samples, now)
+	c.Assert(metrics, DeepEquals, []NamedMetric{NewNamedMetric("load", "", nil, 0.5, now)})
+
+	// 20 seconds of CPU time have passed, 5 of them idle
+	samples, err = ParseExposition(strings.NewReader(exporterOutput(13, 15, 22, 6, 1.5)))
+	c.Assert(err, IsNil)
+	metrics = scraper.Map(ID("node1"), samples, now.Add(10*time.Second))
+	c.Assert(metrics, DeepEquals, []NamedMetric{
+		NewNamedMetric("cpu", "fraction", nil, 0.75, now.Add(10*time.Second)),
+		NewNamedMetric("cpu0_user", "", nil, 1, now.Add(10*time.Second)),
+		NewNamedMetric("load", "", nil, 1.5, now.Add(10*time.Second)),
+	})
+
+	// Counters are reset when exporter restarts
+	samples, err = ParseExposition(strings.NewReader(exporterOutput(1, 1, 1, 1, 1)))
+	c.Assert(err, IsNil)
+	metrics = scraper.Map(ID("node1"), samples, now.Add(20*time.Second))
+	c.Assert(len(metrics), Equals, 1)
+
+	_, err = NewScraper("", 9100, "", 10*time.Second, time.Second, []ScrapeMapping{{Series: "node_load1", Metric: "load", Function: "median"}})
+	c.Assert(err, ErrorMatches, "Scrape function \\[median\\] of series \\[node_load1\\] is not supported")
+
+	_, err = NewScraper("", 9100, "", 10*time.Second, time.Second, []ScrapeMapping{{Series: "node_load1"}})
+	c.Assert(err, ErrorMatches, "Mapping of series \\[node_load1\\] has no metric")
+}
+
+func (s *ScrapeSuite) TestIfScrapeFailuresAreRecordedAsHealth(c *C) {
+	var lock sync.Mutex
+	idle := 10.0
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/metrics" {
+			rw.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		lock.Lock()
+		defer lock.Unlock()
+		rw.Write([]byte(exporterOutput(idle, 0, idle, 0, 0.5)))
+		idle = idle + 1
+	}))
+	defer srv.Close()
+	port := serverPort(c, srv.Listener.Addr().String())
+
+	reachable := prepareLocalNode(ID("node1"))
+	unreachable := NewNode()
+	unreachable.Setup(ID("node2"), Provider{ID: testProviderID}, NetworkInterface{}, NetworkInterface{})
+
+	scraper, err := NewScraper(PublicInterface, port, "", time.Second, time.Second, []ScrapeMapping{
+		{Series: "node_cpu_seconds_total", Metric: "cpu", Function: ScrapeBusy},
+	})
+	c.Assert(err, IsNil)
+
+	logged := []string{}
+	logf := func(format string, args ...interface{}) {
+		logged = append(logged, fmt.Sprintf(format, args...))
+	}
+
+	now := time.Now()
+	c.Assert(scraper.Due(now), Equals, true)
+	scraper.Run(NewNodeSet(reachable, unreachable), now, logf)
+	c.Assert(scraper.Due(now), Equals, false)
+	scraper.Run(NewNodeSet(reachable, unreachable), now.Add(time.Second), logf)
+	c.Assert(logged, HasLen, 2)
+	c.Assert(strings.HasPrefix(logged[0], "Scrape of node [node2] has failed"), Equals, true)
+
+	from, to := now.Add(-time.Second), now.Add(2*time.Second)
+	health := reachable.Metrics.Filter(HealthMetricType, from, to).FilterHealthSource(ScrapeHealthSource)
+	c.Assert(len(health), Equals, 2)
+	c.Assert(health.Average(), Equals, 1.0)
+	cpu := reachable.QueryMetrics("cpu", nil, from, to)
+	c.Assert(len(cpu), Equals, 1)
+	c.Assert(cpu[0].GetValue(), Equals, 0.0)
+
+	health = unreachable.Metrics.Filter(HealthMetricType, from, to).FilterHealthSource(ScrapeHealthSource)
+	c.Assert(len(health), Equals, 2)
+	c.Assert(health.Average(), Equals, 0.0)
+	c.Assert(len(unreachable.QueryMetrics("cpu", nil, from, to)), Equals, 0)
+}
+
+func (s *ScrapeSuite) TestIfEmptyScrapeIsHealthy(c *C) {
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	node := prepareLocalNode(ID("node1"))
+	scraper, err := NewScraper(PublicInterface, serverPort(c, srv.Listener.Addr().String()), "", time.Second, time.Second, nil)
+	c.Assert(err, IsNil)
+
+	now := time.Now()
+	scraper.Run(NewNodeSet(node), now, func(format string, args ...interface{}) {
+		c.Errorf(format, args...)
+	})
+
+	health := node.Metrics.Filter(HealthMetricType, now.Add(-time.Second), now.Add(time.Second)).FilterHealthSource(ScrapeHealthSource)
+	c.Assert(len(health), Equals, 1)
+	c.Assert(health.Average(), Equals, 1.0)
+}
@@ -0,0 +1,250 @@
+package domain
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	ShadowLaunch    = ShadowAction("launch")
+	ShadowTerminate = ShadowAction("terminate")
+	ShadowRelaunch  = ShadowAction("relaunch")
+	ShadowHeal      = ShadowAction("heal")
+
+	maxShadowDecisions = 500
+)
+
+type (
+	ShadowAction string
+
+	// ShadowDecision is command which would have been executed, Outcome is
+	// what has actually happened to the fleet afterwards
+	ShadowDecision struct {
+		Time       time.Time
+		Action     ShadowAction
+		NodeID     ID
+		Reason     string
+		Outcome    string
+		ResolvedAt time.Time
+		// FalsePositive is set if node has recovered on its own
+		FalsePositive bool
+
+		nodes int
+	}
+
+	// ShadowLog records what ASG would have done while it runs in shadow mode
+	// and follows what has actually happened to its nodes
+	ShadowLog struct {
+		sync.Mutex
+		Since     time.Time
+		Decisions []ShadowDecision
+		// NodesAdded and NodesRemoved are changes of the fleet done by others
+		NodesAdded   []ID
+		NodesRemoved []ID
+
+		known map[ID]bool
+	}
+
+	// ShadowReport compares decisions with what has actually happened
+	ShadowReport struct {
+		Since     time.Time
+		WouldHave map[ShadowAction]int
+		// FalsePositives are nodes which would have been replaced, but became
+		// healthy on their own
+		FalsePositives int
+		// Removed nodes would have been replaced and were removed by others
+		Removed int
+		// FleetChanged is amount of launch and terminate decisions followed by
+		// change of amount of nodes
+		FleetChanged int
+		Open         int
+		NodesAdded   []ID
+		NodesRemoved []ID
+		Decisions    []ShadowDecision
+	}
+)
+
+// NewShadowLog constructor
+func NewShadowLog() *ShadowLog {
+	return &ShadowLog{
+		Since:        time.Now(),
+		Decisions:    []ShadowDecision{},
+		NodesAdded:   []ID{},
+		NodesRemoved: []ID{},
+	}
+}
+
+// Record command as decision, returns false if the same decision is still
+// waiting for outcome. Command which is not known is recorded without action
+func (sl *ShadowLog) Record(cmd Command, nodes NodeSet, now time.Time) (ShadowDecision, bool) {
+	decision := ShadowDecision{Time: now, nodes: len(nodes)}
+	switch c := cmd.(type) {
+	case *Launch, *LaunchLocal:
+		decision.Action = ShadowLaunch
+	case *Terminate:
+		decision.Action = ShadowTerminate
+		decision.NodeID = c.NodeID
+	case *TerminateLocal:
+		decision.Action = ShadowTerminate
+		decision.NodeID = c.NodeID
+	case *Relaunch:
+		decision.Action = ShadowRelaunch
+		decision.NodeID = c.NodeID
+	case *RelaunchLocal:
+		decision.Action = ShadowRelaunch
+		decision.NodeID = c.NodeID
+	case *Heal:
+		decision.Action = ShadowHeal
+		decision.NodeID = c.NodeID
+	}
+
+	if r, ok := cmd.(interface {
+		GetReason() string
+	}); ok {
+		decision.Reason = r.GetReason()
+	}
+
+	sl.Lock()
+	defer sl.Unlock()
+
+	for _, d := range sl.Decisions {
+		if d.ResolvedAt.IsZero() && d.Action == decision.Action && d.NodeID == decision.NodeID {
+			return decision, false
+		}
+	}
+
+	sl.Decisions = append(sl.Decisions, decision)
+	if len(sl.Decisions) > maxShadowDecisions {
+		sl.Decisions = sl.Decisions[len(sl.Decisions)-maxShadowDecisions:]
+	}
+
+	return decision, true
+}
+
+// Observe nodes of ASG, resolves decisions which have outcome by now
+func (sl *ShadowLog) Observe(nodes NodeSet, now time.Time) {
+	sl.Lock()
+	defer sl.Unlock()
+
+	if sl.known == nil {
+		sl.known = map[ID]bool{}
+		for id := range nodes {
+			sl.known[id] = true
+		}
+	}
+
+	for id := range nodes {
+		if !sl.known[id] {
+			sl.known[id] = true
+			sl.NodesAdded = append(sl.NodesAdded, id)
+		}
+	}
+
+	removed := []string{}
+	for id := range sl.known {
+		if _, ok := nodes[id]; !ok {
+			delete(sl.known, id)
+			removed = append(removed, string(id))
+		}
+	}
+	sort.Strings(removed)
+	for _, id := range removed {
+		sl.NodesRemoved = append(sl.NodesRemoved, ID(id))
+	}
+
+	for i, d := range sl.Decisions {
+		if !d.ResolvedAt.IsZero() {
+			continue
+		}
+
+		switch d.Action {
+		case ShadowLaunch, ShadowTerminate:
+			if len(nodes) != d.nodes {
+				d.Outcome = fmt.Sprintf("Amount of nodes has changed from %d to %d", d.nodes, len(nodes))
+				d.ResolvedAt = now
+			}
+		default:
+			node, ok := nodes[d.NodeID]
+			if !ok {
+				d.Outcome = fmt.Sprintf("Node [%s] was removed", d.NodeID)
+				d.ResolvedAt = now
+			} else if node.State == NodeStateInService {
+				d.Outcome = fmt.Sprintf("Node [%s] has recovered on its own", d.NodeID)
+				d.ResolvedAt = now
+				d.FalsePositive = true
+			}
+		}
+		sl.Decisions[i] = d
+	}
+}
+
+// Report of decisions and their outcomes
+func (sl *ShadowLog) Report() ShadowReport {
+	sl.Lock()
+	defer sl.Unlock()
+
+	report := ShadowReport{
+		Since:        sl.Since,
+		WouldHave:    map[ShadowAction]int{},
+		NodesAdded:   append([]ID{}, sl.NodesAdded...),
+		NodesRemoved: append([]ID{}, sl.NodesRemoved...),
+		Decisions:    append([]ShadowDecision{}, sl.Decisions...),
+	}
+
+	for _, d := range sl.Decisions {
+		report.WouldHave[d.Action]++
+
+		if d.ResolvedAt.IsZero() {
+			report.Open++
+			continue
+		}
+
+		switch {
+		case d.Action == ShadowLaunch || d.Action == ShadowTerminate:
+			report.FleetChanged++
+		case d.FalsePositive:
+			report.FalsePositives++
+		default:
+			report.Removed++
+		}
+	}
+
+	return report
+}
+
+// shadowExecute records command instead of executing it
+func (asg *AutoScalingGroup) shadowExecute(cmd Command) {
+	decision, ok := asg.Shadow.Record(cmd, asg.Nodes, asg.now())
+	if !ok {
+		return
+	}
+
+	if decision.Action == "" {
+		asg.RecordEvent("Shadow: command %T would have been executed", cmd)
+		return
+	}
+
+	if decision.NodeID != "" {
+		asg.RecordEvent("Shadow: would have %s node [%s]: %s", decision.Action.pastTense(), decision.NodeID, decision.Reason)
+		return
+	}
+
+	asg.RecordEvent("Shadow: would have %s node: %s", decision.Action.pastTense(), decision.Reason)
+}
+
+func (sa ShadowAction) pastTense() string {
+	switch sa {
+	case ShadowLaunch:
+		return "launched"
+	case ShadowTerminate:
+		return "terminated"
+	case ShadowRelaunch:
+		return "relaunched"
+	case ShadowHeal:
+		return "healed"
+	}
+
+	return string(sa)
+}
@@ -0,0 +1,91 @@
+package domain
+
+import (
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type ShadowSuite struct{}
+
+var _ = Suite(&ShadowSuite{})
+
+func (s *ShadowSuite) TestIfShadowASGRecordsDecisionsInsteadOfExecutingThem(c *C) {
+	drv := registerTestDriver()
+
+	node := prepareLocalNode(ID("node1"))
+	node.AddMetrics(prepareMetrics(5, 5))
+
+	plc, err := NewDesiredNodeAmountPerProviderPolicy(ID("policy-1"), 1, 2, 2, 1, 0.7, time.Duration(-5*time.Second), Provider{ID: testProviderID})
+	c.Assert(err, IsNil)
+
+	asg := NewAutoScalingGroup(ID("asg-1"))
+	asg.Setup(NewNodeSet(node), NewPolicySet(plc))
+	asg.Shadow = NewShadowLog()
+
+	for i := 0; i < 2; i++ {
+		c.Assert(asg.Evaluate(), IsNil)
+		c.Assert(asg.Execute(), IsNil)
+	}
+
+	c.Assert(len(drv.created), Equals, 0)
+	c.Assert(len(drv.deleted), Equals, 0)
+	c.Assert(asg.Nodes.GetByID(ID("node1")), NotNil)
+	c.Assert(len(asg.Commands), Equals, 0)
+
+	// Decisions are not repeated while they wait for outcome
+	events := asg.Events()
+	c.Assert(len(events), Equals, 2)
+	c.Assert(events[0].Message, Equals, "Shadow: would have relaunched node [node1]: Node [node1] has failed 1 consecutive health checks")
+	c.Assert(events[1].Message, Equals, "Shadow: would have launched node: Healthy nodes 0 are less than desired 2")
+
+	report := asg.Shadow.Report()
+	c.Assert(report.WouldHave, DeepEquals, map[ShadowAction]int{ShadowRelaunch: 1, ShadowLaunch: 1})
+	c.Assert(report.Open, Equals, 2)
+}
+
+func (s *ShadowSuite) TestIfShadowReportComparesDecisionsWithFleet(c *C) {
+	node := prepareLocalNode(ID("node1"))
+	node.AddMetrics(prepareMetrics(5, 5))
+
+	plc, err := NewDesiredNodeAmountPerProviderPolicy(ID("policy-1"), 1, 2, 2, 1, 0.7, time.Duration(-5*time.Second), Provider{ID: testProviderID})
+	c.Assert(err, IsNil)
+
+	asg := NewAutoScalingGroup(ID("asg-1"))
+	asg.Setup(NewNodeSet(node), NewPolicySet(plc))
+	asg.Shadow = NewShadowLog()
+
+	c.Assert(asg.Evaluate(), IsNil)
+	c.Assert(asg.Execute(), IsNil)
+
+	// Node recovers on its own
+	node.Metrics.Reset()
+	node.AddMetrics(prepareMetrics(0, 5))
+	c.Assert(asg.Evaluate(), IsNil)
+	c.Assert(node.State, Equals, NodeStateInService)
+	c.Assert(asg.Execute(), IsNil)
+
+	// Someone else adds node
+	asg.AddNode(prepareLocalNode(ID("node2")))
+	c.Assert(asg.Execute(), IsNil)
+
+	report := asg.Shadow.Report()
+	c.Assert(report.FalsePositives, Equals, 1)
+	c.Assert(report.FleetChanged, Equals, 1)
+	c.Assert(report.Open, Equals, 0)
+	c.Assert(report.NodesAdded, DeepEquals, []ID{ID("node2")})
+	c.Assert(report.Decisions[0].Outcome, Equals, "Node [node1] has recovered on its own")
+	c.Assert(report.Decisions[1].Outcome, Equals, "Amount of nodes has changed from 1 to 2")
+
+	// Node removed by others confirms the decision
+	node.Metrics.Reset()
+	node.AddMetrics(prepareMetrics(5, 5))
+	c.Assert(asg.Evaluate(), IsNil)
+	c.Assert(asg.Execute(), IsNil)
+	asg.RemoveNode(ID("node1"))
+	c.Assert(asg.Execute(), IsNil)
+
+	report = asg.Shadow.Report()
+	c.Assert(report.Removed, Equals, 1)
+	c.Assert(report.NodesRemoved, DeepEquals, []ID{ID("node1")})
+}
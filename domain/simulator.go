@@ -0,0 +1,480 @@
+package domain
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+const defaultRecoveryWindow = 10 * time.Minute
+
+type (
+	// RecordedMetric is single line of recorded metric stream
+	RecordedMetric struct {
+		Node  ID
+		Time  time.Time
+		Value float64
+	}
+
+	// SimulatedProvider launches nodes which report healthy after BootDelay,
+	// FailureRate part of them never boots
+	SimulatedProvider struct {
+		BootDelay   time.Duration
+		FailureRate float64
+
+		rand *rand.Rand
+	}
+
+	// Simulator replays recorded metrics through policies using virtual clock,
+	// commands are executed against simulated provider instead of real one.
+	// Recorded node joins the fleet with its first metric and is fed its
+	// recorded metrics until it is replaced or terminated
+	Simulator struct {
+		Policies PolicySet
+		Provider *SimulatedProvider
+		// Tick is how often policies are evaluated
+		Tick                   time.Duration
+		HealthCheckGracePeriod time.Duration
+		HeartbeatTimeout       time.Duration
+		// RecoveryWindow is time after replacement within which recorded node
+		// reporting healthy again is counted as false positive
+		RecoveryWindow time.Duration
+		// Log receives activity of simulated ASG, it is discarded if not set
+		Log io.Writer
+	}
+
+	// SimulationPoint is capacity of fleet after single tick
+	SimulationPoint struct {
+		Time      time.Time
+		Nodes     int
+		InService int
+		Pending   int
+		Unhealthy int
+		Desired   int
+	}
+
+	// SimulationResult is timeline of simulation and its summary
+	SimulationResult struct {
+		Start    time.Time
+		End      time.Time
+		Timeline []SimulationPoint
+		Events   []NodeEvent
+
+		Launches       int
+		FailedLaunches int
+		Replacements   int
+		Terminations   int
+		// FalsePositives are replaced nodes which have reported healthy
+		// again within recovery window
+		FalsePositives int
+
+		// TimesToRecover are durations from healthy capacity dropping below
+		// desired until it has been restored
+		TimesToRecover    []time.Duration
+		MeanTimeToRecover time.Duration
+		MaxTimeToRecover  time.Duration
+	}
+
+	// simulation is state of single run
+	simulation struct {
+		*Simulator
+		asg      *AutoScalingGroup
+		clock    *VirtualClock
+		result   *SimulationResult
+		provider Provider
+
+		launched  int
+		simulated map[ID]*simulatedNode
+		replaced  map[ID]*replacedNode
+		removed   map[ID]bool
+
+		reachedDesired bool
+		degradedSince  time.Time
+	}
+
+	// simulatedNode is node launched by simulation
+	simulatedNode struct {
+		healthyAt time.Time
+		broken    bool
+	}
+
+	// replacedNode is recorded node which has been replaced by simulation
+	replacedNode struct {
+		at        time.Time
+		threshold float64
+		recovered bool
+	}
+)
+
+// NewSimulatedProvider constructor, the same seed gives the same failures
+func NewSimulatedProvider(bootDelay time.Duration, failureRate float64, seed int64) (*SimulatedProvider, error) {
+	if bootDelay < 0 {
+		return nil, errors.Errorf("BootDelay %s can not be negative", bootDelay)
+	}
+
+	if failureRate < 0 || failureRate > 1 {
+		return nil, errors.Errorf("FailureRate %v has to be between 0 and 1", failureRate)
+	}
+
+	return &SimulatedProvider{
+		BootDelay:   bootDelay,
+		FailureRate: failureRate,
+		rand:        rand.New(rand.NewSource(seed)),
+	}, nil
+}
+
+// fails returns true if next launched node should never boot
+func (sp *SimulatedProvider) fails() bool {
+	if sp.FailureRate == 0 {
+		return false
+	}
+
+	return sp.rand.Float64() < sp.FailureRate
+}
+
+// NewSimulator constructor
+func NewSimulator(policies PolicySet, provider *SimulatedProvider, tick time.Duration) (*Simulator, error) {
+	if len(policies) == 0 {
+		return nil, errors.Errorf("At least one policy is required")
+	}
+
+	if provider == nil {
+		return nil, errors.Errorf("Simulated provider is required")
+	}
+
+	if tick <= 0 {
+		return nil, errors.Errorf("Tick %s has to be more than 0", tick)
+	}
+
+	return &Simulator{
+		Policies:       policies,
+		Provider:       provider,
+		Tick:           tick,
+		RecoveryWindow: defaultRecoveryWindow,
+	}, nil
+}
+
+// ReadRecordedMetrics reads JSON lines of recorded metrics, empty lines are skipped
+func ReadRecordedMetrics(r io.Reader) ([]RecordedMetric, error) {
+	metrics := []RecordedMetric{}
+	scanner := bufio.NewScanner(r)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		m := RecordedMetric{}
+		if err := json.Unmarshal([]byte(text), &m); err != nil {
+			return nil, errors.Annotatef(err, "Line %d", line)
+		}
+
+		if m.Node == "" {
+			return nil, errors.Errorf("Line %d has no node", line)
+		}
+		metrics = append(metrics, m)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return metrics, nil
+}
+
+// Run simulation over given metrics, policies of simulator are not changed
+// so the same simulator can be run more than once
+func (s *Simulator) Run(metrics []RecordedMetric) (*SimulationResult, error) {
+	if len(metrics) == 0 {
+		return nil, errors.Errorf("There are no metrics to replay")
+	}
+
+	recorded := append([]RecordedMetric{}, metrics...)
+	sort.SliceStable(recorded, func(i, j int) bool {
+		return recorded[i].Time.Before(recorded[j].Time)
+	})
+
+	start := recorded[0].Time
+	end := recorded[len(recorded)-1].Time
+
+	sim, err := s.newSimulation(start)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	sim.result.End = end
+
+	next := 0
+	for t := start.Add(s.Tick); !t.After(end.Add(s.Tick)); t = t.Add(s.Tick) {
+		sim.clock.Set(t)
+
+		// Metrics reported before evaluation, as policies look at window
+		// which ends right before it
+		for next < len(recorded) && recorded[next].Time.Before(t) {
+			sim.replay(recorded[next])
+			next++
+		}
+		sim.report(t)
+
+		if err := sim.asg.Evaluate(); err != nil {
+			return nil, errors.Trace(err)
+		}
+		sim.execute(t)
+		sim.measure(t)
+	}
+
+	sim.summarize()
+
+	return sim.result, nil
+}
+
+// newSimulation creates ASG with copy of policies
+func (s *Simulator) newSimulation(start time.Time) (*simulation, error) {
+	policies := NewPolicySet()
+	ids := []string{}
+	for id, p := range s.Policies {
+		policies[id] = p.Clone()
+		ids = append(ids, string(id))
+	}
+	sort.Strings(ids)
+
+	// Recorded nodes belong to the first policy
+	provider, ok := policyProvider(policies[ID(ids[0])])
+	if !ok {
+		return nil, errors.Errorf("Policy %s is not supported by simulator", ids[0])
+	}
+
+	asg := NewAutoScalingGroup(ID("simulation"))
+	asg.Setup(NewNodeSet(), policies)
+	asg.Clock = NewVirtualClock(start)
+	asg.HealthCheckGracePeriod = s.HealthCheckGracePeriod
+	asg.HeartbeatTimeout = s.HeartbeatTimeout
+	asg.Log = s.Log
+	if asg.Log == nil {
+		asg.Log = ioutil.Discard
+	}
+
+	return &simulation{
+		Simulator: s,
+		asg:       asg,
+		clock:     asg.Clock.(*VirtualClock),
+		provider:  provider,
+		result: &SimulationResult{
+			Start:          start,
+			Timeline:       []SimulationPoint{},
+			Events:         []NodeEvent{},
+			TimesToRecover: []time.Duration{},
+		},
+		simulated: map[ID]*simulatedNode{},
+		replaced:  map[ID]*replacedNode{},
+		removed:   map[ID]bool{},
+	}, nil
+}
+
+// replay recorded metric, node which is not known yet joins the fleet
+func (sim *simulation) replay(m RecordedMetric) {
+	if r, ok := sim.replaced[m.Node]; ok {
+		if !r.recovered && m.Value >= r.threshold && m.Time.Sub(r.at) <= sim.RecoveryWindow {
+			r.recovered = true
+			sim.result.FalsePositives++
+			sim.event(m.Time, "Node [%s] has reported healthy %.2f, %s after it was replaced", m.Node, m.Value, m.Time.Sub(r.at))
+		}
+		return
+	}
+
+	if sim.removed[m.Node] {
+		return
+	}
+
+	if _, ok := sim.asg.Nodes[m.Node]; !ok {
+		node := NewNode()
+		node.Setup(m.Node, sim.provider, NetworkInterface{}, NetworkInterface{})
+		node.LaunchedAt = m.Time
+		node.ChangeState(NodeStateInService)
+		sim.asg.AddNode(node)
+		sim.event(m.Time, "Node [%s] has joined", m.Node)
+	}
+
+	sim.asg.AddMetrics(m.Node, NewMetricSeries(NewHealthMetric(m.Value, m.Time)))
+}
+
+// report healthy metrics of simulated nodes which have booted, they report
+// in between ticks as real nodes do
+func (sim *simulation) report(t time.Time) {
+	reportedAt := t.Add(-sim.Tick / 2)
+	for id, sn := range sim.simulated {
+		if _, ok := sim.asg.Nodes[id]; !ok || sn.broken || sn.healthyAt.After(reportedAt) {
+			continue
+		}
+
+		sim.asg.AddMetrics(id, NewMetricSeries(NewHealthMetric(1, reportedAt)))
+	}
+}
+
+// execute commands created by policies against simulated provider
+func (sim *simulation) execute(t time.Time) {
+	keys := []int{}
+	for k := range sim.asg.Commands {
+		keys = append(keys, int(k))
+	}
+	sort.Ints(keys)
+
+	for _, k := range keys {
+		switch cmd := sim.asg.Commands[Order(k)].(type) {
+		case *Launch:
+			sim.launch(t, cmd.Provider, cmd.Reason)
+		case *Relaunch:
+			sim.replace(t, cmd.NodeID, cmd.Provider, cmd.Reason)
+		case *Terminate:
+			sim.terminate(t, cmd.NodeID, cmd.Reason)
+		default:
+			sim.event(t, "Command %T is not simulated", cmd)
+		}
+	}
+
+	sim.asg.Commands = NewCommandSet()
+}
+
+// launch simulated node which becomes healthy after boot delay unless it fails
+func (sim *simulation) launch(t time.Time, provider Provider, reason string) {
+	sim.launched++
+	id := ID(fmt.Sprintf("simulated-%d", sim.launched))
+
+	node := NewNode()
+	node.Setup(id, provider, NetworkInterface{}, NetworkInterface{})
+	node.LaunchedAt = t
+	sim.asg.AddNode(node)
+
+	sn := &simulatedNode{healthyAt: t.Add(sim.Provider.BootDelay), broken: sim.Provider.fails()}
+	sim.simulated[id] = sn
+
+	sim.result.Launches++
+	if sn.broken {
+		sim.result.FailedLaunches++
+		sim.event(t, "Node [%s] launched and will never boot: %s", id, reason)
+		return
+	}
+	sim.event(t, "Node [%s] launched: %s", id, reason)
+}
+
+// replace node with new one, recorded node is followed to find out if it
+// would have recovered on its own
+func (sim *simulation) replace(t time.Time, nodeID ID, provider Provider, reason string) {
+	sim.result.Replacements++
+	sim.event(t, "Node [%s] replaced: %s", nodeID, reason)
+	sim.launch(t, provider, reason)
+
+	if _, ok := sim.simulated[nodeID]; !ok {
+		if threshold, ok := sim.threshold(provider); ok {
+			sim.replaced[nodeID] = &replacedNode{at: t, threshold: threshold}
+		}
+	}
+	sim.removed[nodeID] = true
+	sim.asg.RemoveNode(nodeID)
+}
+
+// terminate node
+func (sim *simulation) terminate(t time.Time, nodeID ID, reason string) {
+	sim.result.Terminations++
+	sim.event(t, "Node [%s] terminated: %s", nodeID, reason)
+
+	sim.removed[nodeID] = true
+	sim.asg.RemoveNode(nodeID)
+}
+
+// measure capacity after tick and track how long it takes to recover
+func (sim *simulation) measure(t time.Time) {
+	point := SimulationPoint{Time: t, Nodes: len(sim.asg.Nodes)}
+	for _, node := range sim.asg.Nodes {
+		switch node.State {
+		case NodeStateInService:
+			point.InService++
+		case NodeStatePending:
+			point.Pending++
+		case NodeStateUnhealthy:
+			point.Unhealthy++
+		}
+	}
+
+	for _, p := range sim.asg.Policies {
+		switch plc := p.(type) {
+		case *DesiredHealthyNodeAmountPerProviderPolicy:
+			point.Desired += plc.Desired
+		case *BacklogPerInstancePolicy:
+			point.Desired += plc.Desired
+		}
+	}
+	sim.result.Timeline = append(sim.result.Timeline, point)
+
+	if point.InService >= point.Desired {
+		if !sim.degradedSince.IsZero() {
+			ttr := t.Sub(sim.degradedSince)
+			sim.result.TimesToRecover = append(sim.result.TimesToRecover, ttr)
+			sim.event(t, "Capacity has recovered after %s", ttr)
+			sim.degradedSince = time.Time{}
+		}
+		sim.reachedDesired = true
+		return
+	}
+
+	// Fleet which has not reached desired capacity yet is still joining
+	if sim.reachedDesired && sim.degradedSince.IsZero() {
+		sim.degradedSince = t
+		sim.event(t, "Healthy nodes %d are less than desired %d", point.InService, point.Desired)
+	}
+}
+
+// summarize times to recover
+func (sim *simulation) summarize() {
+	total := time.Duration(0)
+	for _, ttr := range sim.result.TimesToRecover {
+		total = total + ttr
+		if ttr > sim.result.MaxTimeToRecover {
+			sim.result.MaxTimeToRecover = ttr
+		}
+	}
+
+	if len(sim.result.TimesToRecover) > 0 {
+		sim.result.MeanTimeToRecover = total / time.Duration(len(sim.result.TimesToRecover))
+	}
+}
+
+// threshold of health policy of given provider
+func (sim *simulation) threshold(provider Provider) (float64, bool) {
+	for _, p := range sim.asg.Policies {
+		if plc, ok := p.(*DesiredHealthyNodeAmountPerProviderPolicy); ok && plc.Provider.ID == provider.ID {
+			return plc.HealthyThreshold, true
+		}
+	}
+
+	return 0, false
+}
+
+func (sim *simulation) event(t time.Time, format string, args ...interface{}) {
+	sim.result.Events = append(sim.result.Events, NodeEvent{
+		Time:    t,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// policyProvider returns provider nodes of given policy belong to
+func policyProvider(p Policy) (Provider, bool) {
+	switch plc := p.(type) {
+	case *DesiredHealthyNodeAmountPerProviderPolicy:
+		return plc.Provider, true
+	case *BacklogPerInstancePolicy:
+		return plc.Provider, true
+	}
+
+	return Provider{}, false
+}
@@ -0,0 +1,101 @@
+package domain
+
+import (
+	"bytes"
+	"strings"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type SimulatorSuite struct{}
+
+var _ = Suite(&SimulatorSuite{})
+
+// recordMetrics of three nodes reporting every 5 seconds, node2 reports
+// unhealthy between given offsets
+func recordMetrics(duration, failFrom, failTo time.Duration) []RecordedMetric {
+	start := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	metrics := []RecordedMetric{}
+	for d := time.Duration(0); d <= duration; d = d + 5*time.Second {
+		for _, id := range []ID{"node1", "node2", "node3"} {
+			value := 1.0
+			if id == "node2" && d >= failFrom && d < failTo {
+				value = 0
+			}
+			metrics = append(metrics, RecordedMetric{Node: id, Time: start.Add(d), Value: value})
+		}
+	}
+
+	return metrics
+}
+
+func prepareSimulator(c *C, failureRate float64) *Simulator {
+	plc, err := NewDesiredNodeAmountPerProviderPolicy(ID("policy-1"), 1, 3, 3, 3, 0.7, time.Duration(-10*time.Second), Provider{ID: testProviderID})
+	c.Assert(err, IsNil)
+
+	provider, err := NewSimulatedProvider(30*time.Second, failureRate, 1)
+	c.Assert(err, IsNil)
+
+	sim, err := NewSimulator(NewPolicySet(plc), provider, 5*time.Second)
+	c.Assert(err, IsNil)
+	sim.HealthCheckGracePeriod = time.Minute
+
+	return sim
+}
+
+func (s *SimulatorSuite) TestIfSimulatorReplaysMetricsAndMeasuresRecovery(c *C) {
+	sim := prepareSimulator(c, 0)
+	log := &bytes.Buffer{}
+	sim.Log = log
+
+	result, err := sim.Run(recordMetrics(5*time.Minute, time.Minute, 2*time.Minute))
+	c.Assert(err, IsNil)
+
+	c.Assert(strings.Contains(log.String(), "Add node [node1]"), Equals, true)
+	c.Assert(result.Replacements, Equals, 1)
+	c.Assert(result.Launches, Equals, 1)
+	c.Assert(result.FailedLaunches, Equals, 0)
+	c.Assert(result.Terminations, Equals, 0)
+	// node2 has reported healthy again after a minute
+	c.Assert(result.FalsePositives, Equals, 1)
+	c.Assert(len(result.TimesToRecover), Equals, 1)
+	c.Assert(result.TimesToRecover[0] >= 30*time.Second, Equals, true)
+	c.Assert(result.MaxTimeToRecover, Equals, result.TimesToRecover[0])
+
+	last := result.Timeline[len(result.Timeline)-1]
+	c.Assert(last.InService, Equals, 3)
+	c.Assert(last.Desired, Equals, 3)
+
+	// Policies of simulator are not changed, so it can be run again
+	again, err := sim.Run(recordMetrics(5*time.Minute, time.Minute, 2*time.Minute))
+	c.Assert(err, IsNil)
+	c.Assert(again.Replacements, Equals, 1)
+}
+
+func (s *SimulatorSuite) TestIfSimulatorKeepsReplacingNodesWhichNeverBoot(c *C) {
+	sim := prepareSimulator(c, 1)
+
+	result, err := sim.Run(recordMetrics(5*time.Minute, time.Minute, 10*time.Minute))
+	c.Assert(err, IsNil)
+
+	c.Assert(result.FailedLaunches, Equals, result.Launches)
+	c.Assert(result.Replacements > 1, Equals, true)
+	c.Assert(result.FalsePositives, Equals, 0)
+	c.Assert(len(result.TimesToRecover), Equals, 0)
+}
+
+func (s *SimulatorSuite) TestIfRecordedMetricsAreReadFromJSONLines(c *C) {
+	input := `{"Node":"node1","Time":"2016-01-01T00:00:00Z","Value":1}
+
+{"Node":"node2","Time":"2016-01-01T00:00:05Z","Value":0.5}
+`
+	metrics, err := ReadRecordedMetrics(strings.NewReader(input))
+	c.Assert(err, IsNil)
+	c.Assert(len(metrics), Equals, 2)
+	c.Assert(metrics[1].Node, Equals, ID("node2"))
+	c.Assert(metrics[1].Value, Equals, 0.5)
+
+	_, err = ReadRecordedMetrics(strings.NewReader(`{"Time":"2016-01-01T00:00:00Z","Value":1}`))
+	c.Assert(err, ErrorMatches, "Line 1 has no node")
+}
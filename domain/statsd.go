@@ -0,0 +1,426 @@
+package domain
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+const (
+	StatsdGauge   = StatsdType("g")
+	StatsdCounter = StatsdType("c")
+
+	// StatsdASGTag and StatsdNodeTag identify node line is reported for,
+	// all other tags become labels of metric
+	StatsdASGTag  = "asg"
+	StatsdNodeTag = "node"
+
+	statsdPacketSize = 65535
+
+	defaultStatsdGaugeExpiry = 10 * time.Minute
+)
+
+type (
+	StatsdType string
+
+	// StatsdLine is single metric of StatsD packet, e.g.
+	// `cpu:42|g|#asg:my-asg,node:node1,core:0`
+	StatsdLine struct {
+		Name MetricType
+		Type StatsdType
+		// Value of gauge which starts with sign changes previous value
+		Value      float64
+		Relative   bool
+		SampleRate float64
+		ASG        ID
+		Node       ID
+		Labels     Labels
+	}
+
+	// StatsdStats are totals since listener has been started
+	StatsdStats struct {
+		Packets   uint64
+		Lines     uint64
+		Malformed uint64
+		// Dropped are aggregated metrics of ASG or node which does not exist
+		Dropped uint64
+	}
+
+	// StatsdListener receives StatsD lines over UDP, aggregates them per
+	// FlushInterval and hands them over to ASG loop which adds them to nodes
+	// the same way as metrics API does
+	StatsdListener struct {
+		Address       string
+		FlushInterval time.Duration
+		Supervisor    *MultiSupervisor
+		// GaugeExpiry is how long last value of gauge which is not reported
+		// anymore is kept, relative gauge starts from zero after that
+		GaugeExpiry time.Duration
+
+		conn   *net.UDPConn
+		stop   chan bool
+		lock   sync.Mutex
+		series map[string]*statsdSeries
+		// gauges keep last value between flushes, relative gauges change it
+		gauges map[string]*statsdGauge
+
+		packets   uint64
+		lines     uint64
+		malformed uint64
+		dropped   uint64
+	}
+
+	statsdSeries struct {
+		key    string
+		asg    ID
+		node   ID
+		name   MetricType
+		kind   StatsdType
+		labels Labels
+		value  float64
+	}
+
+	// statsdGauge was updated at last flush it has been reported in
+	statsdGauge struct {
+		value   float64
+		updated time.Time
+	}
+)
+
+// NewStatsdListener constructor, address is host:port of UDP socket
+func NewStatsdListener(address string, flushInterval time.Duration, supervisor *MultiSupervisor) (*StatsdListener, error) {
+	if address == "" {
+		return nil, errors.Errorf("Address of StatsD listener is required")
+	}
+
+	if flushInterval <= 0 {
+		return nil, errors.Errorf("FlushInterval %s has to be more than 0", flushInterval)
+	}
+
+	if supervisor == nil {
+		return nil, errors.Errorf("Supervisor of StatsD listener is required")
+	}
+
+	return &StatsdListener{
+		Address:       address,
+		FlushInterval: flushInterval,
+		Supervisor:    supervisor,
+		GaugeExpiry:   defaultStatsdGaugeExpiry,
+		series:        map[string]*statsdSeries{},
+		gauges:        map[string]*statsdGauge{},
+	}, nil
+}
+
+// Listen opens UDP socket and starts receiving and flushing in background
+func (sl *StatsdListener) Listen() error {
+	addr, err := net.ResolveUDPAddr("udp", sl.Address)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	sl.conn = conn
+	sl.stop = make(chan bool)
+
+	go sl.receive()
+	go sl.flushEvery()
+
+	return nil
+}
+
+// LocalAddr returns address listener is bound to, nil if it is not listening
+func (sl *StatsdListener) LocalAddr() net.Addr {
+	if sl.conn == nil {
+		return nil
+	}
+
+	return sl.conn.LocalAddr()
+}
+
+// Close socket, whatever has not been flushed yet is flushed
+func (sl *StatsdListener) Close() error {
+	if sl.conn == nil {
+		return nil
+	}
+
+	close(sl.stop)
+	err := sl.conn.Close()
+	sl.Flush(time.Now())
+
+	return err
+}
+
+// Stats of listener
+func (sl *StatsdListener) Stats() StatsdStats {
+	return StatsdStats{
+		Packets:   atomic.LoadUint64(&sl.packets),
+		Lines:     atomic.LoadUint64(&sl.lines),
+		Malformed: atomic.LoadUint64(&sl.malformed),
+		Dropped:   atomic.LoadUint64(&sl.dropped),
+	}
+}
+
+// Handle packet of newline separated lines, malformed lines are counted and
+// dropped, the rest of packet is still taken
+func (sl *StatsdListener) Handle(packet []byte) {
+	atomic.AddUint64(&sl.packets, 1)
+
+	for _, text := range strings.Split(string(packet), "\n") {
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+
+		atomic.AddUint64(&sl.lines, 1)
+		line, err := ParseStatsdLine(text)
+		if err != nil {
+			atomic.AddUint64(&sl.malformed, 1)
+			continue
+		}
+
+		sl.add(line)
+	}
+}
+
+// Flush aggregated metrics to ASGs, counters are sums of interval and
+// gauges are their last value. Metrics are added to nodes by ASG loop, those
+// of ASG or node which does not exist are counted as dropped once it runs
+func (sl *StatsdListener) Flush(now time.Time) {
+	sl.lock.Lock()
+	series := sl.series
+	sl.series = map[string]*statsdSeries{}
+	for _, s := range series {
+		if g, ok := sl.gauges[s.key]; ok {
+			g.updated = now
+		}
+	}
+	for key, g := range sl.gauges {
+		if now.Sub(g.updated) > sl.GaugeExpiry {
+			delete(sl.gauges, key)
+		}
+	}
+	sl.lock.Unlock()
+
+	grouped := map[ID]map[ID][]*statsdSeries{}
+	for _, s := range series {
+		if grouped[s.asg] == nil {
+			grouped[s.asg] = map[ID][]*statsdSeries{}
+		}
+		grouped[s.asg][s.node] = append(grouped[s.asg][s.node], s)
+	}
+
+	for asgID, nodes := range grouped {
+		asg := sl.Supervisor.Get(asgID)
+		for nodeID, nodeSeries := range nodes {
+			metrics := []NamedMetric{}
+			for _, s := range nodeSeries {
+				unit := ""
+				if s.kind == StatsdCounter {
+					unit = "count"
+				}
+				metrics = append(metrics, NewNamedMetric(s.name, unit, s.labels, s.value, now))
+			}
+
+			dropped := sl.dropper(asgID, nodeID, nodeSeries)
+			switch {
+			case asg == nil:
+				dropped(errors.Errorf("ASG was not found"))
+			case asg.Credentials != nil:
+				// StatsD carries no token, so node could report for any other
+				dropped(errors.Errorf("ASG requires node tokens"))
+			default:
+				asg.QueueNamedMetrics(nodeID, metrics, dropped)
+			}
+		}
+	}
+}
+
+// dropper returns func which counts series of node as dropped if they were
+// not added, gauges of node which does not exist are not kept
+func (sl *StatsdListener) dropper(asgID, nodeID ID, nodeSeries []*statsdSeries) func(error) {
+	return func(err error) {
+		if err == nil {
+			return
+		}
+
+		fmt.Printf("StatsD metrics of node [%s] of [%s] are dropped : %s \n", nodeID, asgID, err)
+		atomic.AddUint64(&sl.dropped, uint64(len(nodeSeries)))
+
+		sl.lock.Lock()
+		for _, s := range nodeSeries {
+			delete(sl.gauges, s.key)
+		}
+		sl.lock.Unlock()
+	}
+}
+
+func (sl *StatsdListener) add(line StatsdLine) {
+	key := string(line.ASG) + "|" + string(line.Node) + "|" + string(line.Name) + "|" + string(line.Type) + "|" + line.Labels.String()
+
+	sl.lock.Lock()
+	defer sl.lock.Unlock()
+
+	s, ok := sl.series[key]
+	if !ok {
+		s = &statsdSeries{
+			key:    key,
+			asg:    line.ASG,
+			node:   line.Node,
+			name:   line.Name,
+			kind:   line.Type,
+			labels: line.Labels,
+		}
+		sl.series[key] = s
+	}
+
+	switch line.Type {
+	case StatsdCounter:
+		s.value = s.value + line.Value/line.SampleRate
+	case StatsdGauge:
+		g, ok := sl.gauges[key]
+		if !ok {
+			g = &statsdGauge{}
+			sl.gauges[key] = g
+		}
+		if line.Relative {
+			g.value = g.value + line.Value
+		} else {
+			g.value = line.Value
+		}
+		s.value = g.value
+	}
+}
+
+// receive packets until socket is closed, packets are only parsed here so
+// slow ASG does not hold up the socket
+func (sl *StatsdListener) receive() {
+	buf := make([]byte, statsdPacketSize)
+	for {
+		n, _, err := sl.conn.ReadFromUDP(buf)
+		if err != nil {
+			select {
+			case <-sl.stop:
+				return
+			default:
+			}
+
+			fmt.Printf("StatsD listener could not read packet : %s \n", err)
+			continue
+		}
+
+		sl.Handle(buf[:n])
+	}
+}
+
+func (sl *StatsdListener) flushEvery() {
+	ticker := time.NewTicker(sl.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-sl.stop:
+			return
+		case now := <-ticker.C:
+			sl.Flush(now)
+		}
+	}
+}
+
+// ParseStatsdLine parses `name:value|type[|@rate][|#tag:value,...]`, tags
+// can also be given in name as `name,tag=value,...`. Tags asg and node are required
+func ParseStatsdLine(text string) (StatsdLine, error) {
+	line := StatsdLine{SampleRate: 1, Labels: Labels{}}
+
+	colon := strings.LastIndex(strings.SplitN(text, "|", 2)[0], ":")
+	if colon <= 0 {
+		return line, errors.Errorf("Line [%s] has no value", text)
+	}
+
+	name := text[:colon]
+	parts := strings.Split(text[colon+1:], "|")
+	if len(parts) < 2 {
+		return line, errors.Errorf("Line [%s] has no type", text)
+	}
+
+	// Tags in name, e.g. influx style `cpu,asg=my-asg,node=node1`
+	nameParts := strings.Split(name, ",")
+	line.Name = MetricType(nameParts[0])
+	for _, tag := range nameParts[1:] {
+		if err := addStatsdTag(&line, tag, "="); err != nil {
+			return line, err
+		}
+	}
+
+	value := parts[0]
+	line.Type = StatsdType(parts[1])
+	switch line.Type {
+	case StatsdCounter:
+	case StatsdGauge:
+		line.Relative = strings.HasPrefix(value, "+") || strings.HasPrefix(value, "-")
+	default:
+		return line, errors.Errorf("Type [%s] of line [%s] is not supported", line.Type, text)
+	}
+
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return line, errors.Errorf("Value [%s] of line [%s] is not a number", value, text)
+	}
+	line.Value = v
+
+	for _, part := range parts[2:] {
+		switch {
+		case strings.HasPrefix(part, "@"):
+			rate, err := strconv.ParseFloat(part[1:], 64)
+			if err != nil || rate <= 0 || rate > 1 {
+				return line, errors.Errorf("Sample rate [%s] of line [%s] has to be more than 0 and not more than 1", part[1:], text)
+			}
+			line.SampleRate = rate
+		case strings.HasPrefix(part, "#"):
+			for _, tag := range strings.Split(part[1:], ",") {
+				if err := addStatsdTag(&line, tag, ":"); err != nil {
+					return line, err
+				}
+			}
+		default:
+			return line, errors.Errorf("Part [%s] of line [%s] is not supported", part, text)
+		}
+	}
+
+	if line.Name == "" {
+		return line, errors.Errorf("Line [%s] has no name", text)
+	}
+
+	if line.ASG == "" || line.Node == "" {
+		return line, errors.Errorf("Line [%s] has to be tagged with %s and %s", text, StatsdASGTag, StatsdNodeTag)
+	}
+
+	return line, nil
+}
+
+func addStatsdTag(line *StatsdLine, tag, separator string) error {
+	kv := strings.SplitN(tag, separator, 2)
+	if len(kv) != 2 || kv[0] == "" {
+		return errors.Errorf("Tag [%s] has no value", tag)
+	}
+
+	switch kv[0] {
+	case StatsdASGTag:
+		line.ASG = ID(kv[1])
+	case StatsdNodeTag:
+		line.Node = ID(kv[1])
+	default:
+		line.Labels[kv[0]] = kv[1]
+	}
+
+	return nil
+}
@@ -0,0 +1,153 @@
+package domain
+
+import (
+	"net"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type StatsdSuite struct{}
+
+var _ = Suite(&StatsdSuite{})
+
+// prepareStatsdListener returns listener of supervisor which has ASG with node1
+func prepareStatsdListener(c *C) (*StatsdListener, *AutoScalingGroup, *Node) {
+	node := prepareLocalNode(ID("node1"))
+	asg := NewAutoScalingGroup(ID("asg-1"))
+	asg.Setup(NewNodeSet(node), NewPolicySet())
+
+	supervisor := MakeMultiSupervisor()
+	supervisor.add(asg)
+
+	sl, err := NewStatsdListener("127.0.0.1:0", time.Hour, supervisor)
+	c.Assert(err, IsNil)
+
+	return sl, asg, node
+}
+
+func (s *StatsdSuite) TestIfStatsdLinesAreParsed(c *C) {
+	line, err := ParseStatsdLine("cpu:42.5|g|#asg:asg-1,node:node1,core:0")
+	c.Assert(err, IsNil)
+	c.Assert(line, DeepEquals, StatsdLine{
+		Name:       "cpu",
+		Type:       StatsdGauge,
+		Value:      42.5,
+		SampleRate: 1,
+		ASG:        "asg-1",
+		Node:       "node1",
+		Labels:     Labels{"core": "0"},
+	})
+
+	line, err = ParseStatsdLine("requests,asg=asg-1,node=node1:3|c|@0.5")
+	c.Assert(err, IsNil)
+	c.Assert(line.Name, Equals, MetricType("requests"))
+	c.Assert(line.SampleRate, Equals, 0.5)
+	c.Assert(line.ASG, Equals, ID("asg-1"))
+
+	line, err = ParseStatsdLine("queue:-2|g|#asg:asg-1,node:node1")
+	c.Assert(err, IsNil)
+	c.Assert(line.Relative, Equals, true)
+	c.Assert(line.Value, Equals, -2.0)
+
+	for _, text := range []string{
+		"cpu:42|g",
+		"cpu:high|g|#asg:asg-1,node:node1",
+		"cpu:42|ms|#asg:asg-1,node:node1",
+		"cpu:42|c|@2|#asg:asg-1,node:node1",
+		"cpu42|g|#asg:asg-1,node:node1",
+		":42|g|#asg:asg-1,node:node1",
+		"cpu:42|g|#asg:asg-1,node",
+	} {
+		_, err = ParseStatsdLine(text)
+		c.Assert(err, NotNil, Commentf("Line %s", text))
+	}
+}
+
+func (s *StatsdSuite) TestIfStatsdMetricsAreAggregatedPerFlush(c *C) {
+	sl, asg, node := prepareStatsdListener(c)
+
+	sl.Handle([]byte("requests:1|c|#asg:asg-1,node:node1\nrequests:2|c|@0.5|#asg:asg-1,node:node1\n" +
+		"cpu:40|g|#asg:asg-1,node:node1\ncpu:60|g|#asg:asg-1,node:node1\nbroken\n" +
+		"cpu:1|g|#asg:asg-2,node:node1\ncpu:1|g|#asg:asg-1,node:node2"))
+	sl.Handle([]byte("queue:10|g|#asg:asg-1,node:node1\nqueue:+5|g|#asg:asg-1,node:node1"))
+
+	now := time.Now()
+	sl.Flush(now)
+
+	// Nodes are changed only by ASG loop
+	from, to := now.Add(-time.Second), now.Add(time.Second)
+	c.Assert(len(node.QueryMetrics("requests", nil, from, to)), Equals, 0)
+	asg.addQueuedMetrics()
+
+	requests := node.QueryMetrics("requests", nil, from, to)
+	c.Assert(len(requests), Equals, 1)
+	c.Assert(requests[0].GetValue(), Equals, 5.0)
+
+	cpu := node.QueryMetrics("cpu", nil, from, to)
+	c.Assert(len(cpu), Equals, 1)
+	c.Assert(cpu[0].GetValue(), Equals, 60.0)
+
+	c.Assert(sl.Stats(), DeepEquals, StatsdStats{Packets: 2, Lines: 9, Malformed: 1, Dropped: 2})
+
+	// Gauge keeps its value between flushes, counter starts from zero
+	sl.Handle([]byte("queue:-3|g|#asg:asg-1,node:node1\nrequests:1|c|#asg:asg-1,node:node1"))
+	sl.Flush(now.Add(10 * time.Second))
+	asg.addQueuedMetrics()
+
+	queue := node.QueryMetrics("queue", nil, from, to.Add(10*time.Second))
+	c.Assert(len(queue), Equals, 2)
+	c.Assert(queue[0].GetValue(), Equals, 15.0)
+	c.Assert(queue[1].GetValue(), Equals, 12.0)
+
+	requests = node.QueryMetrics("requests", nil, from, to.Add(10*time.Second))
+	c.Assert(requests[1].GetValue(), Equals, 1.0)
+}
+
+func (s *StatsdSuite) TestIfStatsdListenerReceivesPacketsOverUDP(c *C) {
+	sl, asg, node := prepareStatsdListener(c)
+	c.Assert(sl.Listen(), IsNil)
+
+	conn, err := net.Dial("udp", sl.LocalAddr().String())
+	c.Assert(err, IsNil)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("health:1|g|#asg:asg-1,node:node1"))
+	c.Assert(err, IsNil)
+	_, err = conn.Write([]byte("\x00\xff garbage"))
+	c.Assert(err, IsNil)
+
+	for i := 0; i < 100 && sl.Stats().Lines < 2; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	c.Assert(sl.Close(), IsNil)
+	asg.addQueuedMetrics()
+	c.Assert(sl.Stats().Malformed, Equals, uint64(1))
+	c.Assert(node.Metrics.Filter(HealthMetricType, time.Now().Add(-time.Minute), time.Now().Add(time.Second)).Average(), Equals, 1.0)
+}
+
+func (s *StatsdSuite) TestIfIdleStatsdGaugesExpire(c *C) {
+	sl, asg, node := prepareStatsdListener(c)
+	sl.GaugeExpiry = time.Minute
+
+	now := time.Now()
+	sl.Handle([]byte("queue:10|g|#asg:asg-1,node:node1\nqueue:1|g|#asg:asg-1,node:node1,shard:1"))
+	sl.Flush(now)
+	c.Assert(len(sl.gauges), Equals, 2)
+
+	// Only gauge which is still reported is kept
+	sl.Handle([]byte("queue:+1|g|#asg:asg-1,node:node1,shard:1"))
+	sl.Flush(now.Add(30 * time.Second))
+	sl.Flush(now.Add(80 * time.Second))
+	c.Assert(len(sl.gauges), Equals, 1)
+
+	sl.Handle([]byte("queue:+5|g|#asg:asg-1,node:node1"))
+	sl.Flush(now.Add(3 * time.Minute))
+	asg.addQueuedMetrics()
+
+	at := now.Add(3 * time.Minute)
+	queue := node.QueryMetrics("queue", nil, at.Add(-time.Second), at.Add(time.Second))
+	c.Assert(len(queue), Equals, 1)
+	c.Assert(queue[0].GetValue(), Equals, 5.0)
+}
@@ -0,0 +1,292 @@
+package domain
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+const (
+	// StreamHealthSource is health reported over agent stream, connection
+	// which drops is recorded as 0 right away
+	StreamHealthSource = HealthSource("stream")
+
+	AgentIntervalCommand = AgentCommandType("interval")
+	AgentDrainCommand    = AgentCommandType("drain")
+
+	defaultStreamInterval = 5 * time.Second
+	// missedHeartbeats after which session is considered dropped
+	missedHeartbeats = 3
+	// agentCommandBuffer commands can wait for agent, sending more fails
+	agentCommandBuffer = 16
+)
+
+type (
+	AgentCommandType string
+
+	// AgentCommand is sent by artemis to agent over its stream
+	AgentCommand struct {
+		Type AgentCommandType
+		// Interval agent has to report with, only set for interval command
+		Interval time.Duration
+	}
+
+	// AgentSession is long lived stream of node agent. Agent sends heartbeats
+	// and metrics over it and receives commands back
+	AgentSession struct {
+		Node     ID
+		Opened   time.Time
+		Interval time.Duration
+
+		asg           *AutoScalingGroup
+		lock          sync.Mutex
+		lastHeartbeat time.Time
+		commands      chan AgentCommand
+		done          chan struct{}
+		closed        bool
+	}
+
+	// AgentSessionStatus is snapshot of session
+	AgentSessionStatus struct {
+		Node          ID
+		Opened        time.Time
+		Interval      time.Duration
+		LastHeartbeat time.Time
+	}
+
+	// agentSessions of ASG, node has at most one
+	agentSessions struct {
+		lock     sync.Mutex
+		sessions map[ID]*AgentSession
+	}
+)
+
+// OpenAgentSession opens stream of given node, previous session of node is
+// replaced. Agent is asked to report every interval, 5 seconds by default
+func (asg *AutoScalingGroup) OpenAgentSession(node ID, interval time.Duration, now time.Time) (*AgentSession, error) {
+	// Node is looked up between cycles of ASG loop
+	asg.cycle.Lock()
+	state, found := asg.State, asg.Nodes.GetByID(node) != nil
+	asg.cycle.Unlock()
+
+	if state == ASGStateNew {
+		return nil, errors.Errorf("ASG is in ASGStateNew state, use Setup() first!")
+	}
+
+	if !found {
+		return nil, errors.Errorf("Node by ID %s was not found", node)
+	}
+
+	if interval == 0 {
+		interval = defaultStreamInterval
+	}
+
+	if interval < 0 {
+		return nil, errors.Errorf("Interval %s can not be negative", interval)
+	}
+
+	session := &AgentSession{
+		Node:          node,
+		Opened:        now,
+		Interval:      interval,
+		asg:           asg,
+		lastHeartbeat: now,
+		commands:      make(chan AgentCommand, agentCommandBuffer),
+		done:          make(chan struct{}),
+	}
+	session.commands <- AgentCommand{Type: AgentIntervalCommand, Interval: interval}
+
+	asg.streams.lock.Lock()
+	previous := asg.streams.sessions[node]
+	asg.streams.sessions[node] = session
+	asg.streams.lock.Unlock()
+
+	// Replaced session is not a dropped connection, node is still there
+	if previous != nil {
+		previous.finish()
+	}
+
+	return session, nil
+}
+
+// SendAgentCommand to agent of given node, it fails if node has no open
+// stream or agent does not keep up with commands
+func (asg *AutoScalingGroup) SendAgentCommand(node ID, cmd AgentCommand) error {
+	switch cmd.Type {
+	case AgentIntervalCommand:
+		if cmd.Interval <= 0 {
+			return errors.Errorf("Interval %s has to be more than 0", cmd.Interval)
+		}
+	case AgentDrainCommand:
+	default:
+		return errors.Errorf("Agent command [%s] is not supported", cmd.Type)
+	}
+
+	asg.streams.lock.Lock()
+	session := asg.streams.sessions[node]
+	asg.streams.lock.Unlock()
+
+	if session == nil {
+		return errors.Errorf("Node [%s] has no open stream", node)
+	}
+
+	return session.send(cmd)
+}
+
+// AgentSessions returns open streams sorted by node
+func (asg *AutoScalingGroup) AgentSessions() []AgentSessionStatus {
+	asg.streams.lock.Lock()
+	defer asg.streams.lock.Unlock()
+
+	rez := []AgentSessionStatus{}
+	for _, id := range sortedAgentNodes(asg.streams.sessions) {
+		rez = append(rez, asg.streams.sessions[id].Status())
+	}
+
+	return rez
+}
+
+// closeAgentSession of removed node, it is not recorded as dropped
+func (asg *AutoScalingGroup) closeAgentSession(node ID) {
+	if asg.streams == nil {
+		return
+	}
+
+	asg.streams.lock.Lock()
+	session := asg.streams.sessions[node]
+	delete(asg.streams.sessions, node)
+	asg.streams.lock.Unlock()
+
+	if session != nil {
+		session.finish()
+	}
+}
+
+// Commands agent has to receive
+func (s *AgentSession) Commands() <-chan AgentCommand {
+	return s.commands
+}
+
+// Done is closed when session is closed or replaced
+func (s *AgentSession) Done() <-chan struct{} {
+	return s.done
+}
+
+// Heartbeat of agent, health is recorded as health metric of stream source
+// together with metrics which came with it. They are handed over to ASG loop,
+// metrics which it can not add are only logged
+func (s *AgentSession) Heartbeat(health float64, metrics []NamedMetric, now time.Time) error {
+	if s.isClosed() {
+		return errors.Errorf("Stream of node [%s] is closed", s.Node)
+	}
+
+	s.lock.Lock()
+	s.lastHeartbeat = now
+	s.lock.Unlock()
+
+	s.asg.queueMetrics(s.Node, []Metric{NewHealthMetricFrom(health, now, StreamHealthSource)}, s.logFailure)
+	if len(metrics) > 0 {
+		s.asg.QueueNamedMetrics(s.Node, metrics, s.logFailure)
+	}
+
+	return nil
+}
+
+func (s *AgentSession) logFailure(err error) {
+	if err != nil {
+		s.asg.logf("[%s] Metrics of stream of node [%s] were not added : %s \n", s.asg.ID, s.Node, err)
+	}
+}
+
+// Expired returns true if agent has missed 3 heartbeats in a row
+func (s *AgentSession) Expired(now time.Time) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return now.Sub(s.lastHeartbeat) > missedHeartbeats*s.Interval
+}
+
+// Close session because connection has dropped, node is recorded unhealthy
+// by stream source on next cycle of ASG loop. Closing replaced session does nothing
+func (s *AgentSession) Close(now time.Time) {
+	s.asg.streams.lock.Lock()
+	current := s.asg.streams.sessions[s.Node] == s
+	if current {
+		delete(s.asg.streams.sessions, s.Node)
+	}
+	s.asg.streams.lock.Unlock()
+
+	if !current {
+		return
+	}
+
+	s.finish()
+	s.asg.queueMetrics(s.Node, []Metric{NewHealthMetricFrom(0, now, StreamHealthSource)}, nil)
+}
+
+// Status of session
+func (s *AgentSession) Status() AgentSessionStatus {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return AgentSessionStatus{
+		Node:          s.Node,
+		Opened:        s.Opened,
+		Interval:      s.Interval,
+		LastHeartbeat: s.lastHeartbeat,
+	}
+}
+
+func (s *AgentSession) send(cmd AgentCommand) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.closed {
+		return errors.Errorf("Stream of node [%s] is closed", s.Node)
+	}
+
+	select {
+	case s.commands <- cmd:
+	default:
+		return errors.Errorf("Agent of node [%s] has %d commands pending", s.Node, len(s.commands))
+	}
+
+	if cmd.Type == AgentIntervalCommand {
+		s.Interval = cmd.Interval
+	}
+
+	return nil
+}
+
+func (s *AgentSession) finish() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if !s.closed {
+		s.closed = true
+		close(s.done)
+	}
+}
+
+func (s *AgentSession) isClosed() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.closed
+}
+
+func newAgentSessions() *agentSessions {
+	return &agentSessions{sessions: map[ID]*AgentSession{}}
+}
+
+func sortedAgentNodes(sessions map[ID]*AgentSession) []ID {
+	ids := []ID{}
+	for id := range sessions {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	return ids
+}
@@ -0,0 +1,111 @@
+package domain
+
+import (
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type StreamSuite struct{}
+
+var _ = Suite(&StreamSuite{})
+
+func prepareStreamAsg() (*AutoScalingGroup, *Node) {
+	node := prepareLocalNode(ID("node1"))
+	asg := NewAutoScalingGroup(ID("asg-1"))
+	asg.Setup(NewNodeSet(node), NewPolicySet())
+
+	return asg, node
+}
+
+func streamHealth(node *Node, from, to time.Time) []Metric {
+	return node.Metrics.Filter(HealthMetricType, from, to).FilterHealthSource(StreamHealthSource).List()
+}
+
+func (s *StreamSuite) TestIfHeartbeatsAreRecordedAndDroppedStreamTurnsNodeUnhealthy(c *C) {
+	asg, node := prepareStreamAsg()
+	now := time.Now()
+
+	_, err := asg.OpenAgentSession(ID("node2"), 0, now)
+	c.Assert(err, NotNil)
+
+	session, err := asg.OpenAgentSession(ID("node1"), 0, now)
+	c.Assert(err, IsNil)
+	c.Assert(session.Interval, Equals, defaultStreamInterval)
+	c.Assert(<-session.Commands(), DeepEquals, AgentCommand{Type: AgentIntervalCommand, Interval: defaultStreamInterval})
+
+	c.Assert(session.Heartbeat(1, []NamedMetric{NewNamedMetric("cpu", "", nil, 42, now)}, now), IsNil)
+	// Heartbeat is added by ASG loop
+	c.Assert(node.LastReportedAt.IsZero(), Equals, true)
+	asg.addQueuedMetrics()
+	c.Assert(node.LastReportedAt.IsZero(), Equals, false)
+	c.Assert(len(node.QueryMetrics("cpu", nil, now.Add(-time.Second), now.Add(time.Second))), Equals, 1)
+	c.Assert(asg.Telemetry().AgentStreams, Equals, 1)
+
+	c.Assert(session.Expired(now.Add(15*time.Second)), Equals, false)
+	c.Assert(session.Expired(now.Add(16*time.Second)), Equals, true)
+
+	session.Close(now.Add(time.Second))
+	c.Assert(len(asg.AgentSessions()), Equals, 0)
+	c.Assert(session.Heartbeat(1, nil, now.Add(2*time.Second)), NotNil)
+	asg.addQueuedMetrics()
+
+	health := streamHealth(node, now.Add(-time.Second), now.Add(time.Minute))
+	c.Assert(len(health), Equals, 2)
+	c.Assert(health[1].GetValue(), Equals, 0.0)
+
+	// Last value of stream counts, not average of heartbeats
+	model, err := NewHealthModel(HealthModeAll, 0, []HealthSignal{{Source: StreamHealthSource, Weight: 1}}, 0)
+	c.Assert(err, IsNil)
+	c.Assert(model.Evaluate(node, 0.5, now.Add(-time.Second), now.Add(time.Minute)), Equals, false)
+	c.Assert(model.Evaluate(node, 0.5, now.Add(-time.Second), now.Add(time.Millisecond)), Equals, true)
+}
+
+func (s *StreamSuite) TestIfReplacedOrRemovedStreamIsNotRecordedAsDropped(c *C) {
+	asg, node := prepareStreamAsg()
+	now := time.Now()
+
+	first, err := asg.OpenAgentSession(ID("node1"), time.Second, now)
+	c.Assert(err, IsNil)
+	second, err := asg.OpenAgentSession(ID("node1"), time.Second, now)
+	c.Assert(err, IsNil)
+
+	<-first.Done()
+	first.Close(now)
+	asg.addQueuedMetrics()
+	c.Assert(asg.AgentSessions(), HasLen, 1)
+	c.Assert(streamHealth(node, now.Add(-time.Second), now.Add(time.Second)), HasLen, 0)
+
+	c.Assert(asg.RemoveNode(ID("node1")), IsNil)
+	<-second.Done()
+	second.Close(now)
+	asg.addQueuedMetrics()
+	c.Assert(asg.AgentSessions(), HasLen, 0)
+	c.Assert(streamHealth(node, now.Add(-time.Second), now.Add(time.Second)), HasLen, 0)
+}
+
+func (s *StreamSuite) TestIfCommandsAreSentToAgent(c *C) {
+	asg, _ := prepareStreamAsg()
+
+	c.Assert(asg.SendAgentCommand(ID("node1"), AgentCommand{Type: AgentDrainCommand}), NotNil)
+
+	session, err := asg.OpenAgentSession(ID("node1"), time.Second, time.Now())
+	c.Assert(err, IsNil)
+	<-session.Commands()
+
+	c.Assert(asg.SendAgentCommand(ID("node1"), AgentCommand{Type: "reboot"}), NotNil)
+	c.Assert(asg.SendAgentCommand(ID("node1"), AgentCommand{Type: AgentIntervalCommand}), NotNil)
+
+	c.Assert(asg.SendAgentCommand(ID("node1"), AgentCommand{Type: AgentIntervalCommand, Interval: 10 * time.Second}), IsNil)
+	c.Assert(session.Status().Interval, Equals, 10*time.Second)
+	c.Assert(asg.SendAgentCommand(ID("node1"), AgentCommand{Type: AgentDrainCommand}), IsNil)
+
+	c.Assert(<-session.Commands(), DeepEquals, AgentCommand{Type: AgentIntervalCommand, Interval: 10 * time.Second})
+	c.Assert(<-session.Commands(), DeepEquals, AgentCommand{Type: AgentDrainCommand})
+
+	// Agent which does not read its commands is not waited for
+	for i := 0; i < agentCommandBuffer; i++ {
+		c.Assert(asg.SendAgentCommand(ID("node1"), AgentCommand{Type: AgentDrainCommand}), IsNil)
+	}
+	c.Assert(asg.SendAgentCommand(ID("node1"), AgentCommand{Type: AgentDrainCommand}), NotNil)
+}
@@ -1,6 +1,9 @@
 package domain
 
 import (
+	"net"
+	"strconv"
+	"sync"
 	"testing"
 
 	. "gopkg.in/check.v1"
@@ -10,3 +13,115 @@ import (
 func TestAllSuite(t *testing.T) {
 	TestingT(t)
 }
+
+const testProviderID = "test"
+
+// testDriver records calls instead of talking to provider API
+type testDriver struct {
+	sync.Mutex
+	created    []ID
+	deleted    []ID
+	poweredOn  []ID
+	poweredOff []ID
+	statuses   map[ID]string
+	actions    []string
+	// onAction is called after reboot, power cycle or rebuild
+	onAction func(action string, id ID)
+	// createErr is returned by Create when set
+	createErr error
+	// publicIP of created nodes, 192.100.10.1 if not set
+	publicIP net.IP
+}
+
+// registerTestDriver registers new testDriver for testProviderID
+func registerTestDriver() *testDriver {
+	drv := &testDriver{}
+	RegisterDriver(testProviderID, func(Provider) Driver {
+		return drv
+	})
+
+	return drv
+}
+
+func (d *testDriver) Create() (*Node, error) {
+	d.Lock()
+	defer d.Unlock()
+
+	if d.createErr != nil {
+		return nil, d.createErr
+	}
+
+	id := ID("test-" + strconv.Itoa(len(d.created)+1))
+	d.created = append(d.created, id)
+
+	publicIP := d.publicIP
+	if publicIP == nil {
+		publicIP = net.ParseIP("192.100.10.1")
+	}
+
+	node := NewNode()
+	node.Setup(
+		id,
+		Provider{ID: testProviderID},
+		NetworkInterface{IP: net.ParseIP("10.0.0.1")},
+		NetworkInterface{IP: publicIP},
+	)
+
+	return node, nil
+}
+
+func (d *testDriver) Delete(id ID) error {
+	d.Lock()
+	defer d.Unlock()
+
+	d.deleted = append(d.deleted, id)
+	return nil
+}
+
+func (d *testDriver) PowerOn(id ID) error {
+	d.Lock()
+	defer d.Unlock()
+
+	d.poweredOn = append(d.poweredOn, id)
+	return nil
+}
+
+func (d *testDriver) PowerOff(id ID) error {
+	d.Lock()
+	defer d.Unlock()
+
+	d.poweredOff = append(d.poweredOff, id)
+	return nil
+}
+
+func (d *testDriver) Statuses() (map[ID]string, error) {
+	d.Lock()
+	defer d.Unlock()
+
+	return d.statuses, nil
+}
+
+func (d *testDriver) Reboot(id ID) error {
+	return d.act("reboot", id)
+}
+
+func (d *testDriver) PowerCycle(id ID) error {
+	return d.act("power-cycle", id)
+}
+
+func (d *testDriver) Rebuild(id ID) error {
+	return d.act("rebuild", id)
+}
+
+func (d *testDriver) act(action string, id ID) error {
+	d.Lock()
+	d.actions = append(d.actions, action+":"+string(id))
+	onAction := d.onAction
+	d.Unlock()
+
+	if onAction != nil {
+		onAction(action, id)
+	}
+
+	return nil
+}
@@ -0,0 +1,376 @@
+package domain
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// LaunchBuckets are upper bounds in seconds of launch duration histogram
+	LaunchBuckets = []float64{5, 10, 30, 60, 120, 300, 600, 1200}
+	// EvaluationBuckets are upper bounds in seconds of evaluation duration histogram
+	EvaluationBuckets = []float64{.001, .005, .01, .05, .1, .5, 1, 5}
+	// ProviderCallBuckets are upper bounds in seconds of provider API call latency histogram
+	ProviderCallBuckets = []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60, 120}
+
+	providerCalls = &providerTelemetry{
+		calls: map[providerCallKey]*ProviderCallTelemetry{},
+	}
+)
+
+type (
+	// Histogram of observed values, Counts are cumulative, Counts[i] is amount
+	// of values less or equal to Bounds[i]
+	Histogram struct {
+		Bounds []float64
+		Counts []uint64
+		Count  uint64
+		Sum    float64
+	}
+
+	// ASGTelemetry is operational state of ASG at the time it was taken
+	ASGTelemetry struct {
+		ID                                   ID
+		Desired, Current, Healthy, Unhealthy int
+		// CommandsExecuted and CommandsFailed by command type, e.g. Launch
+		CommandsExecuted   map[string]uint64
+		CommandsFailed     map[string]uint64
+		LaunchDuration     Histogram
+		EvaluationDuration Histogram
+		MetricsIngested    uint64
+		// AgentStreams are open streams of node agents
+		AgentStreams int
+	}
+
+	// ProviderCallTelemetry of single provider API call, e.g. Create
+	ProviderCallTelemetry struct {
+		Provider string
+		Call     string
+		Count    uint64
+		Errors   uint64
+		Latency  Histogram
+	}
+
+	// telemetry is collected by ASG while it runs
+	telemetry struct {
+		sync.Mutex
+		commandsExecuted   map[string]uint64
+		commandsFailed     map[string]uint64
+		launchDuration     Histogram
+		evaluationDuration Histogram
+		metricsIngested    uint64
+		// nodes are counts of policies and nodes taken by ASG loop, HTTP
+		// handlers must not walk Nodes and Policies which the loop changes
+		nodes nodeCounts
+	}
+
+	nodeCounts struct {
+		desired, current, healthy, unhealthy int
+	}
+
+	providerTelemetry struct {
+		sync.Mutex
+		calls map[providerCallKey]*ProviderCallTelemetry
+	}
+
+	providerCallKey struct {
+		provider string
+		call     string
+	}
+
+	// instrumentedDriver measures calls of driver it wraps
+	instrumentedDriver struct {
+		driver   Driver
+		provider string
+	}
+)
+
+// NewHistogram constructor, bounds have to be sorted
+func NewHistogram(bounds ...float64) Histogram {
+	return Histogram{
+		Bounds: append([]float64{}, bounds...),
+		Counts: make([]uint64, len(bounds)),
+	}
+}
+
+// Observe value
+func (h *Histogram) Observe(value float64) {
+	for i, bound := range h.Bounds {
+		if value <= bound {
+			h.Counts[i]++
+		}
+	}
+	h.Count++
+	h.Sum = h.Sum + value
+}
+
+func (h Histogram) clone() Histogram {
+	h.Bounds = append([]float64{}, h.Bounds...)
+	h.Counts = append([]uint64{}, h.Counts...)
+
+	return h
+}
+
+func newTelemetry() *telemetry {
+	return &telemetry{
+		commandsExecuted:   map[string]uint64{},
+		commandsFailed:     map[string]uint64{},
+		launchDuration:     NewHistogram(LaunchBuckets...),
+		evaluationDuration: NewHistogram(EvaluationBuckets...),
+	}
+}
+
+// Methods are safe to call on nil, ASG which is only planned collects nothing
+
+func (t *telemetry) commandExecuted(cmd Command, err error) {
+	if t == nil {
+		return
+	}
+
+	t.Lock()
+	defer t.Unlock()
+
+	name := commandType(cmd)
+	t.commandsExecuted[name]++
+	if err != nil {
+		t.commandsFailed[name]++
+	}
+}
+
+func (t *telemetry) launched(d time.Duration) {
+	if t == nil {
+		return
+	}
+
+	t.Lock()
+	t.launchDuration.Observe(d.Seconds())
+	t.Unlock()
+}
+
+func (t *telemetry) evaluated(d time.Duration) {
+	if t == nil {
+		return
+	}
+
+	t.Lock()
+	t.evaluationDuration.Observe(d.Seconds())
+	t.Unlock()
+}
+
+func (t *telemetry) ingested(amount int) {
+	if t == nil {
+		return
+	}
+
+	t.Lock()
+	t.metricsIngested = t.metricsIngested + uint64(amount)
+	t.Unlock()
+}
+
+// observeNodes takes counts of policies and nodes, it has to be called by
+// goroutine which changes them
+func (asg *AutoScalingGroup) observeNodes() {
+	t := asg.telemetry
+	if t == nil {
+		return
+	}
+
+	counts := nodeCounts{}
+	for _, policy := range asg.Policies {
+		switch p := policy.(type) {
+		case *DesiredHealthyNodeAmountPerProviderPolicy:
+			counts.desired = counts.desired + p.Desired
+		case *BacklogPerInstancePolicy:
+			counts.desired = counts.desired + p.Desired
+		}
+	}
+
+	for _, node := range asg.Nodes {
+		switch node.State {
+		case NodeStateTerminated, NodeStateDeleted, NodeStateWarm:
+			continue
+		case NodeStateInService:
+			counts.healthy++
+		case NodeStateUnhealthy:
+			counts.unhealthy++
+		}
+		counts.current++
+	}
+
+	t.Lock()
+	t.nodes = counts
+	t.Unlock()
+}
+
+// Telemetry returns operational state of ASG, node counts are those taken
+// by ASG after it was set up or executed commands last time, counters are
+// totals since ASG has been created. It is safe to call while ASG runs.
+func (asg *AutoScalingGroup) Telemetry() ASGTelemetry {
+	rez := ASGTelemetry{
+		ID:                 asg.ID,
+		CommandsExecuted:   map[string]uint64{},
+		CommandsFailed:     map[string]uint64{},
+		LaunchDuration:     NewHistogram(LaunchBuckets...),
+		EvaluationDuration: NewHistogram(EvaluationBuckets...),
+	}
+
+	if asg.streams != nil {
+		rez.AgentStreams = len(asg.AgentSessions())
+	}
+
+	t := asg.telemetry
+	if t == nil {
+		return rez
+	}
+
+	t.Lock()
+	defer t.Unlock()
+
+	rez.Desired = t.nodes.desired
+	rez.Current = t.nodes.current
+	rez.Healthy = t.nodes.healthy
+	rez.Unhealthy = t.nodes.unhealthy
+
+	for name, num := range t.commandsExecuted {
+		rez.CommandsExecuted[name] = num
+	}
+
+	for name, num := range t.commandsFailed {
+		rez.CommandsFailed[name] = num
+	}
+
+	rez.LaunchDuration = t.launchDuration.clone()
+	rez.EvaluationDuration = t.evaluationDuration.clone()
+	rez.MetricsIngested = t.metricsIngested
+
+	return rez
+}
+
+// ProviderCalls returns telemetry of every provider API call made so far,
+// sorted by provider and call
+func ProviderCalls() []ProviderCallTelemetry {
+	providerCalls.Lock()
+	defer providerCalls.Unlock()
+
+	rez := []ProviderCallTelemetry{}
+	for _, call := range providerCalls.calls {
+		c := *call
+		c.Latency = call.Latency.clone()
+		rez = append(rez, c)
+	}
+
+	sort.Slice(rez, func(i, j int) bool {
+		if rez[i].Provider != rez[j].Provider {
+			return rez[i].Provider < rez[j].Provider
+		}
+		return rez[i].Call < rez[j].Call
+	})
+
+	return rez
+}
+
+func (pt *providerTelemetry) observe(provider, call string, d time.Duration, err error) {
+	pt.Lock()
+	defer pt.Unlock()
+
+	key := providerCallKey{provider: provider, call: call}
+	c, ok := pt.calls[key]
+	if !ok {
+		c = &ProviderCallTelemetry{
+			Provider: provider,
+			Call:     call,
+			Latency:  NewHistogram(ProviderCallBuckets...),
+		}
+		pt.calls[key] = c
+	}
+
+	c.Count++
+	if err != nil {
+		c.Errors++
+	}
+	c.Latency.Observe(d.Seconds())
+}
+
+// commandType returns name of command type without package, e.g. Launch
+func commandType(cmd Command) string {
+	return strings.TrimPrefix(fmt.Sprintf("%T", cmd), "*domain.")
+}
+
+func (d *instrumentedDriver) measure(call string, start time.Time, err error) {
+	providerCalls.observe(d.provider, call, time.Since(start), err)
+}
+
+// Create ...
+func (d *instrumentedDriver) Create() (*Node, error) {
+	start := time.Now()
+	node, err := d.driver.Create()
+	d.measure("Create", start, err)
+
+	return node, err
+}
+
+// Delete ...
+func (d *instrumentedDriver) Delete(id ID) error {
+	start := time.Now()
+	err := d.driver.Delete(id)
+	d.measure("Delete", start, err)
+
+	return err
+}
+
+// PowerOn ...
+func (d *instrumentedDriver) PowerOn(id ID) error {
+	start := time.Now()
+	err := d.driver.PowerOn(id)
+	d.measure("PowerOn", start, err)
+
+	return err
+}
+
+// PowerOff ...
+func (d *instrumentedDriver) PowerOff(id ID) error {
+	start := time.Now()
+	err := d.driver.PowerOff(id)
+	d.measure("PowerOff", start, err)
+
+	return err
+}
+
+// Reboot ...
+func (d *instrumentedDriver) Reboot(id ID) error {
+	start := time.Now()
+	err := d.driver.Reboot(id)
+	d.measure("Reboot", start, err)
+
+	return err
+}
+
+// PowerCycle ...
+func (d *instrumentedDriver) PowerCycle(id ID) error {
+	start := time.Now()
+	err := d.driver.PowerCycle(id)
+	d.measure("PowerCycle", start, err)
+
+	return err
+}
+
+// Rebuild ...
+func (d *instrumentedDriver) Rebuild(id ID) error {
+	start := time.Now()
+	err := d.driver.Rebuild(id)
+	d.measure("Rebuild", start, err)
+
+	return err
+}
+
+// Statuses ...
+func (d *instrumentedDriver) Statuses() (map[ID]string, error) {
+	start := time.Now()
+	statuses, err := d.driver.Statuses()
+	d.measure("Statuses", start, err)
+
+	return statuses, err
+}
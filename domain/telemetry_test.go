@@ -0,0 +1,93 @@
+package domain
+
+import (
+	"errors"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type TelemetrySuite struct{}
+
+var _ = Suite(&TelemetrySuite{})
+
+func (s *TelemetrySuite) TestIfHistogramCountsAreCumulative(c *C) {
+	h := NewHistogram(1, 5)
+	h.Observe(0.5)
+	h.Observe(3)
+	h.Observe(10)
+
+	c.Assert(h.Counts, DeepEquals, []uint64{1, 2})
+	c.Assert(h.Count, Equals, uint64(3))
+	c.Assert(h.Sum, Equals, 13.5)
+}
+
+func (s *TelemetrySuite) TestIfASGCollectsTelemetryWhileItRuns(c *C) {
+	drv := registerTestDriver()
+	drv.createErr = errors.New("quota is exceeded")
+
+	node := prepareLocalNode(ID("node1"))
+	node.ChangeState(NodeStateInService)
+
+	plc, err := NewDesiredNodeAmountPerProviderPolicy(ID("policy-1"), 1, 3, 2, 1, 0.7, time.Duration(-5*time.Second), Provider{ID: testProviderID})
+	c.Assert(err, IsNil)
+
+	asg := NewAutoScalingGroup(ID("asg-1"))
+	asg.Setup(NewNodeSet(node), NewPolicySet(plc))
+	c.Assert(asg.AddMetrics(node.ID, prepareMetrics(0, 5)), IsNil)
+	c.Assert(asg.AddGroupMetrics(prepareBacklog(10, 3)), IsNil)
+
+	t := asg.Telemetry()
+	c.Assert(t.Desired, Equals, 2)
+	c.Assert(t.Current, Equals, 1)
+	c.Assert(t.Healthy, Equals, 1)
+	c.Assert(t.Unhealthy, Equals, 0)
+	c.Assert(t.MetricsIngested, Equals, uint64(8))
+
+	c.Assert(asg.Evaluate(), IsNil)
+	c.Assert(asg.Execute(), NotNil)
+
+	t = asg.Telemetry()
+	c.Assert(t.EvaluationDuration.Count, Equals, uint64(1))
+	c.Assert(t.CommandsExecuted, DeepEquals, map[string]uint64{"Launch": 1})
+	c.Assert(t.CommandsFailed, DeepEquals, map[string]uint64{"Launch": 1})
+	c.Assert(t.LaunchDuration.Count, Equals, uint64(0))
+
+	found := false
+	for _, call := range ProviderCalls() {
+		if call.Provider == testProviderID && call.Call == "Create" {
+			found = true
+			c.Assert(call.Errors > 0, Equals, true)
+			c.Assert(call.Latency.Count, Equals, call.Count)
+		}
+	}
+	c.Assert(found, Equals, true)
+
+	// Copy which is only planned does not count
+	_, err = asg.Plan(nil)
+	c.Assert(err, IsNil)
+	c.Assert(asg.Telemetry().EvaluationDuration.Count, Equals, uint64(1))
+}
+
+func (s *TelemetrySuite) TestIfNodeCountsAreTakenWhenASGExecutes(c *C) {
+	registerTestDriver()
+
+	node := prepareLocalNode(ID("node1"))
+	node.ChangeState(NodeStateInService)
+
+	asg := NewAutoScalingGroup(ID("asg-1"))
+	asg.Setup(NewNodeSet(node), NewPolicySet())
+	c.Assert(asg.Telemetry().Healthy, Equals, 1)
+
+	// Reader does not walk nodes which are being changed
+	node.ChangeState(NodeStateUnhealthy)
+	t := asg.Telemetry()
+	c.Assert(t.Healthy, Equals, 1)
+	c.Assert(t.Unhealthy, Equals, 0)
+
+	c.Assert(asg.Execute(), IsNil)
+	t = asg.Telemetry()
+	c.Assert(t.Healthy, Equals, 0)
+	c.Assert(t.Unhealthy, Equals, 1)
+	c.Assert(t.Current, Equals, 1)
+}
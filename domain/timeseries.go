@@ -0,0 +1,494 @@
+package domain
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+// DefaultRetention keeps raw metrics for a minute, an hour of 1 minute rollups
+// and a day of 1 hour rollups
+var DefaultRetention = Retention{
+	Raw:       time.Minute,
+	RawPoints: 600,
+	Minutes:   60,
+	Hours:     24,
+	MaxSeries: 100,
+}
+
+type (
+	// Retention of metric store, memory used by every series is bounded by
+	// RawPoints, Minutes and Hours and amount of series by MaxSeries
+	Retention struct {
+		// Raw is how long raw metrics are kept
+		Raw time.Duration
+		// RawPoints is how many raw metrics are kept at most
+		RawPoints int
+		// Minutes and Hours are amounts of rollups kept
+		Minutes int
+		Hours   int
+		// MaxSeries is amount of different metric names and labels kept
+		MaxSeries int
+	}
+
+	// Rollup summarizes metrics of single minute or hour
+	Rollup struct {
+		Time  time.Time
+		Count int
+		Sum   float64
+		Min   float64
+		Max   float64
+	}
+
+	// MetricStore keeps metrics of node or ASG in ring buffers, one per
+	// metric name and labels. Every metric is rolled up into 1 minute and
+	// 1 hour rollups as well, so they outlive raw metrics
+	MetricStore struct {
+		sync.RWMutex
+		Retention Retention
+
+		series map[string]*storedSeries
+	}
+
+	// SeriesLimitError is returned by MetricStore when metrics of new series
+	// were rejected, Rejected are their positions in metrics which were added
+	SeriesLimitError struct {
+		Rejected  []int
+		MaxSeries int
+	}
+
+	// storedSeries of single metric name and labels
+	storedSeries struct {
+		metricType MetricType
+		unit       string
+		labels     Labels
+		raw        sampleRing
+		minutes    rollupLevel
+		hours      rollupLevel
+	}
+
+	// sample is raw metric as it is kept by store, it is smaller than any
+	// Metric and is not allocated on its own
+	sample struct {
+		unix   int64
+		value  float64
+		source HealthSource
+	}
+
+	// sampleRing keeps samples in order of their time
+	sampleRing struct {
+		samples []sample
+		head    int
+		size    int
+	}
+
+	// rollupLevel keeps rollups of given resolution, the last one is still open
+	rollupLevel struct {
+		resolution time.Duration
+		rollups    []Rollup
+		head       int
+		size       int
+	}
+)
+
+// NewMetricStore constructor, zero retention is DefaultRetention
+func NewMetricStore(retention Retention) *MetricStore {
+	if retention == (Retention{}) {
+		retention = DefaultRetention
+	}
+
+	return &MetricStore{
+		Retention: retention,
+		series:    map[string]*storedSeries{},
+	}
+}
+
+// Validate retention
+func (r Retention) Validate() error {
+	if r.Raw <= 0 {
+		return errors.Errorf("Raw retention %s has to be more than 0", r.Raw)
+	}
+
+	if r.RawPoints <= 0 || r.Minutes <= 0 || r.Hours <= 0 || r.MaxSeries <= 0 {
+		return errors.Errorf("RawPoints %d, Minutes %d, Hours %d and MaxSeries %d have to be more than 0", r.RawPoints, r.Minutes, r.Hours, r.MaxSeries)
+	}
+
+	return nil
+}
+
+// GetValue returns avg value of rollup
+func (r Rollup) GetValue() float64 {
+	if r.Count == 0 {
+		return 0
+	}
+
+	return r.Sum / float64(r.Count)
+}
+
+// GetTimestamp returns start of rollup
+func (r Rollup) GetTimestamp() time.Time {
+	return r.Time
+}
+
+// Add metrics to their series, raw metrics older than Raw retention at given
+// time are dropped. SeriesLimitError is returned if there were more series
+// than allowed, metrics of other series are still added
+func (ms *MetricStore) Add(now time.Time, metrics ...Metric) error {
+	ms.Lock()
+	defer ms.Unlock()
+
+	if ms.series == nil {
+		ms.series = map[string]*storedSeries{}
+	}
+
+	rejected := []int{}
+	for i, m := range metrics {
+		key, metricType, unit, labels, source := describeMetric(m)
+		s, ok := ms.series[key]
+		if !ok {
+			if len(ms.series) >= ms.Retention.MaxSeries {
+				rejected = append(rejected, i)
+				continue
+			}
+
+			s = &storedSeries{
+				metricType: metricType,
+				unit:       unit,
+				labels:     labels,
+				raw:        newSampleRing(ms.Retention.RawPoints),
+				minutes:    newRollupLevel(time.Minute, ms.Retention.Minutes),
+				hours:      newRollupLevel(time.Hour, ms.Retention.Hours),
+			}
+			ms.series[key] = s
+		}
+
+		t := m.GetTimestamp()
+		s.raw.push(sample{unix: t.UnixNano(), value: m.GetValue(), source: source})
+		s.minutes.add(t, m.GetValue())
+		s.hours.add(t, m.GetValue())
+	}
+
+	required := now.Add(-ms.Retention.Raw).UnixNano()
+	for _, s := range ms.series {
+		s.raw.dropBefore(required)
+	}
+
+	if len(rejected) > 0 {
+		return &SeriesLimitError{Rejected: rejected, MaxSeries: ms.Retention.MaxSeries}
+	}
+
+	return nil
+}
+
+func (e *SeriesLimitError) Error() string {
+	return fmt.Sprintf("%d metrics were rejected, store has reached %d series", len(e.Rejected), e.MaxSeries)
+}
+
+// Query returns raw metrics of given name reported between from and to, sorted
+// by time. Labels are only matched by named metrics
+func (ms *MetricStore) Query(metricType MetricType, labels Labels, from, to time.Time) []Metric {
+	rez := []Metric{}
+	if ms == nil {
+		return rez
+	}
+
+	ms.RLock()
+	defer ms.RUnlock()
+
+	fromUnix, toUnix := from.UnixNano(), to.UnixNano()
+	for _, s := range ms.series {
+		if s.metricType != metricType || !s.labels.Matches(labels) {
+			continue
+		}
+
+		for i := 0; i < s.raw.size; i++ {
+			smp := s.raw.at(i)
+			if smp.unix > fromUnix && smp.unix < toUnix {
+				rez = append(rez, s.toMetric(smp))
+			}
+		}
+	}
+	sortMetrics(rez)
+
+	return rez
+}
+
+// Filter returns raw metrics of given type reported between from and to as series
+func (ms *MetricStore) Filter(metricType MetricType, from, to time.Time) MetricSeries {
+	return NewMetricSeries(ms.Query(metricType, nil, from, to)...)
+}
+
+// Rollups returns rollups of given resolution, either time.Minute or time.Hour,
+// which started between from and to. Rollups of series with the same name are
+// merged
+func (ms *MetricStore) Rollups(metricType MetricType, labels Labels, resolution time.Duration, from, to time.Time) []Rollup {
+	rez := []Rollup{}
+	if ms == nil {
+		return rez
+	}
+
+	ms.RLock()
+	defer ms.RUnlock()
+
+	merged := map[time.Time]Rollup{}
+	for _, s := range ms.series {
+		if s.metricType != metricType || !s.labels.Matches(labels) {
+			continue
+		}
+
+		level := s.minutes
+		if resolution == time.Hour {
+			level = s.hours
+		}
+
+		for i := 0; i < level.size; i++ {
+			r := level.at(i)
+			if r.Time.Before(from) || !r.Time.Before(to) {
+				continue
+			}
+
+			if m, ok := merged[r.Time]; ok {
+				r = m.merge(r)
+			}
+			merged[r.Time] = r
+		}
+	}
+
+	for _, r := range merged {
+		rez = append(rez, r)
+	}
+	sort.Slice(rez, func(i, j int) bool {
+		return rez[i].Time.Before(rez[j].Time)
+	})
+
+	return rez
+}
+
+// Len returns amount of raw metrics kept
+func (ms *MetricStore) Len() int {
+	if ms == nil {
+		return 0
+	}
+
+	ms.RLock()
+	defer ms.RUnlock()
+
+	total := 0
+	for _, s := range ms.series {
+		total = total + s.raw.size
+	}
+
+	return total
+}
+
+// Reset removes all metrics
+func (ms *MetricStore) Reset() {
+	ms.Lock()
+	defer ms.Unlock()
+
+	ms.series = map[string]*storedSeries{}
+}
+
+// SetRetention changes retention, series are resized to fit it
+func (ms *MetricStore) SetRetention(retention Retention) {
+	ms.Lock()
+	defer ms.Unlock()
+
+	if retention == (Retention{}) {
+		retention = DefaultRetention
+	}
+
+	if ms.Retention == retention {
+		return
+	}
+
+	ms.Retention = retention
+	for _, s := range ms.series {
+		s.raw = s.raw.resize(retention.RawPoints)
+		s.minutes = s.minutes.resize(retention.Minutes)
+		s.hours = s.hours.resize(retention.Hours)
+	}
+}
+
+// Clone returns copy of store which can be changed without affecting original
+func (ms *MetricStore) Clone() *MetricStore {
+	if ms == nil {
+		return nil
+	}
+
+	ms.RLock()
+	defer ms.RUnlock()
+
+	clone := NewMetricStore(ms.Retention)
+	for key, s := range ms.series {
+		c := *s
+		c.raw = s.raw.resize(len(s.raw.samples))
+		c.minutes = s.minutes.resize(len(s.minutes.rollups))
+		c.hours = s.hours.resize(len(s.hours.rollups))
+		clone.series[key] = &c
+	}
+
+	return clone
+}
+
+// toMetric returns sample as metric of the type it was added as
+func (s *storedSeries) toMetric(smp sample) Metric {
+	t := time.Unix(0, smp.unix)
+	switch s.metricType {
+	case HealthMetricType:
+		return NewHealthMetricFrom(smp.value, t, smp.source)
+	case BacklogMetricType:
+		return NewBacklogMetric(smp.value, t)
+	}
+
+	return NewNamedMetric(s.metricType, s.unit, s.labels, smp.value, t)
+}
+
+// describeMetric returns key of series given metric belongs to and what is
+// needed to restore it
+func describeMetric(m Metric) (string, MetricType, string, Labels, HealthSource) {
+	switch v := m.(type) {
+	case HealthMetric:
+		return string(HealthMetricType), HealthMetricType, "", nil, v.Source
+	case BacklogMetric:
+		return string(BacklogMetricType), BacklogMetricType, "", nil, ""
+	case NamedMetric:
+		return string(v.Name) + v.Labels.String(), v.Name, v.Unit, v.Labels, ""
+	}
+
+	return "", "", "", nil, ""
+}
+
+func newSampleRing(capacity int) sampleRing {
+	return sampleRing{samples: make([]sample, capacity)}
+}
+
+// at returns i-th oldest sample
+func (sr *sampleRing) at(i int) sample {
+	return sr.samples[(sr.head+i)%len(sr.samples)]
+}
+
+// push sample, the oldest one is overwritten if ring is full. Sample which is
+// older than the newest one is put in its place
+func (sr *sampleRing) push(smp sample) {
+	capacity := len(sr.samples)
+	if sr.size == capacity {
+		if smp.unix < sr.at(0).unix {
+			return
+		}
+		sr.head = (sr.head + 1) % capacity
+		sr.size--
+	}
+
+	i := sr.size
+	for i > 0 && sr.at(i-1).unix > smp.unix {
+		sr.samples[(sr.head+i)%capacity] = sr.at(i - 1)
+		i--
+	}
+	sr.samples[(sr.head+i)%capacity] = smp
+	sr.size++
+}
+
+// dropBefore removes samples older than given time
+func (sr *sampleRing) dropBefore(unix int64) {
+	for sr.size > 0 && sr.at(0).unix < unix {
+		sr.head = (sr.head + 1) % len(sr.samples)
+		sr.size--
+	}
+}
+
+// resize ring, the newest samples are kept
+func (sr sampleRing) resize(capacity int) sampleRing {
+	resized := newSampleRing(capacity)
+	from := 0
+	if sr.size > capacity {
+		from = sr.size - capacity
+	}
+
+	for i := from; i < sr.size; i++ {
+		resized.samples[resized.size] = sr.at(i)
+		resized.size++
+	}
+
+	return resized
+}
+
+func newRollupLevel(resolution time.Duration, capacity int) rollupLevel {
+	return rollupLevel{resolution: resolution, rollups: make([]Rollup, capacity)}
+}
+
+// at returns i-th oldest rollup
+func (rl *rollupLevel) at(i int) Rollup {
+	return rl.rollups[(rl.head+i)%len(rl.rollups)]
+}
+
+// add value to rollup of its time, value older than the oldest rollup is dropped
+func (rl *rollupLevel) add(t time.Time, value float64) {
+	start := t.Truncate(rl.resolution)
+	capacity := len(rl.rollups)
+
+	for i := rl.size - 1; i >= 0; i-- {
+		idx := (rl.head + i) % capacity
+		r := rl.rollups[idx]
+		if r.Time.Equal(start) {
+			rl.rollups[idx] = r.merge(Rollup{Time: start, Count: 1, Sum: value, Min: value, Max: value})
+			return
+		}
+
+		if r.Time.Before(start) {
+			if i != rl.size-1 {
+				// Late value falls between rollups, it is dropped
+				return
+			}
+			break
+		}
+	}
+
+	if rl.size > 0 && start.Before(rl.at(0).Time) {
+		return
+	}
+
+	if rl.size == capacity {
+		rl.head = (rl.head + 1) % capacity
+		rl.size--
+	}
+	rl.rollups[(rl.head+rl.size)%capacity] = Rollup{Time: start, Count: 1, Sum: value, Min: value, Max: value}
+	rl.size++
+}
+
+// resize level, the newest rollups are kept
+func (rl rollupLevel) resize(capacity int) rollupLevel {
+	resized := newRollupLevel(rl.resolution, capacity)
+	from := 0
+	if rl.size > capacity {
+		from = rl.size - capacity
+	}
+
+	for i := from; i < rl.size; i++ {
+		resized.rollups[resized.size] = rl.at(i)
+		resized.size++
+	}
+
+	return resized
+}
+
+// merge two rollups of the same time
+func (r Rollup) merge(other Rollup) Rollup {
+	if r.Count == 0 {
+		return other
+	}
+
+	r.Count = r.Count + other.Count
+	r.Sum = r.Sum + other.Sum
+	if other.Min < r.Min {
+		r.Min = other.Min
+	}
+	if other.Max > r.Max {
+		r.Max = other.Max
+	}
+
+	return r
+}
@@ -0,0 +1,140 @@
+package domain
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type TimeSeriesSuite struct{}
+
+var _ = Suite(&TimeSeriesSuite{})
+
+func (s *TimeSeriesSuite) TestIfStoreKeepsMetricsWithinRetention(c *C) {
+	store := NewMetricStore(Retention{Raw: time.Minute, RawPoints: 10, Minutes: 2, Hours: 1, MaxSeries: 2})
+
+	start := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 20; i++ {
+		t := start.Add(time.Duration(i) * time.Second)
+		c.Assert(store.Add(t, NewHealthMetric(float64(i), t)), IsNil)
+	}
+
+	// Only RawPoints newest metrics are kept
+	metrics := store.Query(HealthMetricType, nil, start.Add(-time.Second), start.Add(time.Minute))
+	c.Assert(len(metrics), Equals, 10)
+	c.Assert(metrics[0].GetValue(), Equals, 10.0)
+	c.Assert(metrics[9].GetValue(), Equals, 19.0)
+
+	// Metrics with identical time are both kept, late one is put in its place
+	t := start.Add(15 * time.Second)
+	c.Assert(store.Add(start.Add(19*time.Second), NewHealthMetric(100, t)), IsNil)
+	metrics = store.Query(HealthMetricType, nil, t.Add(-time.Second), t.Add(time.Second))
+	c.Assert(len(metrics), Equals, 2)
+
+	// Raw metrics older than retention are dropped, rollups outlive them
+	c.Assert(store.Add(start.Add(2*time.Minute), NewHealthMetric(1, start.Add(2*time.Minute))), IsNil)
+	c.Assert(store.Len(), Equals, 1)
+
+	rollups := store.Rollups(HealthMetricType, nil, time.Minute, start, start.Add(time.Hour))
+	c.Assert(len(rollups), Equals, 2)
+	c.Assert(rollups[0].Time, Equals, start)
+	c.Assert(rollups[0].Count, Equals, 21)
+	c.Assert(rollups[0].Min, Equals, 0.0)
+	c.Assert(rollups[0].Max, Equals, 100.0)
+	c.Assert(rollups[1].Count, Equals, 1)
+
+	hours := store.Rollups(HealthMetricType, nil, time.Hour, start, start.Add(time.Hour))
+	c.Assert(len(hours), Equals, 1)
+	c.Assert(hours[0].Count, Equals, 22)
+
+	// New series are rejected when there are too many of them
+	c.Assert(store.Add(start, NewNamedMetric("cpu", "", nil, 1, start)), IsNil)
+	c.Assert(store.Add(start, NewNamedMetric("memory", "", nil, 1, start)), ErrorMatches, "1 metrics were rejected, store has reached 2 series")
+
+	// Only metrics of new series are rejected, their positions are reported
+	err := store.Add(start, NewNamedMetric("disk", "", nil, 1, start), NewNamedMetric("cpu", "", nil, 2, start))
+	c.Assert(err, DeepEquals, &SeriesLimitError{Rejected: []int{0}, MaxSeries: 2})
+	c.Assert(store.Rollups("cpu", nil, time.Minute, start, start.Add(time.Hour))[0].Count, Equals, 2)
+}
+
+func (s *TimeSeriesSuite) TestIfRetentionCanBeChanged(c *C) {
+	node := prepareLocalNode(ID("node1"))
+	node.AddMetrics(prepareMetrics(0, 5))
+
+	asg := NewAutoScalingGroup(ID("asg-1"))
+	asg.Setup(NewNodeSet(node), NewPolicySet())
+
+	c.Assert(asg.SetMetricRetention(Retention{Raw: time.Hour, RawPoints: 2, Minutes: 1, Hours: 1, MaxSeries: 1}), IsNil)
+	c.Assert(node.Metrics.Len(), Equals, 2)
+	c.Assert(node.Metrics.Retention.Raw, Equals, time.Hour)
+
+	c.Assert(asg.SetMetricRetention(Retention{Raw: time.Hour}), NotNil)
+}
+
+// BenchmarkMetricStoreAdd measures single node reporting health every second
+func BenchmarkMetricStoreAdd(b *testing.B) {
+	store := NewMetricStore(DefaultRetention)
+	start := time.Now()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		t := start.Add(time.Duration(i) * time.Second)
+		store.Add(t, NewHealthMetric(1, t))
+	}
+}
+
+// BenchmarkThousandsOfNodes measures one second of 5000 nodes reporting
+// health and cpu, memory stays bounded however long they report
+func BenchmarkThousandsOfNodes(b *testing.B) {
+	nodes := 5000
+	stores := make([]*MetricStore, nodes)
+	for i := range stores {
+		stores[i] = NewMetricStore(DefaultRetention)
+	}
+	start := time.Now()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		t := start.Add(time.Duration(i) * time.Second)
+		for _, store := range stores {
+			store.Add(t, NewHealthMetric(1, t), NewNamedMetric("cpu", "percent", nil, 50, t))
+		}
+	}
+}
+
+// BenchmarkMetricStoreQuery measures health check window of full store
+func BenchmarkMetricStoreQuery(b *testing.B) {
+	store := NewMetricStore(DefaultRetention)
+	start := time.Now()
+	for i := 0; i < DefaultRetention.RawPoints; i++ {
+		t := start.Add(time.Duration(i) * 100 * time.Millisecond)
+		store.Add(t, NewHealthMetric(1, t))
+	}
+	now := start.Add(time.Minute)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		store.Query(HealthMetricType, nil, now.Add(-10*time.Second), now)
+	}
+}
+
+// BenchmarkMetricStoreSeries measures node reporting many labeled series
+func BenchmarkMetricStoreSeries(b *testing.B) {
+	store := NewMetricStore(DefaultRetention)
+	start := time.Now()
+	metrics := []Metric{}
+	for core := 0; core < 32; core++ {
+		metrics = append(metrics, NewNamedMetric("cpu", "percent", Labels{"core": fmt.Sprint(core)}, 50, start))
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		store.Add(start, metrics...)
+	}
+}
@@ -9,10 +9,18 @@ const (
 	Vultr        = "vultr"
 
 	NodeStateNew        = NodeState(0)
-	NodeStateActive     = NodeState(1)
+	NodeStateInService  = NodeState(1)
 	NodeStateUnhealthy  = NodeState(2)
 	NodeStateTerminated = NodeState(4)
 	NodeStateDeleted    = NodeState(8)
+	NodeStateWarm       = NodeState(16)
+	// Deprecated: NodeStateActive is kept for existing callers, use NodeStateInService
+	NodeStateActive = NodeStateInService
+	// Node is waiting for lifecycle hooks, Pending:Wait and Terminating:Wait
+	NodeStatePendingWait     = NodeState(32)
+	NodeStateTerminatingWait = NodeState(64)
+	// Node is launched but has not reported healthy yet
+	NodeStatePending = NodeState(128)
 
 	ASGStateNew       = State(0)
 	ASGStateActive    = State(1)
@@ -24,7 +32,13 @@ const (
 	CMDStateDone       = CommandState(2)
 	CMDStateFailed     = CommandState(4)
 
-	HealthMetricType MetricType = "health"
+	// How policy treats node which has not reported any data
+	MissingDataBreaching    = MissingDataTreatment("breaching")
+	MissingDataNotBreaching = MissingDataTreatment("notBreaching")
+	MissingDataIgnore       = MissingDataTreatment("ignore")
+
+	HealthMetricType  MetricType = "health"
+	BacklogMetricType MetricType = "backlog"
 )
 
 type (
@@ -32,6 +46,8 @@ type (
 
 	MetricType string
 
+	MissingDataTreatment string
+
 	Provider struct {
 		ID     string
 		Region string
@@ -39,6 +55,8 @@ type (
 		APIKey string
 		Image  string
 		SSHKey string
+		// UserData is passed to node on launch, e.g. cloud-init script
+		UserData string
 	}
 
 	State int
@@ -56,6 +74,32 @@ type (
 	NIFaces []NetworkInterface
 )
 
+// String representation of node state
+func (ns NodeState) String() string {
+	switch ns {
+	case NodeStateNew:
+		return "New"
+	case NodeStateInService:
+		return "InService"
+	case NodeStateUnhealthy:
+		return "Unhealthy"
+	case NodeStateTerminated:
+		return "Terminated"
+	case NodeStateDeleted:
+		return "Deleted"
+	case NodeStateWarm:
+		return "Warm"
+	case NodeStatePendingWait:
+		return "Pending:Wait"
+	case NodeStateTerminatingWait:
+		return "Terminating:Wait"
+	case NodeStatePending:
+		return "Pending"
+	}
+
+	return "Unknown"
+}
+
 func NewCommandSet(cmd ...Command) CommandSet {
 	return CommandSet{}
 }
@@ -71,6 +115,13 @@ func isRequiredMetric(metric Metric, typ MetricType) bool {
 	case HealthMetricType:
 		_, ok = metric.(HealthMetric)
 		return ok
+	case BacklogMetricType:
+		_, ok = metric.(BacklogMetric)
+		return ok
+	}
+
+	if nm, isNamed := metric.(NamedMetric); isNamed {
+		return nm.Name == typ
 	}
 
 	return ok
@@ -0,0 +1,248 @@
+package domain
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+type (
+	// WarmPool keeps already created and powered off nodes, so that ASG
+	// could power them on instead of creating new ones. Warm nodes are
+	// never part of ASG nodes and are not counted as in-service capacity
+	WarmPool struct {
+		sync.Mutex
+
+		MinSize, MaxSize int
+		// ReuseOnScaleIn returns terminated nodes back to the pool
+		// instead of deleting them
+		ReuseOnScaleIn bool
+		Provider       Provider
+		Nodes          NodeSet
+
+		// Bootstrap is optional, when set warm node is only powered off once
+		// it passes, so user data and provisioning have finished
+		Bootstrap *HealthCheck
+		// BootstrapTimeout is how long node is waited for, without Bootstrap
+		// node with user data is kept running that long before power off
+		BootstrapTimeout time.Duration
+
+		pending int
+	}
+)
+
+const defaultBootstrapTimeout = 5 * time.Minute
+
+// NewWarmPool constructor
+func NewWarmPool(minSize, maxSize int, reuseOnScaleIn bool, provider Provider) (*WarmPool, error) {
+	if minSize < 0 {
+		return nil, errors.Errorf("MinSize %d can not be less than 0", minSize)
+	}
+
+	if minSize > maxSize {
+		return nil, errors.Errorf("MinSize %d can not be more than MaxSize %d", minSize, maxSize)
+	}
+
+	return &WarmPool{
+		MinSize:          minSize,
+		MaxSize:          maxSize,
+		ReuseOnScaleIn:   reuseOnScaleIn,
+		Provider:         provider,
+		Nodes:            NewNodeSet(),
+		BootstrapTimeout: defaultBootstrapTimeout,
+	}, nil
+}
+
+// SetBootstrap defines check warm node has to pass before it is powered off,
+// timeout defaults to 5 minutes
+func (wp *WarmPool) SetBootstrap(check *HealthCheck, timeout time.Duration) error {
+	if timeout < 0 {
+		return errors.Errorf("BootstrapTimeout %s can not be negative", timeout)
+	}
+
+	if timeout == 0 {
+		timeout = defaultBootstrapTimeout
+	}
+
+	wp.Bootstrap = check
+	wp.BootstrapTimeout = timeout
+
+	return nil
+}
+
+// Size returns amount of warm nodes
+func (wp *WarmPool) Size() int {
+	wp.Lock()
+	defer wp.Unlock()
+
+	return len(wp.Nodes)
+}
+
+// Take removes warm node prepared with given provider from the pool, nil if
+// there is none. Whole provider is compared, node with other image, size,
+// region or user data would not be the node which was asked for
+func (wp *WarmPool) Take(provider Provider) *Node {
+	wp.Lock()
+	defer wp.Unlock()
+
+	for id, node := range wp.Nodes {
+		if node.Provider != provider {
+			continue
+		}
+
+		delete(wp.Nodes, id)
+		return node
+	}
+
+	return nil
+}
+
+// drain removes nodes which were not prepared with given provider
+func (wp *WarmPool) drain(provider Provider) []*Node {
+	wp.Lock()
+	defer wp.Unlock()
+
+	stale := []*Node{}
+	for id, node := range wp.Nodes {
+		if node.Provider == provider {
+			continue
+		}
+
+		delete(wp.Nodes, id)
+		stale = append(stale, node)
+	}
+
+	return stale
+}
+
+// Put powered off node to the pool, false is returned if pool is full
+func (wp *WarmPool) Put(node *Node) bool {
+	wp.Lock()
+	defer wp.Unlock()
+
+	if len(wp.Nodes)+wp.pending >= wp.MaxSize {
+		return false
+	}
+
+	node.ChangeState(NodeStateWarm)
+	wp.Nodes[node.ID] = node
+	return true
+}
+
+// Refill deletes nodes which no longer match given provider, then creates
+// missing ones, waits until they are bootstrapped and powers them off until
+// pool has MinSize of them. Missing nodes are prepared at the same time
+func (wp *WarmPool) Refill(provider Provider) error {
+	driver, err := NewDriver(provider)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	errs := []error{}
+	for _, node := range wp.drain(provider) {
+		fmt.Printf("Warm node [%s] does not match provider, deleting it \n", node.ID)
+		if err := wp.delete(node); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	wp.Lock()
+	missing := wp.MinSize - len(wp.Nodes) - wp.pending
+	if missing < 0 {
+		missing = 0
+	}
+	wp.pending = wp.pending + missing
+	wp.Unlock()
+
+	results := make(chan error, missing)
+	for i := 0; i < missing; i++ {
+		go func() {
+			node, err := wp.prepare(driver, provider)
+
+			wp.Lock()
+			wp.pending--
+			if err == nil {
+				wp.Nodes[node.ID] = node
+			}
+			wp.Unlock()
+
+			results <- err
+		}()
+	}
+
+	for i := 0; i < missing; i++ {
+		if err := <-results; err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.Errorf("Warm pool refill finished with %d errors, last - %s", len(errs), errs[len(errs)-1])
+	}
+
+	return nil
+}
+
+// delete node with driver of provider it was prepared with
+func (wp *WarmPool) delete(node *Node) error {
+	driver, err := NewDriver(node.Provider)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	return errors.Trace(driver.Delete(node.ID))
+}
+
+// prepare node with given provider, node keeps provider so that it is only
+// taken for launch of the same one
+func (wp *WarmPool) prepare(driver Driver, provider Provider) (*Node, error) {
+	node, err := driver.Create()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	node.Provider = provider
+
+	if err := wp.waitBootstrapped(node, provider.UserData != ""); err != nil {
+		driver.Delete(node.ID)
+		return nil, errors.Trace(err)
+	}
+
+	err = driver.PowerOff(node.ID)
+	if err != nil {
+		// Do not leave running droplet behind
+		driver.Delete(node.ID)
+		return nil, errors.Trace(err)
+	}
+
+	fmt.Printf("Warm node [%s] is ready \n", node.ID)
+	node.ChangeState(NodeStateWarm)
+
+	return node, nil
+}
+
+// waitBootstrapped waits until node passes Bootstrap check. User data only
+// runs on first boot, so node which has some is not powered off before it
+// has had time to finish
+func (wp *WarmPool) waitBootstrapped(node *Node, hasUserData bool) error {
+	if wp.Bootstrap == nil {
+		if hasUserData {
+			time.Sleep(wp.BootstrapTimeout)
+		}
+		return nil
+	}
+
+	deadline := time.Now().Add(wp.BootstrapTimeout)
+	for {
+		err := wp.Bootstrap.Check(node)
+		if err == nil {
+			return nil
+		}
+
+		if time.Now().Add(wp.Bootstrap.Interval).After(deadline) {
+			return errors.Annotatef(err, "Warm node [%s] was not bootstrapped in %s", node.ID, wp.BootstrapTimeout)
+		}
+		time.Sleep(wp.Bootstrap.Interval)
+	}
+}
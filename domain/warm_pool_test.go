@@ -0,0 +1,171 @@
+package domain
+
+import (
+	"net"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type WarmPoolSuite struct{}
+
+var _ = Suite(&WarmPoolSuite{})
+
+func (s *WarmPoolSuite) TestIfWarmPoolIsRefilledWithPoweredOffNodes(c *C) {
+	drv := registerTestDriver()
+
+	wp, err := NewWarmPool(2, 3, false, Provider{ID: testProviderID})
+	c.Assert(err, IsNil)
+
+	err = wp.Refill(wp.Provider)
+	c.Assert(err, IsNil)
+	c.Assert(wp.Size(), Equals, 2)
+	c.Assert(drv.poweredOff, DeepEquals, drv.created)
+
+	for _, node := range wp.Nodes {
+		c.Assert(node.State, Equals, NodeStateWarm)
+	}
+
+	// Nothing is missing, nothing should be created
+	err = wp.Refill(wp.Provider)
+	c.Assert(err, IsNil)
+	c.Assert(len(drv.created), Equals, 2)
+}
+
+func (s *WarmPoolSuite) TestIfLaunchPowersOnWarmNodeInsteadOfCreatingNewOne(c *C) {
+	drv := registerTestDriver()
+
+	wp, err := NewWarmPool(1, 1, false, Provider{ID: testProviderID})
+	c.Assert(err, IsNil)
+	c.Assert(wp.Refill(wp.Provider), IsNil)
+
+	asg := NewAutoScalingGroup(ID("asg-1"))
+	asg.Setup(NewNodeSet(), NewPolicySet())
+	asg.WarmPool = wp
+
+	cmd := &Launch{
+		BaseCommand: BaseCommand{
+			Provider: Provider{ID: testProviderID},
+		},
+	}
+	err = cmd.Execute(asg)
+	c.Assert(err, IsNil)
+
+	c.Assert(len(drv.created), Equals, 1)
+	c.Assert(drv.poweredOn, DeepEquals, []ID{ID("test-1")})
+	c.Assert(wp.Size(), Equals, 0)
+	c.Assert(len(asg.Nodes), Equals, 1)
+	c.Assert(asg.Nodes.GetByID(ID("test-1")).State, Equals, NodeStatePending)
+
+	// Pool is empty now, so new node has to be created
+	err = cmd.Execute(asg)
+	c.Assert(err, IsNil)
+	c.Assert(len(drv.created), Equals, 2)
+	c.Assert(len(asg.Nodes), Equals, 2)
+}
+
+func (s *WarmPoolSuite) TestIfTerminatedNodeIsReturnedToWarmPool(c *C) {
+	drv := registerTestDriver()
+
+	wp, err := NewWarmPool(0, 1, true, Provider{ID: testProviderID})
+	c.Assert(err, IsNil)
+
+	node1, _ := drv.Create()
+	node2, _ := drv.Create()
+
+	asg := NewAutoScalingGroup(ID("asg-1"))
+	asg.Setup(NewNodeSet(node1, node2), NewPolicySet())
+	asg.WarmPool = wp
+
+	err = (&Terminate{BaseCommand: BaseCommand{Provider: Provider{ID: testProviderID}}, NodeID: node1.ID}).Execute(asg)
+	c.Assert(err, IsNil)
+	c.Assert(wp.Size(), Equals, 1)
+	c.Assert(len(drv.deleted), Equals, 0)
+	c.Assert(len(asg.Nodes), Equals, 1)
+
+	// Pool is full, node is deleted
+	err = (&Terminate{BaseCommand: BaseCommand{Provider: Provider{ID: testProviderID}}, NodeID: node2.ID}).Execute(asg)
+	c.Assert(err, IsNil)
+	c.Assert(wp.Size(), Equals, 1)
+	c.Assert(drv.deleted, DeepEquals, []ID{node2.ID})
+	c.Assert(len(asg.Nodes), Equals, 0)
+}
+
+func (s *WarmPoolSuite) TestIfWarmNodeIsPoweredOffOnlyOnceBootstrapped(c *C) {
+	drv := registerTestDriver()
+	drv.publicIP = net.ParseIP("127.0.0.1")
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, IsNil)
+
+	check, err := NewHealthCheck(HealthCheckTCP, PublicInterface, serverPort(c, ln.Addr().String()), "", 0, "", 10*time.Millisecond, 10*time.Millisecond)
+	c.Assert(err, IsNil)
+
+	wp, err := NewWarmPool(1, 1, false, Provider{ID: testProviderID, UserData: "#!/bin/sh"})
+	c.Assert(err, IsNil)
+	c.Assert(wp.SetBootstrap(check, 50*time.Millisecond), IsNil)
+
+	c.Assert(wp.Refill(wp.Provider), IsNil)
+	c.Assert(wp.Size(), Equals, 1)
+	c.Assert(drv.poweredOff, DeepEquals, drv.created)
+
+	// Node which is never bootstrapped is deleted instead of kept in pool
+	ln.Close()
+	wp.Nodes = NewNodeSet()
+	c.Assert(wp.Refill(wp.Provider), NotNil)
+	c.Assert(wp.Size(), Equals, 0)
+	c.Assert(drv.deleted, DeepEquals, []ID{ID("test-2")})
+	c.Assert(len(drv.poweredOff), Equals, 1)
+}
+
+func (s *WarmPoolSuite) TestIfNodeWithUserDataIsGivenTimeToRunIt(c *C) {
+	registerTestDriver()
+
+	wp, err := NewWarmPool(1, 1, false, Provider{ID: testProviderID, UserData: "#!/bin/sh"})
+	c.Assert(err, IsNil)
+	c.Assert(wp.SetBootstrap(nil, 50*time.Millisecond), IsNil)
+	c.Assert(wp.SetBootstrap(nil, -time.Second), NotNil)
+
+	start := time.Now()
+	c.Assert(wp.Refill(wp.Provider), IsNil)
+	c.Assert(time.Since(start) >= 50*time.Millisecond, Equals, true)
+	c.Assert(wp.Size(), Equals, 1)
+}
+
+func (s *WarmPoolSuite) TestIfMissingNodesArePreparedAtTheSameTime(c *C) {
+	drv := registerTestDriver()
+
+	wp, err := NewWarmPool(3, 3, false, Provider{ID: testProviderID, UserData: "#!/bin/sh"})
+	c.Assert(err, IsNil)
+	c.Assert(wp.SetBootstrap(nil, 100*time.Millisecond), IsNil)
+
+	start := time.Now()
+	c.Assert(wp.Refill(wp.Provider), IsNil)
+	c.Assert(time.Since(start) < 250*time.Millisecond, Equals, true)
+	c.Assert(wp.Size(), Equals, 3)
+	c.Assert(len(drv.poweredOff), Equals, 3)
+}
+
+func (s *WarmPoolSuite) TestIfOnlyNodesOfTheSameProviderAreTakenAndOthersAreDrained(c *C) {
+	drv := registerTestDriver()
+
+	old := Provider{ID: testProviderID, Image: "ubuntu-14-04-x64", Size: "512mb"}
+	wp, err := NewWarmPool(1, 1, false, old)
+	c.Assert(err, IsNil)
+	c.Assert(wp.Refill(old), IsNil)
+
+	// Same provider ID is not enough
+	changed := old
+	changed.Image = "ubuntu-16-04-x64"
+	c.Assert(wp.Take(changed), IsNil)
+	c.Assert(wp.Size(), Equals, 1)
+
+	c.Assert(wp.Refill(changed), IsNil)
+	c.Assert(drv.deleted, DeepEquals, []ID{ID("test-1")})
+	c.Assert(wp.Size(), Equals, 1)
+	c.Assert(wp.Take(old), IsNil)
+
+	node := wp.Take(changed)
+	c.Assert(node, NotNil)
+	c.Assert(node.ID, Equals, ID("test-2"))
+}
@@ -0,0 +1,332 @@
+package endpoints
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/nildev/artemis/domain"
+	"github.com/nildev/lib/utils"
+)
+
+const (
+	// maxConcurrentBatches are ingested at once, the rest wait for
+	// batchQueueTimeout and are rejected with 429 afterwards
+	maxConcurrentBatches = 4
+	batchQueueTimeout    = 5 * time.Second
+	// maxBatchBytes of decompressed body
+	maxBatchBytes = 64 << 20
+	// maxBatchLineBytes is longest line of newline delimited JSON
+	maxBatchLineBytes = 1 << 20
+	// batchChunkSize metrics are added to nodes at once
+	batchChunkSize = 1000
+	// maxBatchErrors are reported in response, the rest are only counted
+	maxBatchErrors = 1000
+)
+
+var batchSlots = make(chan struct{}, maxConcurrentBatches)
+
+type (
+	// BatchMetric type, NodeID is optional and metric is added to ASG itself
+	// without it. Name defaults to health and Time to time of request
+	BatchMetric struct {
+		ID     string
+		NodeID string
+		Name   string
+		Unit   string
+		Labels map[string]string
+		Value  float64
+		Time   time.Time
+	}
+
+	// BatchError type, Index is position of metric in batch starting from 0
+	BatchError struct {
+		Index  int
+		ID     string
+		NodeID string
+		Error  string
+	}
+
+	// AddMetricsBatchResponse type, Error is set if body could not be read
+	// till the end, metrics before it are still added
+	AddMetricsBatchResponse struct {
+		Accepted int
+		Rejected int
+		Errors   []BatchError
+		Error    string
+	}
+
+	// metricBatch groups metrics by node so they are added in chunks
+	metricBatch struct {
+		request  *http.Request
+		received time.Time
+		read     int
+		pending  int
+		groups   map[batchTarget]*batchGroup
+		queued   []queuedBatchGroup
+		resp     *AddMetricsBatchResponse
+	}
+
+	batchTarget struct {
+		asg  string
+		node string
+	}
+
+	batchGroup struct {
+		indexes []int
+		metrics []domain.NamedMetric
+	}
+
+	// queuedBatchGroup waits for ASG loop to add its metrics
+	queuedBatchGroup struct {
+		target batchTarget
+		group  *batchGroup
+		result <-chan error
+	}
+
+	// limitedBody fails once more than maxBatchBytes are read
+	limitedBody struct {
+		io.Reader
+		closer io.Closer
+		read   int64
+	}
+)
+
+// AddMetricsBatchHandler adds metrics of many ASGs and nodes at once. Body is
+// either JSON array of metrics or, with `application/x-ndjson` content type,
+// one metric per line, optionally gzip compressed. Metrics which can not be
+// added are reported in response without rejecting the rest. Metrics are
+// added by ASG loops, response is sent once they are
+func AddMetricsBatchHandler(rw http.ResponseWriter, r *http.Request) {
+	select {
+	case batchSlots <- struct{}{}:
+		defer func() { <-batchSlots }()
+	case <-time.After(batchQueueTimeout):
+		rw.Header().Set("Retry-After", "1")
+		utils.Respond(rw, "Too many batches are being ingested, retry later", http.StatusTooManyRequests)
+		return
+	}
+
+	body, err := requestBody(r)
+	if err != nil {
+		utils.Respond(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer body.Close()
+
+	batch := &metricBatch{
+		request:  r,
+		received: time.Now(),
+		groups:   map[batchTarget]*batchGroup{},
+		resp:     &AddMetricsBatchResponse{Errors: []BatchError{}},
+	}
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-ndjson") {
+		err = batch.readLines(body)
+	} else {
+		err = batch.readArray(body)
+	}
+	batch.flush()
+	batch.collect()
+
+	sort.Slice(batch.resp.Errors, func(i, j int) bool {
+		return batch.resp.Errors[i].Index < batch.resp.Errors[j].Index
+	})
+
+	returnCode := http.StatusOK
+	if err != nil {
+		batch.resp.Error = err.Error()
+		returnCode = http.StatusBadRequest
+	}
+
+	out, err := json.Marshal(batch.resp)
+	if err != nil {
+		utils.Respond(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	utils.Respond(rw, string(out), returnCode)
+}
+
+// requestBody returns body of request, decompressed if it is gzip encoded,
+// reading more than maxBatchBytes fails
+func requestBody(r *http.Request) (io.ReadCloser, error) {
+	if r.Header.Get("Content-Encoding") != "gzip" {
+		return &limitedBody{Reader: io.LimitReader(r.Body, maxBatchBytes+1), closer: r.Body}, nil
+	}
+
+	gz, err := gzip.NewReader(r.Body)
+	if err != nil {
+		return nil, errors.Annotatef(err, "Body is not gzip compressed")
+	}
+
+	return &limitedBody{Reader: io.LimitReader(gz, maxBatchBytes+1), closer: gz}, nil
+}
+
+func (lb *limitedBody) Read(p []byte) (int, error) {
+	n, err := lb.Reader.Read(p)
+	lb.read = lb.read + int64(n)
+	if lb.read > maxBatchBytes {
+		return n, errors.Errorf("Body is larger than %d bytes", maxBatchBytes)
+	}
+
+	return n, err
+}
+
+func (lb *limitedBody) Close() error {
+	return lb.closer.Close()
+}
+
+// readArray decodes JSON array one metric at a time
+func (b *metricBatch) readArray(body io.Reader) error {
+	dec := json.NewDecoder(body)
+	token, err := dec.Token()
+	if err != nil {
+		return errors.Annotatef(err, "Body is not JSON array")
+	}
+
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return errors.Errorf("Body is not JSON array")
+	}
+
+	for dec.More() {
+		m := BatchMetric{}
+		if err := dec.Decode(&m); err != nil {
+			return errors.Annotatef(err, "Metric %d", b.read)
+		}
+		b.add(m)
+	}
+
+	_, err = dec.Token()
+	return errors.Trace(err)
+}
+
+// readLines decodes newline delimited JSON, line which can not be decoded
+// is reported and skipped
+func (b *metricBatch) readLines(body io.Reader) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), maxBatchLineBytes)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		m := BatchMetric{}
+		if err := json.Unmarshal([]byte(line), &m); err != nil {
+			b.reject(b.read, m, err)
+			b.read++
+			continue
+		}
+		b.add(m)
+	}
+
+	return errors.Trace(scanner.Err())
+}
+
+func (b *metricBatch) add(m BatchMetric) {
+	index := b.read
+	b.read++
+
+	if m.ID == "" {
+		b.reject(index, m, errors.Errorf("ID of ASG is required"))
+		return
+	}
+
+	name := domain.HealthMetricType
+	if m.Name != "" {
+		name = domain.MetricType(m.Name)
+	}
+
+	t := m.Time
+	if t.IsZero() {
+		t = b.received
+	}
+
+	target := batchTarget{asg: m.ID, node: m.NodeID}
+	group, ok := b.groups[target]
+	if !ok {
+		group = &batchGroup{}
+		b.groups[target] = group
+	}
+	group.indexes = append(group.indexes, index)
+	group.metrics = append(group.metrics, domain.NewNamedMetric(name, m.Unit, domain.Labels(m.Labels), m.Value, t))
+
+	b.pending++
+	if b.pending >= batchChunkSize {
+		b.flush()
+	}
+}
+
+// flush pending metrics to ASG loops, results are collected once whole batch
+// is read
+func (b *metricBatch) flush() {
+	for target, group := range b.groups {
+		asg := ASGSupervisor.Get(domain.ID(target.asg))
+		switch {
+		case asg == nil:
+			b.rejectGroup(target, group, errors.Errorf("ASG with ID [%s], could not be found! Have you created it with /setup endpoint?", target.asg))
+		case verifyNodeToken(asg, target.node, b.request) != nil:
+			b.rejectGroup(target, group, domain.ErrInvalidToken)
+		default:
+			b.queued = append(b.queued, queuedBatchGroup{
+				target: target,
+				group:  group,
+				result: queueMetrics(asg, target.node, group.metrics),
+			})
+		}
+	}
+
+	b.groups = map[batchTarget]*batchGroup{}
+	b.pending = 0
+}
+
+// collect results of queued metrics, all metrics of node fail together unless
+// only some of them were rejected because node or ASG has reached its series limit
+func (b *metricBatch) collect() {
+	deadline := time.Now().Add(queuedMetricsTimeout)
+	for _, q := range b.queued {
+		err := waitMetrics(q.result, deadline)
+		if err == nil {
+			b.resp.Accepted = b.resp.Accepted + len(q.group.metrics)
+			continue
+		}
+
+		if limit, ok := errors.Cause(err).(*domain.SeriesLimitError); ok {
+			b.resp.Accepted = b.resp.Accepted + len(q.group.metrics) - len(limit.Rejected)
+			for _, i := range limit.Rejected {
+				b.reject(q.group.indexes[i], BatchMetric{ID: q.target.asg, NodeID: q.target.node}, errors.Errorf("Series limit %d of store has been reached", limit.MaxSeries))
+			}
+			continue
+		}
+
+		b.rejectGroup(q.target, q.group, err)
+	}
+
+	b.queued = nil
+}
+
+func (b *metricBatch) rejectGroup(target batchTarget, group *batchGroup, err error) {
+	for _, index := range group.indexes {
+		b.reject(index, BatchMetric{ID: target.asg, NodeID: target.node}, err)
+	}
+}
+
+func (b *metricBatch) reject(index int, m BatchMetric, err error) {
+	b.resp.Rejected++
+	if len(b.resp.Errors) >= maxBatchErrors {
+		return
+	}
+
+	b.resp.Errors = append(b.resp.Errors, BatchError{
+		Index:  index,
+		ID:     m.ID,
+		NodeID: m.NodeID,
+		Error:  err.Error(),
+	})
+}
@@ -0,0 +1,145 @@
+package endpoints
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/nildev/artemis/domain"
+	. "gopkg.in/check.v1"
+)
+
+type BatchSuite struct{}
+
+var _ = Suite(&BatchSuite{})
+
+func (s *BatchSuite) TearDownTest(c *C) {
+	ASGSupervisor.Remove(domain.ID("batch-asg"))
+}
+
+// postBatch sends body to batch handler and decodes its response
+func postBatch(c *C, body []byte, header map[string]string) (int, AddMetricsBatchResponse) {
+	r := httptest.NewRequest("POST", "/api/v1/metrics/batch", bytes.NewReader(body))
+	for k, v := range header {
+		r.Header.Set(k, v)
+	}
+
+	rw := httptest.NewRecorder()
+	AddMetricsBatchHandler(rw, r)
+
+	// Response is marshalled into JSON string by utils.Respond
+	out := ""
+	c.Assert(json.Unmarshal(rw.Body.Bytes(), &out), IsNil, Commentf("Body %s", rw.Body.String()))
+	resp := AddMetricsBatchResponse{}
+	c.Assert(json.Unmarshal([]byte(out), &resp), IsNil, Commentf("Body %s", out))
+
+	return rw.Code, resp
+}
+
+func (s *BatchSuite) TestIfBatchRejectsOnlyMetricsWhichCanNotBeAdded(c *C) {
+	asg := prepareASG(c, "batch-asg", "node1")
+
+	code, resp := postBatch(c, []byte(`[
+		{"ID": "batch-asg", "NodeID": "node1", "Value": 1},
+		{"ID": "unknown-asg", "NodeID": "node1", "Value": 1},
+		{"NodeID": "node1", "Value": 1},
+		{"ID": "batch-asg", "NodeID": "node2", "Value": 1},
+		{"ID": "batch-asg", "Name": "backlog", "Value": 10}
+	]`), nil)
+
+	c.Assert(code, Equals, http.StatusOK)
+	c.Assert(resp.Accepted, Equals, 2)
+	c.Assert(resp.Rejected, Equals, 3)
+	c.Assert(len(resp.Errors), Equals, 3)
+	c.Assert(resp.Errors[0].Index, Equals, 1)
+	c.Assert(resp.Errors[1].Index, Equals, 2)
+	c.Assert(resp.Errors[1].Error, Equals, "ID of ASG is required")
+	c.Assert(resp.Errors[2].Index, Equals, 3)
+	c.Assert(resp.Errors[2].NodeID, Equals, "node2")
+
+	now := time.Now()
+	c.Assert(len(asg.QueryGroupMetrics("backlog", nil, now.Add(-time.Minute), now)), Equals, 1)
+}
+
+func (s *BatchSuite) TestIfBatchRejectsOnlyMetricsOverSeriesLimit(c *C) {
+	asg := prepareASG(c, "batch-asg", "node1")
+	c.Assert(asg.SetMetricRetention(domain.Retention{Raw: time.Minute, RawPoints: 10, Minutes: 1, Hours: 1, MaxSeries: 2}), IsNil)
+
+	code, resp := postBatch(c, []byte(`[
+		{"ID": "batch-asg", "NodeID": "node1", "Name": "cpu", "Value": 1},
+		{"ID": "batch-asg", "NodeID": "node1", "Name": "memory", "Value": 1},
+		{"ID": "batch-asg", "NodeID": "node1", "Name": "disk", "Value": 1},
+		{"ID": "batch-asg", "NodeID": "node1", "Name": "cpu", "Value": 2}
+	]`), nil)
+
+	c.Assert(code, Equals, http.StatusOK)
+	c.Assert(resp.Accepted, Equals, 3)
+	c.Assert(resp.Rejected, Equals, 1)
+	c.Assert(len(resp.Errors), Equals, 1)
+	c.Assert(resp.Errors[0].Index, Equals, 2)
+	c.Assert(resp.Errors[0].Error, Equals, "Series limit 2 of store has been reached")
+}
+
+func (s *BatchSuite) TestIfBatchIsReadAsCompressedLines(c *C) {
+	prepareASG(c, "batch-asg", "node1")
+
+	body := &bytes.Buffer{}
+	gz := gzip.NewWriter(body)
+	gz.Write([]byte("{\"ID\": \"batch-asg\", \"NodeID\": \"node1\", \"Value\": 1}\n\nnot json\n" +
+		"{\"ID\": \"batch-asg\", \"NodeID\": \"node1\", \"Name\": \"cpu\", \"Value\": 42}\n"))
+	c.Assert(gz.Close(), IsNil)
+
+	code, resp := postBatch(c, body.Bytes(), map[string]string{
+		"Content-Type":     "application/x-ndjson",
+		"Content-Encoding": "gzip",
+	})
+
+	c.Assert(code, Equals, http.StatusOK)
+	c.Assert(resp.Accepted, Equals, 2)
+	c.Assert(resp.Rejected, Equals, 1)
+	c.Assert(resp.Errors[0].Index, Equals, 1)
+}
+
+func (s *BatchSuite) TestIfBatchRequiresTokenOfNode(c *C) {
+	credentials, err := domain.NewNodeCredentials(time.Hour, time.Minute)
+	c.Assert(err, IsNil)
+	prepareSecuredASG(c, "batch-asg", credentials, "node1", "node2")
+
+	token, err := credentials.Issue("node1", time.Now())
+	c.Assert(err, IsNil)
+
+	code, resp := postBatch(c, []byte(`[
+		{"ID": "batch-asg", "NodeID": "node1", "Value": 1},
+		{"ID": "batch-asg", "NodeID": "node2", "Value": 1}
+	]`), map[string]string{"Authorization": "Bearer " + token.Token})
+
+	c.Assert(code, Equals, http.StatusOK)
+	c.Assert(resp.Accepted, Equals, 1)
+	c.Assert(resp.Errors[0].NodeID, Equals, "node2")
+	c.Assert(resp.Errors[0].Error, Equals, domain.ErrInvalidToken.Error())
+
+	// Metrics of ASG itself require group token, token of node is not enough
+	backlog := []byte(`[{"ID": "batch-asg", "Name": "backlog", "Value": 10}]`)
+	_, resp = postBatch(c, backlog, map[string]string{"Authorization": "Bearer " + token.Token})
+	c.Assert(resp.Rejected, Equals, 1)
+
+	group, err := credentials.IssueGroupToken(time.Now())
+	c.Assert(err, IsNil)
+	_, resp = postBatch(c, backlog, map[string]string{"X-Node-Token": group.Token})
+	c.Assert(resp.Accepted, Equals, 1)
+}
+
+func (s *BatchSuite) TestIfMetricsBeforeBrokenBodyAreStillAdded(c *C) {
+	prepareASG(c, "batch-asg", "node1")
+
+	code, resp := postBatch(c, []byte(`[{"ID": "batch-asg", "NodeID": "node1", "Value": 1}, {"ID": `), nil)
+	c.Assert(code, Equals, http.StatusBadRequest)
+	c.Assert(resp.Accepted, Equals, 1)
+	c.Assert(resp.Error, Matches, "Metric 1.*")
+
+	code, _ = postBatch(c, []byte(`{"ID": "batch-asg"}`), nil)
+	c.Assert(code, Equals, http.StatusBadRequest)
+}
@@ -4,7 +4,6 @@ import (
 	"net/http"
 
 	"encoding/json"
-	"io/ioutil"
 	"time"
 
 	"bitbucket.org/nildev/lib/Godeps/_workspace/src/github.com/juju/errors"
@@ -14,15 +13,25 @@ import (
 	"github.com/nildev/lib/utils"
 )
 
+// queuedMetricsTimeout is how long request waits for ASG loop to add its metrics
+const queuedMetricsTimeout = 30 * time.Second
+
 type (
+	// Metric type, Name is optional and defaults to Type of request
 	Metric struct {
-		Value float64
-		Time  time.Time
+		Name   string
+		Unit   string
+		Labels map[string]string
+		Value  float64
+		Time   time.Time
 	}
 
+	// AddMetricsRequest type, when NodeID is empty metrics are added to ASG itself.
+	// Type is name of metrics which have none, health by default
 	AddMetricsRequest struct {
 		ID      string
 		NodeID  string
+		Type    string
 		Metrics []Metric
 	}
 )
@@ -30,15 +39,16 @@ type (
 func AddMetricsHandler(rw http.ResponseWriter, r *http.Request) {
 	ctxLog := log.WithField("version", version.Version).WithField("git-hash", version.GitHash).WithField("build-time", version.BuiltTimestamp)
 
-	body, err := ioutil.ReadAll(r.Body)
+	body, err := requestBody(r)
 	if err != nil {
 		ctxLog.Error(err)
 		utils.Respond(rw, err.Error(), http.StatusBadRequest)
 		return
 	}
+	defer body.Close()
 
 	req := &AddMetricsRequest{}
-	if err := json.Unmarshal(body, req); err != nil {
+	if err := json.NewDecoder(body).Decode(req); err != nil {
 		ctxLog.Error(err)
 		utils.Respond(rw, err.Error(), http.StatusBadRequest)
 		return
@@ -52,15 +62,30 @@ func AddMetricsHandler(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	healthMetricSeries := domain.NewMetricSeries()
+	if err := verifyNodeToken(asg, req.NodeID, r); err != nil {
+		ctxLog.Error(err)
+		utils.Respond(rw, err.Error(), http.StatusUnauthorized)
+		return
+	}
 
+	metricType := domain.HealthMetricType
+	if req.Type != "" {
+		metricType = domain.MetricType(req.Type)
+	}
+
+	metrics := []domain.NamedMetric{}
 	for _, m := range req.Metrics {
-		ctxLog.Infof("Adding: [%v] [%v] \n", m.Value, m.Time)
-		healthMetricSeries[m.Time] = domain.NewHealthMetric(m.Value, m.Time)
+		name := metricType
+		if m.Name != "" {
+			name = domain.MetricType(m.Name)
+		}
+
+		ctxLog.Infof("Adding: [%s] [%v] [%v] \n", name, m.Value, m.Time)
+		metrics = append(metrics, domain.NewNamedMetric(name, m.Unit, domain.Labels(m.Labels), m.Value, m.Time))
 	}
 
-	err = asg.AddMetrics(domain.ID(req.NodeID), healthMetricSeries)
-	if err := json.Unmarshal(body, req); err != nil {
+	err = waitMetrics(queueMetrics(asg, req.NodeID, metrics), time.Now().Add(queuedMetricsTimeout))
+	if err != nil {
 		ctxLog.Error(err)
 		utils.Respond(rw, err.Error(), http.StatusInternalServerError)
 		return
@@ -69,3 +94,25 @@ func AddMetricsHandler(rw http.ResponseWriter, r *http.Request) {
 	returnCode := http.StatusOK
 	utils.Respond(rw, nil, returnCode)
 }
+
+// queueMetrics hands metrics of node, or of ASG itself if node is empty, over
+// to ASG loop. Result is sent to returned channel once the loop has added them
+func queueMetrics(asg *domain.AutoScalingGroup, node string, metrics []domain.NamedMetric) <-chan error {
+	result := make(chan error, 1)
+	asg.QueueNamedMetrics(domain.ID(node), metrics, func(err error) {
+		result <- err
+	})
+
+	return result
+}
+
+// waitMetrics waits for result of queued metrics until deadline, ASG which has
+// been stopped meanwhile never sends one
+func waitMetrics(result <-chan error, deadline time.Time) error {
+	select {
+	case err := <-result:
+		return err
+	case <-time.After(time.Until(deadline)):
+		return errors.Errorf("Metrics were not added by ASG in %s", queuedMetricsTimeout)
+	}
+}
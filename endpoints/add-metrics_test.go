@@ -0,0 +1,40 @@
+package endpoints
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/nildev/artemis/domain"
+	. "gopkg.in/check.v1"
+)
+
+type MetricsSuite struct{}
+
+var _ = Suite(&MetricsSuite{})
+
+func (s *MetricsSuite) TearDownTest(c *C) {
+	ASGSupervisor.Remove(domain.ID("metrics-asg"))
+}
+
+// postMetrics sends body to metrics handler and returns response code
+func postMetrics(body string) int {
+	r := httptest.NewRequest("POST", "/api/v1/metrics", bytes.NewReader([]byte(body)))
+	rw := httptest.NewRecorder()
+	AddMetricsHandler(rw, r)
+
+	return rw.Code
+}
+
+func (s *MetricsSuite) TestIfMetricsAreAddedByASGLoop(c *C) {
+	asg := prepareASG(c, "metrics-asg", "node1")
+	now := time.Now().Format(time.RFC3339Nano)
+
+	c.Assert(postMetrics(`{"ID": "metrics-asg", "NodeID": "node1", "Metrics": [{"Value": 1, "Time": "`+now+`"}]}`), Equals, http.StatusOK)
+	c.Assert(postMetrics(`{"ID": "metrics-asg", "NodeID": "node2", "Metrics": [{"Value": 1, "Time": "`+now+`"}]}`), Equals, http.StatusInternalServerError)
+	c.Assert(postMetrics(`{"ID": "metrics-asg", "Type": "backlog", "Metrics": [{"Value": 10, "Time": "`+now+`"}]}`), Equals, http.StatusOK)
+
+	// Response is sent once metrics are added
+	c.Assert(len(asg.QueryGroupMetrics("backlog", nil, time.Now().Add(-time.Minute), time.Now())), Equals, 1)
+}
@@ -0,0 +1,107 @@
+package endpoints
+
+import (
+	"net/http"
+
+	"encoding/json"
+	"io/ioutil"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/nildev/artemis/domain"
+	"github.com/nildev/lib/utils"
+)
+
+type (
+	// ReadCircuitBreakerResponse type
+	ReadCircuitBreakerResponse struct {
+		State     string
+		Failures  int
+		Trips     int
+		RetryAt   time.Time
+		TrialNode string
+		Events    []NodeEvent
+	}
+
+	// ResetCircuitBreakerRequest type
+	ResetCircuitBreakerRequest struct {
+		ID string
+	}
+
+	ResetCircuitBreakerResponse struct{}
+)
+
+// ReadCircuitBreakerHandler returns circuit breaker state of ASG given by `ID` query param
+func ReadCircuitBreakerHandler(rw http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("ID")
+
+	asg := ASGSupervisor.Get(domain.ID(id))
+	if asg == nil {
+		err := errors.Errorf("ASG with ID [%s], could not be found! Have you created it with /setup endpoint?", id)
+		utils.Respond(rw, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	if asg.Breaker == nil {
+		err := errors.Errorf("ASG [%s] has no circuit breaker", id)
+		utils.Respond(rw, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	asg.Breaker.Lock()
+	outResp := &ReadCircuitBreakerResponse{
+		State:     string(asg.Breaker.State),
+		Failures:  len(asg.Breaker.Failures),
+		Trips:     asg.Breaker.Trips,
+		RetryAt:   asg.Breaker.RetryAt,
+		TrialNode: string(asg.Breaker.TrialNode),
+		Events:    []NodeEvent{},
+	}
+	for _, e := range asg.Breaker.Events {
+		outResp.Events = append(outResp.Events, NodeEvent{Time: e.Time, Message: e.Message})
+	}
+	asg.Breaker.Unlock()
+
+	out, err := json.Marshal(outResp)
+	if err != nil {
+		utils.Respond(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	utils.Respond(rw, string(out), http.StatusOK)
+}
+
+// ResetCircuitBreakerHandler API handler
+func ResetCircuitBreakerHandler(rw http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		utils.Respond(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	req := &ResetCircuitBreakerRequest{}
+	if err := json.Unmarshal(body, req); err != nil {
+		utils.Respond(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	asg := ASGSupervisor.Get(domain.ID(req.ID))
+	if asg == nil {
+		err := errors.Errorf("ASG with ID [%s], could not be found! Have you created it with /setup endpoint?", domain.ID(req.ID))
+		utils.Respond(rw, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	if err := asg.ResetCircuitBreaker(); err != nil {
+		ctxLog.Error(err)
+		utils.Respond(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	outResp := &ResetCircuitBreakerResponse{}
+	out, err := json.Marshal(outResp)
+	if err != nil {
+		utils.Respond(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	utils.Respond(rw, string(out), http.StatusOK)
+}
@@ -0,0 +1,107 @@
+package endpoints
+
+import (
+	"net/http"
+
+	"encoding/json"
+	"io/ioutil"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/nildev/artemis/domain"
+	"github.com/nildev/lib/utils"
+)
+
+type (
+	// ReadGuardResponse type
+	ReadGuardResponse struct {
+		Frozen   bool
+		FrozenAt time.Time
+		Events   []NodeEvent
+	}
+
+	// ConfirmReplacementsRequest type, global guard is confirmed if ID is empty
+	ConfirmReplacementsRequest struct {
+		ID string
+	}
+
+	ConfirmReplacementsResponse struct{}
+)
+
+// ReadGuardHandler returns mass failure guard of ASG given by `ID` query param,
+// global guard is returned if `ID` is empty
+func ReadGuardHandler(rw http.ResponseWriter, r *http.Request) {
+	guard, err := getGuard(r.URL.Query().Get("ID"))
+	if err != nil {
+		utils.Respond(rw, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	guard.Lock()
+	outResp := &ReadGuardResponse{
+		Frozen:   guard.Frozen,
+		FrozenAt: guard.FrozenAt,
+		Events:   []NodeEvent{},
+	}
+	for _, e := range guard.Events {
+		outResp.Events = append(outResp.Events, NodeEvent{Time: e.Time, Message: e.Message})
+	}
+	guard.Unlock()
+
+	out, err := json.Marshal(outResp)
+	if err != nil {
+		utils.Respond(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	utils.Respond(rw, string(out), http.StatusOK)
+}
+
+// ConfirmReplacementsHandler API handler
+func ConfirmReplacementsHandler(rw http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		utils.Respond(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	req := &ConfirmReplacementsRequest{}
+	if err := json.Unmarshal(body, req); err != nil {
+		utils.Respond(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	guard, err := getGuard(req.ID)
+	if err != nil {
+		utils.Respond(rw, err.Error(), http.StatusNotFound)
+		return
+	}
+	guard.Confirm(domain.ID(req.ID))
+
+	outResp := &ConfirmReplacementsResponse{}
+	out, err := json.Marshal(outResp)
+	if err != nil {
+		utils.Respond(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	utils.Respond(rw, string(out), http.StatusOK)
+}
+
+func getGuard(id string) (*domain.MassFailureGuard, error) {
+	if id == "" {
+		if ASGSupervisor.Guard() == nil {
+			return nil, errors.Errorf("Global mass failure guard is not configured")
+		}
+		return ASGSupervisor.Guard(), nil
+	}
+
+	asg := ASGSupervisor.Get(domain.ID(id))
+	if asg == nil {
+		return nil, errors.Errorf("ASG with ID [%s], could not be found! Have you created it with /setup endpoint?", id)
+	}
+
+	if asg.Guard == nil {
+		return nil, errors.Errorf("ASG [%s] has no mass failure guard", id)
+	}
+
+	return asg.Guard, nil
+}
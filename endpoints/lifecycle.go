@@ -0,0 +1,103 @@
+package endpoints
+
+import (
+	"net/http"
+
+	"encoding/json"
+	"io/ioutil"
+
+	"github.com/juju/errors"
+	"github.com/nildev/artemis/domain"
+	"github.com/nildev/lib/utils"
+)
+
+type (
+	// CompleteLifecycleActionRequest type, Result is CONTINUE or ABANDON
+	CompleteLifecycleActionRequest struct {
+		ID       string
+		NodeID   string
+		HookName string
+		Result   string
+	}
+
+	// LifecycleActionHeartbeatRequest type
+	LifecycleActionHeartbeatRequest struct {
+		ID       string
+		NodeID   string
+		HookName string
+	}
+
+	LifecycleActionResponse struct{}
+)
+
+// CompleteLifecycleActionHandler API handler
+func CompleteLifecycleActionHandler(rw http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		utils.Respond(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	req := &CompleteLifecycleActionRequest{}
+	if err := json.Unmarshal(body, req); err != nil {
+		utils.Respond(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	asg := ASGSupervisor.Get(domain.ID(req.ID))
+	if asg == nil {
+		err := errors.Errorf("ASG with ID [%s], could not be found! Have you created it with /setup endpoint?", domain.ID(req.ID))
+		utils.Respond(rw, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	err = asg.CompleteLifecycleAction(domain.ID(req.NodeID), req.HookName, domain.LifecycleResult(req.Result))
+	if err != nil {
+		ctxLog.Error(err)
+		utils.Respond(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	respondLifecycleAction(rw)
+}
+
+// LifecycleActionHeartbeatHandler API handler
+func LifecycleActionHeartbeatHandler(rw http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		utils.Respond(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	req := &LifecycleActionHeartbeatRequest{}
+	if err := json.Unmarshal(body, req); err != nil {
+		utils.Respond(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	asg := ASGSupervisor.Get(domain.ID(req.ID))
+	if asg == nil {
+		err := errors.Errorf("ASG with ID [%s], could not be found! Have you created it with /setup endpoint?", domain.ID(req.ID))
+		utils.Respond(rw, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	err = asg.RecordLifecycleActionHeartbeat(domain.ID(req.NodeID), req.HookName)
+	if err != nil {
+		ctxLog.Error(err)
+		utils.Respond(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	respondLifecycleAction(rw)
+}
+
+func respondLifecycleAction(rw http.ResponseWriter) {
+	outResp := &LifecycleActionResponse{}
+	out, err := json.Marshal(outResp)
+	if err != nil {
+		utils.Respond(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	utils.Respond(rw, string(out), http.StatusOK)
+}
@@ -0,0 +1,220 @@
+package endpoints
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/nildev/artemis/domain"
+)
+
+type (
+	// exposition builds metrics in Prometheus text format
+	exposition struct {
+		bytes.Buffer
+	}
+)
+
+// MetricsHandler exposes operational metrics of artemis and its ASGs in
+// Prometheus text format
+func MetricsHandler(rw http.ResponseWriter, r *http.Request) {
+	asgs := []domain.ASGTelemetry{}
+	for _, asg := range ASGSupervisor.List() {
+		asgs = append(asgs, asg.Telemetry())
+	}
+
+	out := &exposition{}
+	writeASGMetrics(out, asgs)
+	writeProviderMetrics(out, domain.ProviderCalls())
+	if Statsd != nil {
+		writeStatsdMetrics(out, Statsd.Stats())
+	}
+	writeRuntimeMetrics(out)
+
+	rw.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	rw.WriteHeader(http.StatusOK)
+	rw.Write(out.Bytes())
+}
+
+func writeASGMetrics(out *exposition, asgs []domain.ASGTelemetry) {
+	gauges := []struct {
+		name, help string
+		value      func(domain.ASGTelemetry) int
+	}{
+		{"artemis_asg_desired_nodes", "Desired amount of nodes of ASG", func(t domain.ASGTelemetry) int { return t.Desired }},
+		{"artemis_asg_current_nodes", "Amount of nodes of ASG which are not terminated", func(t domain.ASGTelemetry) int { return t.Current }},
+		{"artemis_asg_healthy_nodes", "Amount of in service nodes of ASG", func(t domain.ASGTelemetry) int { return t.Healthy }},
+		{"artemis_asg_unhealthy_nodes", "Amount of unhealthy nodes of ASG", func(t domain.ASGTelemetry) int { return t.Unhealthy }},
+		{"artemis_asg_agent_streams", "Amount of open streams of node agents", func(t domain.ASGTelemetry) int { return t.AgentStreams }},
+	}
+
+	for _, g := range gauges {
+		out.family(g.name, g.help, "gauge")
+		for _, t := range asgs {
+			out.sample(g.name, domain.Labels{"asg": string(t.ID)}, float64(g.value(t)))
+		}
+	}
+
+	out.family("artemis_commands_executed_total", "Commands executed by ASG", "counter")
+	for _, t := range asgs {
+		for _, name := range sortedKeys(t.CommandsExecuted) {
+			out.sample("artemis_commands_executed_total", domain.Labels{"asg": string(t.ID), "command": name}, float64(t.CommandsExecuted[name]))
+		}
+	}
+
+	out.family("artemis_commands_failed_total", "Commands of ASG which have failed", "counter")
+	for _, t := range asgs {
+		for _, name := range sortedKeys(t.CommandsFailed) {
+			out.sample("artemis_commands_failed_total", domain.Labels{"asg": string(t.ID), "command": name}, float64(t.CommandsFailed[name]))
+		}
+	}
+
+	out.family("artemis_launch_duration_seconds", "Time it takes to create node and pass its launch hooks", "histogram")
+	for _, t := range asgs {
+		out.histogram("artemis_launch_duration_seconds", domain.Labels{"asg": string(t.ID)}, t.LaunchDuration)
+	}
+
+	out.family("artemis_evaluation_duration_seconds", "Time it takes to evaluate policies of ASG", "histogram")
+	for _, t := range asgs {
+		out.histogram("artemis_evaluation_duration_seconds", domain.Labels{"asg": string(t.ID)}, t.EvaluationDuration)
+	}
+
+	out.family("artemis_metrics_ingested_total", "Metrics reported to ASG and its nodes", "counter")
+	for _, t := range asgs {
+		out.sample("artemis_metrics_ingested_total", domain.Labels{"asg": string(t.ID)}, float64(t.MetricsIngested))
+	}
+}
+
+func writeProviderMetrics(out *exposition, calls []domain.ProviderCallTelemetry) {
+	out.family("artemis_provider_calls_total", "Calls made to provider API", "counter")
+	for _, c := range calls {
+		out.sample("artemis_provider_calls_total", domain.Labels{"provider": c.Provider, "call": c.Call}, float64(c.Count))
+	}
+
+	out.family("artemis_provider_errors_total", "Calls to provider API which have failed", "counter")
+	for _, c := range calls {
+		out.sample("artemis_provider_errors_total", domain.Labels{"provider": c.Provider, "call": c.Call}, float64(c.Errors))
+	}
+
+	out.family("artemis_provider_call_duration_seconds", "Latency of provider API calls", "histogram")
+	for _, c := range calls {
+		out.histogram("artemis_provider_call_duration_seconds", domain.Labels{"provider": c.Provider, "call": c.Call}, c.Latency)
+	}
+}
+
+func writeStatsdMetrics(out *exposition, stats domain.StatsdStats) {
+	counters := []struct {
+		name, help string
+		value      uint64
+	}{
+		{"artemis_statsd_packets_total", "StatsD packets received", stats.Packets},
+		{"artemis_statsd_lines_total", "StatsD lines received", stats.Lines},
+		{"artemis_statsd_malformed_total", "StatsD lines which could not be parsed", stats.Malformed},
+		{"artemis_statsd_dropped_total", "StatsD metrics of ASG or node which does not exist", stats.Dropped},
+	}
+
+	for _, c := range counters {
+		out.family(c.name, c.help, "counter")
+		out.sample(c.name, nil, float64(c.value))
+	}
+}
+
+func writeRuntimeMetrics(out *exposition) {
+	ms := runtime.MemStats{}
+	runtime.ReadMemStats(&ms)
+
+	out.family("go_info", "Information about the Go environment", "gauge")
+	out.sample("go_info", domain.Labels{"version": runtime.Version()}, 1)
+
+	out.family("go_goroutines", "Number of goroutines that currently exist", "gauge")
+	out.sample("go_goroutines", nil, float64(runtime.NumGoroutine()))
+
+	gauges := []struct {
+		name, kind, help string
+		value            uint64
+	}{
+		{"go_memstats_alloc_bytes", "gauge", "Number of bytes allocated and still in use", ms.Alloc},
+		{"go_memstats_alloc_bytes_total", "counter", "Total number of bytes allocated, even if freed", ms.TotalAlloc},
+		{"go_memstats_sys_bytes", "gauge", "Number of bytes obtained from system", ms.Sys},
+		{"go_memstats_heap_alloc_bytes", "gauge", "Number of heap bytes allocated and still in use", ms.HeapAlloc},
+		{"go_memstats_heap_inuse_bytes", "gauge", "Number of heap bytes that are in use", ms.HeapInuse},
+		{"go_memstats_heap_objects", "gauge", "Number of allocated objects", ms.HeapObjects},
+		{"go_memstats_mallocs_total", "counter", "Total number of mallocs", ms.Mallocs},
+		{"go_memstats_frees_total", "counter", "Total number of frees", ms.Frees},
+		{"go_memstats_gc_completed_total", "counter", "Number of completed GC cycles", uint64(ms.NumGC)},
+	}
+
+	for _, g := range gauges {
+		out.family(g.name, g.help, g.kind)
+		out.sample(g.name, nil, float64(g.value))
+	}
+
+	out.family("go_gc_pause_seconds_total", "Total time GC has stopped the world", "counter")
+	out.sample("go_gc_pause_seconds_total", nil, float64(ms.PauseTotalNs)/1e9)
+
+	out.family("go_memstats_last_gc_time_seconds", "Number of seconds since 1970 of last garbage collection", "gauge")
+	out.sample("go_memstats_last_gc_time_seconds", nil, float64(ms.LastGC)/1e9)
+}
+
+// family writes HELP and TYPE lines, they have to precede samples of family
+func (e *exposition) family(name, help, kind string) {
+	fmt.Fprintf(e, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(e, "# TYPE %s %s\n", name, kind)
+}
+
+func (e *exposition) sample(name string, labels domain.Labels, value float64) {
+	fmt.Fprintf(e, "%s%s %s\n", name, formatLabels(labels), strconv.FormatFloat(value, 'g', -1, 64))
+}
+
+func (e *exposition) histogram(name string, labels domain.Labels, h domain.Histogram) {
+	for i, bound := range h.Bounds {
+		e.sample(name+"_bucket", withLabel(labels, "le", strconv.FormatFloat(bound, 'g', -1, 64)), float64(h.Counts[i]))
+	}
+	e.sample(name+"_bucket", withLabel(labels, "le", "+Inf"), float64(h.Count))
+	e.sample(name+"_sum", labels, h.Sum)
+	e.sample(name+"_count", labels, float64(h.Count))
+}
+
+// formatLabels sorted by name, values are escaped as Prometheus expects
+func formatLabels(labels domain.Labels) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	names := []string{}
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	escape := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	pairs := []string{}
+	for _, name := range names {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, name, escape.Replace(labels[name])))
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func withLabel(labels domain.Labels, name, value string) domain.Labels {
+	rez := domain.Labels{name: value}
+	for k, v := range labels {
+		rez[k] = v
+	}
+
+	return rez
+}
+
+func sortedKeys(m map[string]uint64) []string {
+	keys := []string{}
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
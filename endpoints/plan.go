@@ -0,0 +1,120 @@
+package endpoints
+
+import (
+	"net/http"
+
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/juju/errors"
+	"github.com/nildev/artemis/domain"
+	"github.com/nildev/lib/utils"
+)
+
+type (
+	// PlanRequest type, at most one of policies can be proposed
+	PlanRequest struct {
+		ID            string
+		HealthPolicy  *HealthPolicy
+		BacklogPolicy *BacklogPolicy
+	}
+
+	// PlannedCommand type
+	PlannedCommand struct {
+		Order      int
+		Type       string
+		NodeID     string
+		ProviderID string
+		Reason     string
+	}
+
+	// PlanResponse type
+	PlanResponse struct {
+		Commands []PlannedCommand
+	}
+)
+
+// PlanHandler returns commands which next evaluation of ASG would execute,
+// nothing is changed
+func PlanHandler(rw http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		utils.Respond(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	req := &PlanRequest{}
+	if err := json.Unmarshal(body, req); err != nil {
+		utils.Respond(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	asg := ASGSupervisor.Get(domain.ID(req.ID))
+	if asg == nil {
+		err := errors.Errorf("ASG with ID [%s], could not be found! Have you created it with /setup endpoint?", domain.ID(req.ID))
+		utils.Respond(rw, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	if req.HealthPolicy != nil && req.BacklogPolicy != nil {
+		utils.Respond(rw, "Only one policy can be proposed at once", http.StatusBadRequest)
+		return
+	}
+
+	var proposed domain.Policy
+	if req.HealthPolicy != nil {
+		proposed, err = toDomainHealthPolicy(*req.HealthPolicy)
+	}
+	if req.BacklogPolicy != nil {
+		proposed, err = toDomainBacklogPolicy(*req.BacklogPolicy)
+	}
+	if err != nil {
+		utils.Respond(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	planned, err := asg.Plan(proposed)
+	if err != nil {
+		ctxLog.Error(err)
+		utils.Respond(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	outResp := &PlanResponse{Commands: []PlannedCommand{}}
+	for _, p := range planned {
+		outResp.Commands = append(outResp.Commands, toPlannedCommand(p))
+	}
+
+	out, err := json.Marshal(outResp)
+	if err != nil {
+		utils.Respond(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	utils.Respond(rw, string(out), http.StatusOK)
+}
+
+func toPlannedCommand(p domain.PlannedCommand) PlannedCommand {
+	pc := PlannedCommand{
+		Order:  int(p.Order),
+		Type:   strings.TrimPrefix(fmt.Sprintf("%T", p.Command), "*domain."),
+		Reason: p.Reason,
+	}
+
+	switch cmd := p.Command.(type) {
+	case *domain.Launch:
+		pc.ProviderID = cmd.Provider.ID
+	case *domain.Terminate:
+		pc.ProviderID = cmd.Provider.ID
+		pc.NodeID = string(cmd.NodeID)
+	case *domain.Relaunch:
+		pc.ProviderID = cmd.Provider.ID
+		pc.NodeID = string(cmd.NodeID)
+	case *domain.Heal:
+		pc.ProviderID = cmd.Provider.ID
+		pc.NodeID = string(cmd.NodeID)
+	}
+
+	return pc
+}
@@ -0,0 +1,83 @@
+package endpoints
+
+import (
+	"net/http"
+
+	"encoding/json"
+	"io/ioutil"
+
+	"github.com/juju/errors"
+	"github.com/nildev/artemis/domain"
+	"github.com/nildev/lib/utils"
+)
+
+type (
+	// ProcessesRequest type, all processes are used if Processes is empty.
+	// Reason is used only when processes are suspended
+	ProcessesRequest struct {
+		ID        string
+		Processes []string
+		Reason    string
+	}
+
+	ProcessesResponse struct{}
+)
+
+// SuspendProcessesHandler API handler
+func SuspendProcessesHandler(rw http.ResponseWriter, r *http.Request) {
+	handleProcesses(rw, r, func(asg *domain.AutoScalingGroup, p domain.Process, reason string) error {
+		return asg.SuspendProcess(p, reason)
+	})
+}
+
+// ResumeProcessesHandler API handler
+func ResumeProcessesHandler(rw http.ResponseWriter, r *http.Request) {
+	handleProcesses(rw, r, func(asg *domain.AutoScalingGroup, p domain.Process, reason string) error {
+		return asg.ResumeProcess(p)
+	})
+}
+
+func handleProcesses(rw http.ResponseWriter, r *http.Request, apply func(*domain.AutoScalingGroup, domain.Process, string) error) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		utils.Respond(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	req := &ProcessesRequest{}
+	if err := json.Unmarshal(body, req); err != nil {
+		utils.Respond(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	asg := ASGSupervisor.Get(domain.ID(req.ID))
+	if asg == nil {
+		err := errors.Errorf("ASG with ID [%s], could not be found! Have you created it with /setup endpoint?", domain.ID(req.ID))
+		utils.Respond(rw, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	processes := domain.Processes()
+	if len(req.Processes) > 0 {
+		processes = []domain.Process{}
+		for _, p := range req.Processes {
+			processes = append(processes, domain.Process(p))
+		}
+	}
+
+	for _, p := range processes {
+		if err := apply(asg, p, req.Reason); err != nil {
+			ctxLog.Error(err)
+			utils.Respond(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	outResp := &ProcessesResponse{}
+	out, err := json.Marshal(outResp)
+	if err != nil {
+		utils.Respond(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	utils.Respond(rw, string(out), http.StatusOK)
+}
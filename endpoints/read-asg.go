@@ -3,11 +3,67 @@ package endpoints
 import (
 	"net/http"
 
+	"encoding/json"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/nildev/artemis/domain"
 	"github.com/nildev/lib/utils"
 )
 
+type (
+	// Suspension type
+	Suspension struct {
+		Process string
+		Since   time.Time
+		Reason  string
+	}
+
+	// ReadASGResponse type
+	ReadASGResponse struct {
+		ID                  string
+		Nodes               int
+		LastScalingActivity time.Time
+		SuspendedProcesses  []Suspension
+		History             []NodeEvent
+	}
+)
+
+// ReadASGHandler returns status of ASG given by `ID` query param
 func ReadASGHandler(rw http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("ID")
+
+	asg := ASGSupervisor.Get(domain.ID(id))
+	if asg == nil {
+		err := errors.Errorf("ASG with ID [%s], could not be found! Have you created it with /setup endpoint?", id)
+		utils.Respond(rw, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	outResp := &ReadASGResponse{
+		ID:                  string(asg.ID),
+		Nodes:               len(asg.Nodes),
+		LastScalingActivity: asg.LastScalingActivity,
+		SuspendedProcesses:  []Suspension{},
+		History:             []NodeEvent{},
+	}
+
+	for _, s := range asg.Suspensions() {
+		outResp.SuspendedProcesses = append(outResp.SuspendedProcesses, Suspension{
+			Process: string(s.Process),
+			Since:   s.Since,
+			Reason:  s.Reason,
+		})
+	}
+
+	for _, e := range asg.Events() {
+		outResp.History = append(outResp.History, NodeEvent{Time: e.Time, Message: e.Message})
+	}
 
-	returnCode := http.StatusOK
-	utils.Respond(rw, "", returnCode)
+	out, err := json.Marshal(outResp)
+	if err != nil {
+		utils.Respond(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	utils.Respond(rw, string(out), http.StatusOK)
 }
@@ -3,11 +3,95 @@ package endpoints
 import (
 	"net/http"
 
+	"encoding/json"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/nildev/artemis/domain"
 	"github.com/nildev/lib/utils"
 )
 
+type (
+	// SignalStatus type
+	SignalStatus struct {
+		Source     string
+		Value      float64
+		DataPoints int
+		Healthy    bool
+	}
+
+	// NodeStatus type
+	NodeStatus struct {
+		ID            string
+		ProviderID    string
+		State         string
+		PublicIP      string
+		PrivateIP     string
+		HealthSignals []SignalStatus
+		// LastReportedAt and MissingData tell apart silent node from the one
+		// which reports that it is unhealthy
+		LastReportedAt time.Time
+		MissingData    bool
+		History        []NodeEvent
+	}
+
+	// NodeEvent type
+	NodeEvent struct {
+		Time    time.Time
+		Message string
+	}
+
+	// ReadNodesResponse type
+	ReadNodesResponse struct {
+		Nodes []NodeStatus
+	}
+)
+
+// ReadNodesHandler returns nodes of ASG given by `ID` query param
 func ReadNodesHandler(rw http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("ID")
+
+	asg := ASGSupervisor.Get(domain.ID(id))
+	if asg == nil {
+		err := errors.Errorf("ASG with ID [%s], could not be found! Have you created it with /setup endpoint?", id)
+		utils.Respond(rw, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	outResp := &ReadNodesResponse{Nodes: []NodeStatus{}}
+	for _, n := range asg.Nodes {
+		node := NodeStatus{
+			ID:             string(n.ID),
+			ProviderID:     n.Provider.ID,
+			State:          n.State.String(),
+			PublicIP:       n.PublicIface.IP.String(),
+			PrivateIP:      n.PrivateIface.IP.String(),
+			HealthSignals:  []SignalStatus{},
+			LastReportedAt: n.LastReportedAt,
+			MissingData:    n.MissingData,
+			History:        []NodeEvent{},
+		}
+
+		for _, s := range n.HealthSignals {
+			node.HealthSignals = append(node.HealthSignals, SignalStatus{
+				Source:     string(s.Source),
+				Value:      s.Value,
+				DataPoints: s.DataPoints,
+				Healthy:    s.Healthy,
+			})
+		}
+
+		for _, e := range n.History {
+			node.History = append(node.History, NodeEvent{Time: e.Time, Message: e.Message})
+		}
+
+		outResp.Nodes = append(outResp.Nodes, node)
+	}
 
-	returnCode := http.StatusOK
-	utils.Respond(rw, "", returnCode)
+	out, err := json.Marshal(outResp)
+	if err != nil {
+		utils.Respond(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	utils.Respond(rw, string(out), http.StatusOK)
 }
@@ -20,6 +20,8 @@ import (
 var (
 	ctxLog        *log.Entry
 	ASGSupervisor *domain.MultiSupervisor
+	// Statsd listener is optional
+	Statsd *domain.StatsdListener
 )
 
 func init() {
@@ -41,7 +43,7 @@ func BuildRoutes() []router.Routes {
 
 	asgRoutes := router.Routes{
 		BasePattern: "/api/v1",
-		Routes:      make([]router.Route, 9),
+		Routes:      make([]router.Route, 28),
 	}
 
 	asgRoutes.Routes[0] = router.Route{
@@ -61,7 +63,7 @@ func BuildRoutes() []router.Routes {
 			"POST",
 		},
 		Pattern:     "/asgs",
-		Protected:   false,
+		Protected:   true,
 		HandlerFunc: SetupHandler,
 		Queries:     []string{},
 	}
@@ -72,7 +74,7 @@ func BuildRoutes() []router.Routes {
 			"GET",
 		},
 		Pattern:     "/nodes",
-		Protected:   false,
+		Protected:   true,
 		HandlerFunc: ReadNodesHandler,
 		Queries:     []string{},
 	}
@@ -83,7 +85,7 @@ func BuildRoutes() []router.Routes {
 			"POST",
 		},
 		Pattern:     "/nodes",
-		Protected:   false,
+		Protected:   true,
 		HandlerFunc: AddNodeHandler,
 		Queries:     []string{},
 	}
@@ -94,7 +96,7 @@ func BuildRoutes() []router.Routes {
 			"DELETE",
 		},
 		Pattern:     "/nodes",
-		Protected:   false,
+		Protected:   true,
 		HandlerFunc: RemoveNodeHandler,
 		Queries:     []string{},
 	}
@@ -116,7 +118,7 @@ func BuildRoutes() []router.Routes {
 			"GET",
 		},
 		Pattern:     "/asg",
-		Protected:   false,
+		Protected:   true,
 		HandlerFunc: ReadASGHandler,
 		Queries:     []string{},
 	}
@@ -127,7 +129,7 @@ func BuildRoutes() []router.Routes {
 			"POST",
 		},
 		Pattern:     "/policies",
-		Protected:   false,
+		Protected:   true,
 		HandlerFunc: UpdatePolicyHandler,
 		Queries:     []string{},
 	}
@@ -138,13 +140,241 @@ func BuildRoutes() []router.Routes {
 			"DELETE",
 		},
 		Pattern:     "/asgs",
-		Protected:   false,
+		Protected:   true,
 		HandlerFunc: RemoveASGHandler,
 		Queries:     []string{},
 	}
 
+	asgRoutes.Routes[9] = router.Route{
+		Name: "github.com/nildev/artemis:SetDesiredCapacity",
+		Method: []string{
+			"POST",
+		},
+		Pattern:     "/capacity",
+		Protected:   true,
+		HandlerFunc: SetDesiredCapacityHandler,
+		Queries:     []string{},
+	}
+
+	asgRoutes.Routes[10] = router.Route{
+		Name: "github.com/nildev/artemis:CompleteLifecycleAction",
+		Method: []string{
+			"POST",
+		},
+		Pattern:     "/lifecycle/complete",
+		Protected:   true,
+		HandlerFunc: CompleteLifecycleActionHandler,
+		Queries:     []string{},
+	}
+
+	asgRoutes.Routes[11] = router.Route{
+		Name: "github.com/nildev/artemis:LifecycleActionHeartbeat",
+		Method: []string{
+			"POST",
+		},
+		Pattern:     "/lifecycle/heartbeat",
+		Protected:   true,
+		HandlerFunc: LifecycleActionHeartbeatHandler,
+		Queries:     []string{},
+	}
+
+	asgRoutes.Routes[12] = router.Route{
+		Name: "github.com/nildev/artemis:ReadCircuitBreaker",
+		Method: []string{
+			"GET",
+		},
+		Pattern:     "/breaker",
+		Protected:   true,
+		HandlerFunc: ReadCircuitBreakerHandler,
+		Queries:     []string{},
+	}
+
+	asgRoutes.Routes[13] = router.Route{
+		Name: "github.com/nildev/artemis:ResetCircuitBreaker",
+		Method: []string{
+			"POST",
+		},
+		Pattern:     "/breaker/reset",
+		Protected:   true,
+		HandlerFunc: ResetCircuitBreakerHandler,
+		Queries:     []string{},
+	}
+
+	asgRoutes.Routes[14] = router.Route{
+		Name: "github.com/nildev/artemis:ReadGuard",
+		Method: []string{
+			"GET",
+		},
+		Pattern:     "/guard",
+		Protected:   true,
+		HandlerFunc: ReadGuardHandler,
+		Queries:     []string{},
+	}
+
+	asgRoutes.Routes[15] = router.Route{
+		Name: "github.com/nildev/artemis:ConfirmReplacements",
+		Method: []string{
+			"POST",
+		},
+		Pattern:     "/guard/confirm",
+		Protected:   true,
+		HandlerFunc: ConfirmReplacementsHandler,
+		Queries:     []string{},
+	}
+
+	asgRoutes.Routes[16] = router.Route{
+		Name: "github.com/nildev/artemis:SuspendProcesses",
+		Method: []string{
+			"POST",
+		},
+		Pattern:     "/processes/suspend",
+		Protected:   true,
+		HandlerFunc: SuspendProcessesHandler,
+		Queries:     []string{},
+	}
+
+	asgRoutes.Routes[17] = router.Route{
+		Name: "github.com/nildev/artemis:ResumeProcesses",
+		Method: []string{
+			"POST",
+		},
+		Pattern:     "/processes/resume",
+		Protected:   true,
+		HandlerFunc: ResumeProcessesHandler,
+		Queries:     []string{},
+	}
+
+	asgRoutes.Routes[18] = router.Route{
+		Name: "github.com/nildev/artemis:Plan",
+		Method: []string{
+			"POST",
+		},
+		Pattern:     "/plan",
+		Protected:   true,
+		HandlerFunc: PlanHandler,
+		Queries:     []string{},
+	}
+
+	asgRoutes.Routes[19] = router.Route{
+		Name: "github.com/nildev/artemis:ReadShadowReport",
+		Method: []string{
+			"GET",
+		},
+		Pattern:     "/shadow",
+		Protected:   true,
+		HandlerFunc: ReadShadowReportHandler,
+		Queries:     []string{},
+	}
+
+	asgRoutes.Routes[20] = router.Route{
+		Name: "github.com/nildev/artemis:AddMetricsBatch",
+		Method: []string{
+			"POST",
+		},
+		Pattern:     "/metrics/batch",
+		Protected:   false,
+		HandlerFunc: AddMetricsBatchHandler,
+		Queries:     []string{},
+	}
+
+	asgRoutes.Routes[21] = router.Route{
+		Name: "github.com/nildev/artemis:ExchangeToken",
+		Method: []string{
+			"POST",
+		},
+		Pattern:     "/tokens",
+		Protected:   false,
+		HandlerFunc: ExchangeTokenHandler,
+		Queries:     []string{},
+	}
+
+	asgRoutes.Routes[22] = router.Route{
+		Name: "github.com/nildev/artemis:RotateToken",
+		Method: []string{
+			"POST",
+		},
+		Pattern:     "/tokens/rotate",
+		Protected:   false,
+		HandlerFunc: RotateTokenHandler,
+		Queries:     []string{},
+	}
+
+	asgRoutes.Routes[23] = router.Route{
+		Name: "github.com/nildev/artemis:RevokeToken",
+		Method: []string{
+			"DELETE",
+		},
+		Pattern:     "/tokens",
+		Protected:   true,
+		HandlerFunc: RevokeTokenHandler,
+		Queries:     []string{},
+	}
+
+	asgRoutes.Routes[24] = router.Route{
+		Name: "github.com/nildev/artemis:Stream",
+		Method: []string{
+			"GET",
+		},
+		Pattern:     "/stream",
+		Protected:   false,
+		HandlerFunc: StreamHandler,
+		Queries:     []string{},
+	}
+
+	asgRoutes.Routes[25] = router.Route{
+		Name: "github.com/nildev/artemis:SendStreamCommand",
+		Method: []string{
+			"POST",
+		},
+		Pattern:     "/stream/commands",
+		Protected:   true,
+		HandlerFunc: SendStreamCommandHandler,
+		Queries:     []string{},
+	}
+
+	asgRoutes.Routes[26] = router.Route{
+		Name: "github.com/nildev/artemis:IssueGroupToken",
+		Method: []string{
+			"POST",
+		},
+		Pattern:     "/tokens/group",
+		Protected:   true,
+		HandlerFunc: IssueGroupTokenHandler,
+		Queries:     []string{},
+	}
+
+	asgRoutes.Routes[27] = router.Route{
+		Name: "github.com/nildev/artemis:RotateBootstrapToken",
+		Method: []string{
+			"POST",
+		},
+		Pattern:     "/tokens/bootstrap",
+		Protected:   true,
+		HandlerFunc: RotateBootstrapHandler,
+		Queries:     []string{},
+	}
+
 	rt = append(rt, asgRoutes)
 
+	// Prometheus scrapes /metrics by default, so it is not under API prefix
+	metricsRoutes := router.Routes{
+		BasePattern: "/metrics",
+		Routes: []router.Route{
+			{
+				Name: "github.com/nildev/artemis:Metrics",
+				Method: []string{
+					"GET",
+				},
+				Pattern:     "",
+				Protected:   false,
+				HandlerFunc: MetricsHandler,
+				Queries:     []string{},
+			},
+		},
+	}
+
+	rt = append(rt, metricsRoutes)
+
 	return rt
 }
 
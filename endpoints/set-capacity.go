@@ -0,0 +1,67 @@
+package endpoints
+
+import (
+	"net/http"
+
+	"encoding/json"
+	"io/ioutil"
+
+	"github.com/juju/errors"
+	"github.com/nildev/artemis/domain"
+	"github.com/nildev/lib/utils"
+)
+
+type (
+	// SetDesiredCapacityRequest type, PolicyID can be omitted if ASG has only one policy
+	SetDesiredCapacityRequest struct {
+		ID            string
+		PolicyID      string
+		Desired       int
+		HonorCooldown bool
+	}
+
+	SetDesiredCapacityResponse struct{}
+)
+
+// SetDesiredCapacityHandler API handler
+func SetDesiredCapacityHandler(rw http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		utils.Respond(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	req := &SetDesiredCapacityRequest{}
+	if err := json.Unmarshal(body, req); err != nil {
+		utils.Respond(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	asg := ASGSupervisor.Get(domain.ID(req.ID))
+	if asg == nil {
+		err := errors.Errorf("ASG with ID [%s], could not be found! Have you created it with /setup endpoint?", domain.ID(req.ID))
+		utils.Respond(rw, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	policyID := domain.ID(req.PolicyID)
+	if policyID == "" && len(asg.Policies) == 1 {
+		for id := range asg.Policies {
+			policyID = id
+		}
+	}
+
+	if err := asg.SetDesiredCapacity(policyID, req.Desired, req.HonorCooldown); err != nil {
+		ctxLog.Error(err)
+		utils.Respond(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	outResp := &SetDesiredCapacityResponse{}
+	out, err := json.Marshal(outResp)
+	if err != nil {
+		utils.Respond(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	utils.Respond(rw, string(out), http.StatusOK)
+}
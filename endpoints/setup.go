@@ -18,13 +18,42 @@ type (
 
 	// SetupASGRequest type
 	SetupASGRequest struct {
-		ID string
+		ID       string
+		Cooldown int
+		// HealthCheckGracePeriod and HeartbeatTimeout in seconds
+		HealthCheckGracePeriod int
+		HeartbeatTimeout       int
 
-		Nodes        []Node
-		HealthPolicy HealthPolicy
+		Nodes          []Node
+		HealthPolicy   HealthPolicy
+		BacklogPolicy  *BacklogPolicy
+		WarmPool       *WarmPool
+		LifecycleHooks []LifecycleHook
+		HealthChecks   []HealthCheck
+		Scrapers       []Scraper
+		HealthModel    *HealthModel
+		HealingLadder  []HealingStep
+		CircuitBreaker *CircuitBreaker
+		Guard          *MassFailureGuard
+		// MaxSurge and MaxUnavailable are optional, both have to be set to limit rollout
+		MaxSurge       *int
+		MaxUnavailable *int
+		// Shadow ASG only records what it would have done
+		Shadow bool
+		// MetricRetention is optional, fields which are not set are defaults
+		MetricRetention *MetricRetention
+		// NodeTokens are optional, when set nodes have to present their own
+		// token when they report metrics
+		NodeTokens *NodeTokens
 	}
 
-	SetupASGResponse struct{}
+	// SetupASGResponse type, BootstrapToken, GroupToken for metrics of ASG
+	// itself and tokens of given nodes are only set if ASG requires node tokens
+	SetupASGResponse struct {
+		BootstrapToken string
+		GroupToken     string
+		NodeTokens     map[string]string
+	}
 )
 
 // SetupHandler API handler
@@ -43,24 +72,11 @@ func SetupHandler(rw http.ResponseWriter, r *http.Request) {
 
 	asg := domain.NewAutoScalingGroup(domain.ID(req.ID))
 
-	plc, err := domain.NewDesiredNodeAmountPerProviderPolicy(
-		domain.ID(req.HealthPolicy.ID),
-		req.HealthPolicy.Min,
-		req.HealthPolicy.Max,
-		req.HealthPolicy.Desired,
-		req.HealthPolicy.ConsecutiveChecks,
-		req.HealthPolicy.HealthyThreshold,
-		time.Duration(req.HealthPolicy.CheckInterval)*time.Second,
-		domain.Provider{
-			ID:     req.HealthPolicy.Provider.ID,
-			APIKey: req.HealthPolicy.Provider.APIKey,
-			Region: req.HealthPolicy.Provider.Region,
-			Size:   req.HealthPolicy.Provider.Size,
-			Image:  req.HealthPolicy.Provider.Image,
-			SSHKey: req.HealthPolicy.Provider.SSHKey,
-		},
-	)
-	policySet := domain.NewPolicySet(plc)
+	policySet, err := ToDomainPolicies(req)
+	if err != nil {
+		utils.Respond(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	nodeSet := domain.NewNodeSet()
 	for _, n := range req.Nodes {
@@ -85,11 +101,221 @@ func SetupHandler(rw http.ResponseWriter, r *http.Request) {
 	}
 
 	asg.Setup(nodeSet, policySet)
+	asg.Cooldown = time.Duration(req.Cooldown) * time.Second
+	asg.HealthCheckGracePeriod = time.Duration(req.HealthCheckGracePeriod) * time.Second
+	asg.HeartbeatTimeout = time.Duration(req.HeartbeatTimeout) * time.Second
+
+	if req.WarmPool != nil {
+		wp, err := domain.NewWarmPool(req.WarmPool.MinSize, req.WarmPool.MaxSize, req.WarmPool.ReuseOnScaleIn, toDomainProvider(req.WarmPool.Provider))
+		if err != nil {
+			utils.Respond(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var bootstrap *domain.HealthCheck
+		if req.WarmPool.Bootstrap != nil {
+			bootstrap, err = toDomainHealthCheck(*req.WarmPool.Bootstrap)
+			if err != nil {
+				utils.Respond(rw, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		if err := wp.SetBootstrap(bootstrap, time.Duration(req.WarmPool.BootstrapTimeout)*time.Second); err != nil {
+			utils.Respond(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		asg.WarmPool = wp
+	}
+
+	for _, h := range req.HealthChecks {
+		hc, err := toDomainHealthCheck(h)
+		if err != nil {
+			utils.Respond(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		asg.HealthChecks = append(asg.HealthChecks, hc)
+	}
+
+	for _, sc := range req.Scrapers {
+		mappings := []domain.ScrapeMapping{}
+		for _, m := range sc.Mappings {
+			mappings = append(mappings, domain.ScrapeMapping{
+				Series:     m.Series,
+				Labels:     domain.Labels(m.Labels),
+				Metric:     domain.MetricType(m.Metric),
+				Unit:       m.Unit,
+				Function:   domain.ScrapeFunction(m.Function),
+				IdleLabels: domain.Labels(m.IdleLabels),
+			})
+		}
+
+		scraper, err := domain.NewScraper(
+			sc.Interface,
+			sc.Port,
+			sc.Path,
+			time.Duration(sc.Interval)*time.Second,
+			time.Duration(sc.Timeout)*time.Second,
+			mappings,
+		)
+		if err != nil {
+			utils.Respond(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		asg.Scrapers = append(asg.Scrapers, scraper)
+	}
+
+	if req.HealthModel != nil {
+		signals := []domain.HealthSignal{}
+		for _, s := range req.HealthModel.Signals {
+			signals = append(signals, domain.HealthSignal{
+				Source: domain.HealthSource(s.Source),
+				Weight: s.Weight,
+			})
+		}
+
+		model, err := domain.NewHealthModel(
+			domain.HealthMode(req.HealthModel.Mode),
+			req.HealthModel.Quorum,
+			signals,
+			time.Duration(req.HealthModel.ProviderStatusInterval)*time.Second,
+		)
+		if err != nil {
+			utils.Respond(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		asg.HealthModel = model
+	}
+
+	if len(req.LifecycleHooks) > 0 {
+		hooks := []domain.LifecycleHook{}
+		for _, h := range req.LifecycleHooks {
+			hook, err := domain.NewLifecycleHook(
+				h.Name,
+				domain.LifecycleTransition(h.Transition),
+				time.Duration(h.HeartbeatTimeout)*time.Second,
+				domain.LifecycleResult(h.DefaultResult),
+				h.NotificationURL,
+			)
+			if err != nil {
+				utils.Respond(rw, err.Error(), http.StatusBadRequest)
+				return
+			}
+			hooks = append(hooks, hook)
+		}
+		asg.Hooks = domain.NewLifecycleHooks(hooks...)
+	}
+
+	if len(req.HealingLadder) > 0 {
+		steps := []domain.HealingStep{}
+		for _, st := range req.HealingLadder {
+			steps = append(steps, domain.HealingStep{
+				Action:  domain.HealingAction(st.Action),
+				Wait:    time.Duration(st.Wait) * time.Second,
+				Recheck: time.Duration(st.Recheck) * time.Second,
+			})
+		}
+
+		ladder, err := domain.NewHealingLadder(steps...)
+		if err != nil {
+			utils.Respond(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		asg.Healing = ladder
+	}
+
+	if req.CircuitBreaker != nil {
+		cb, err := domain.NewCircuitBreaker(
+			req.CircuitBreaker.Threshold,
+			time.Duration(req.CircuitBreaker.Window)*time.Second,
+			time.Duration(req.CircuitBreaker.Backoff)*time.Second,
+			time.Duration(req.CircuitBreaker.MaxBackoff)*time.Second,
+			req.CircuitBreaker.AlertURL,
+		)
+		if err != nil {
+			utils.Respond(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := cb.SetTrialTimeout(time.Duration(req.CircuitBreaker.TrialTimeout) * time.Second); err != nil {
+			utils.Respond(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		asg.Breaker = cb
+	}
+
+	if req.Guard != nil {
+		guard, err := domain.NewMassFailureGuard(
+			req.Guard.MaxUnhealthyFraction,
+			time.Duration(req.Guard.Window)*time.Second,
+			req.Guard.MinUnhealthy,
+			req.Guard.AlertURL,
+		)
+		if err != nil {
+			utils.Respond(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		asg.Guard = guard
+	}
+
+	if req.MaxSurge != nil || req.MaxUnavailable != nil {
+		if req.MaxSurge == nil || req.MaxUnavailable == nil {
+			utils.Respond(rw, "MaxSurge and MaxUnavailable have to be set together", http.StatusBadRequest)
+			return
+		}
+
+		budget, err := domain.NewRolloutBudget(*req.MaxSurge, *req.MaxUnavailable)
+		if err != nil {
+			utils.Respond(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		asg.Rollout = budget
+	}
+
+	if req.Shadow {
+		asg.Shadow = domain.NewShadowLog()
+	}
+
+	if req.MetricRetention != nil {
+		if err := asg.SetMetricRetention(toDomainRetention(*req.MetricRetention)); err != nil {
+			utils.Respond(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	outResp := &SetupASGResponse{}
+	if req.NodeTokens != nil {
+		credentials, err := domain.NewNodeCredentials(
+			time.Duration(req.NodeTokens.TTL)*time.Second,
+			time.Duration(req.NodeTokens.RotationGrace)*time.Second,
+		)
+		if err != nil {
+			utils.Respond(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		asg.Credentials = credentials
+
+		outResp.BootstrapToken = credentials.BootstrapToken()
+		group, err := credentials.IssueGroupToken(time.Now())
+		if err != nil {
+			utils.Respond(rw, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		outResp.GroupToken = group.Token
+		outResp.NodeTokens = map[string]string{}
+		for id := range nodeSet {
+			token, err := credentials.Issue(id, time.Now())
+			if err != nil {
+				utils.Respond(rw, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			outResp.NodeTokens[string(id)] = token.Token
+		}
+	}
 
 	// Start ASG routine
 	ASGSupervisor.Add(asg)
 
-	outResp := &SetupASGResponse{}
 	out, err := json.Marshal(outResp)
 	if err != nil {
 		utils.Respond(rw, err.Error(), http.StatusInternalServerError)
@@ -97,3 +323,133 @@ func SetupHandler(rw http.ResponseWriter, r *http.Request) {
 	}
 	utils.Respond(rw, string(out), http.StatusCreated)
 }
+
+// ToDomainPolicies returns policies of setup request, simulate command reads
+// them from the same request
+func ToDomainPolicies(req *SetupASGRequest) (domain.PolicySet, error) {
+	policies := []domain.Policy{}
+	if req.HealthPolicy.ID != "" {
+		plc, err := toDomainHealthPolicy(req.HealthPolicy)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, plc)
+	}
+
+	if req.BacklogPolicy != nil {
+		plc, err := toDomainBacklogPolicy(*req.BacklogPolicy)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, plc)
+	}
+
+	return domain.NewPolicySet(policies...), nil
+}
+
+func toDomainHealthPolicy(hp HealthPolicy) (domain.Policy, error) {
+	plc, err := domain.NewDesiredNodeAmountPerProviderPolicy(
+		domain.ID(hp.ID),
+		hp.Min,
+		hp.Max,
+		hp.Desired,
+		hp.ConsecutiveChecks,
+		hp.HealthyThreshold,
+		time.Duration(hp.CheckInterval)*time.Second,
+		toDomainProvider(hp.Provider),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if hp.MissingData != "" {
+		err = plc.(*domain.DesiredHealthyNodeAmountPerProviderPolicy).SetMissingData(domain.MissingDataTreatment(hp.MissingData))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if hp.Aggregation != "" {
+		aggregator, err := domain.NewAggregator(domain.Aggregation(hp.Aggregation), hp.EWMAAlpha)
+		if err != nil {
+			return nil, err
+		}
+		plc.(*domain.DesiredHealthyNodeAmountPerProviderPolicy).Aggregator = aggregator
+	}
+
+	return plc, nil
+}
+
+func toDomainBacklogPolicy(bp BacklogPolicy) (domain.Policy, error) {
+	plc, err := domain.NewBacklogPerInstancePolicy(
+		domain.ID(bp.ID),
+		bp.Min,
+		bp.Max,
+		bp.AcceptableBacklog,
+		time.Duration(bp.CheckInterval)*time.Second,
+		toDomainProvider(bp.Provider),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if bp.Aggregation != "" {
+		aggregator, err := domain.NewAggregator(domain.Aggregation(bp.Aggregation), bp.EWMAAlpha)
+		if err != nil {
+			return nil, err
+		}
+		plc.(*domain.BacklogPerInstancePolicy).Aggregator = aggregator
+	}
+
+	return plc, nil
+}
+
+func toDomainRetention(r MetricRetention) domain.Retention {
+	retention := domain.DefaultRetention
+	if r.Raw != 0 {
+		retention.Raw = time.Duration(r.Raw) * time.Second
+	}
+
+	if r.RawPoints != 0 {
+		retention.RawPoints = r.RawPoints
+	}
+
+	if r.Minutes != 0 {
+		retention.Minutes = r.Minutes
+	}
+
+	if r.Hours != 0 {
+		retention.Hours = r.Hours
+	}
+
+	if r.MaxSeries != 0 {
+		retention.MaxSeries = r.MaxSeries
+	}
+
+	return retention
+}
+
+func toDomainHealthCheck(h HealthCheck) (*domain.HealthCheck, error) {
+	return domain.NewHealthCheck(
+		domain.HealthCheckType(h.Type),
+		h.Interface,
+		h.Port,
+		h.Path,
+		h.ExpectedStatus,
+		h.ExpectedBody,
+		time.Duration(h.Interval)*time.Second,
+		time.Duration(h.Timeout)*time.Second,
+	)
+}
+
+func toDomainProvider(p Provider) domain.Provider {
+	return domain.Provider{
+		ID:       p.ID,
+		APIKey:   p.APIKey,
+		Region:   p.Region,
+		Size:     p.Size,
+		Image:    p.Image,
+		SSHKey:   p.SSHKey,
+		UserData: p.UserData,
+	}
+}
@@ -0,0 +1,101 @@
+package endpoints
+
+import (
+	"net/http"
+
+	"encoding/json"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/nildev/artemis/domain"
+	"github.com/nildev/lib/utils"
+)
+
+type (
+	// ShadowDecision type
+	ShadowDecision struct {
+		Time          time.Time
+		Action        string
+		NodeID        string
+		Reason        string
+		Outcome       string
+		ResolvedAt    time.Time
+		FalsePositive bool
+	}
+
+	// ReadShadowReportResponse type
+	ReadShadowReportResponse struct {
+		Since          time.Time
+		WouldHave      map[string]int
+		FalsePositives int
+		Removed        int
+		FleetChanged   int
+		Open           int
+		NodesAdded     []string
+		NodesRemoved   []string
+		Decisions      []ShadowDecision
+	}
+)
+
+// ReadShadowReportHandler returns decisions of ASG given by `ID` query param
+// which runs in shadow mode, compared with what has actually happened
+func ReadShadowReportHandler(rw http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("ID")
+
+	asg := ASGSupervisor.Get(domain.ID(id))
+	if asg == nil {
+		err := errors.Errorf("ASG with ID [%s], could not be found! Have you created it with /setup endpoint?", id)
+		utils.Respond(rw, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	if asg.Shadow == nil {
+		err := errors.Errorf("ASG [%s] does not run in shadow mode", id)
+		utils.Respond(rw, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	report := asg.Shadow.Report()
+	outResp := &ReadShadowReportResponse{
+		Since:          report.Since,
+		WouldHave:      map[string]int{},
+		FalsePositives: report.FalsePositives,
+		Removed:        report.Removed,
+		FleetChanged:   report.FleetChanged,
+		Open:           report.Open,
+		NodesAdded:     []string{},
+		NodesRemoved:   []string{},
+		Decisions:      []ShadowDecision{},
+	}
+
+	for action, num := range report.WouldHave {
+		outResp.WouldHave[string(action)] = num
+	}
+
+	for _, id := range report.NodesAdded {
+		outResp.NodesAdded = append(outResp.NodesAdded, string(id))
+	}
+
+	for _, id := range report.NodesRemoved {
+		outResp.NodesRemoved = append(outResp.NodesRemoved, string(id))
+	}
+
+	for _, d := range report.Decisions {
+		outResp.Decisions = append(outResp.Decisions, ShadowDecision{
+			Time:          d.Time,
+			Action:        string(d.Action),
+			NodeID:        string(d.NodeID),
+			Reason:        d.Reason,
+			Outcome:       d.Outcome,
+			ResolvedAt:    d.ResolvedAt,
+			FalsePositive: d.FalsePositive,
+		})
+	}
+
+	out, err := json.Marshal(outResp)
+	if err != nil {
+		utils.Respond(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	utils.Respond(rw, string(out), http.StatusOK)
+}
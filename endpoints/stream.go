@@ -0,0 +1,211 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/nildev/artemis/domain"
+	"github.com/nildev/lib/utils"
+)
+
+const (
+	wsNormalClosure = 1000
+	wsGoingAway     = 1001
+	wsPolicy        = 1008
+)
+
+type (
+	// StreamHeartbeat is sent by agent over its stream, Health is required.
+	// Token is only sent once agent has rotated its token, stream goes on
+	// with the new one
+	StreamHeartbeat struct {
+		Health  *float64
+		Metrics []Metric
+		Token   string
+	}
+
+	// StreamCommand is sent to agent over its stream, Interval is in seconds
+	StreamCommand struct {
+		Type     string
+		Interval int
+	}
+
+	// SendStreamCommandRequest type
+	SendStreamCommandRequest struct {
+		ID       string
+		NodeID   string
+		Type     string
+		Interval int
+	}
+)
+
+// StreamHandler opens WebSocket stream of node given by `ID` and `NodeID`
+// query params, optional `Interval` is in seconds. Agent sends heartbeats
+// over it and receives commands back, stream which drops turns node unhealthy.
+// Token of node is checked on every heartbeat, so stream is closed once it
+// has been revoked or has expired
+func StreamHandler(rw http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if err := checkWebsocketRequest(r); err != nil {
+		utils.Respond(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	asg := ASGSupervisor.Get(domain.ID(query.Get("ID")))
+	if asg == nil {
+		err := errors.Errorf("ASG with ID [%s], could not be found! Have you created it with /setup endpoint?", query.Get("ID"))
+		utils.Respond(rw, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	if query.Get("NodeID") == "" {
+		utils.Respond(rw, "NodeID is required", http.StatusBadRequest)
+		return
+	}
+
+	token := nodeToken(r)
+	if err := verifyToken(asg, query.Get("NodeID"), token); err != nil {
+		utils.Respond(rw, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	interval := 0
+	if query.Get("Interval") != "" {
+		v, err := strconv.Atoi(query.Get("Interval"))
+		if err != nil {
+			utils.Respond(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		interval = v
+	}
+
+	session, err := asg.OpenAgentSession(domain.ID(query.Get("NodeID")), time.Duration(interval)*time.Second, time.Now())
+	if err != nil {
+		utils.Respond(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	conn, err := upgradeWebsocket(rw, r)
+	if err != nil {
+		session.Close(time.Now())
+		ctxLog.Error(err)
+		return
+	}
+
+	ctxLog.Infof("Stream of node [%s] of [%s] is open", session.Node, asg.ID)
+	go writeStream(conn, session)
+
+	for {
+		message, err := conn.ReadMessage()
+		if err != nil {
+			break
+		}
+
+		heartbeat := StreamHeartbeat{}
+		if err := json.Unmarshal(message, &heartbeat); err != nil {
+			conn.Close(wsPolicy, "Heartbeat is not valid JSON")
+			break
+		}
+
+		if heartbeat.Health == nil {
+			conn.Close(wsPolicy, "Heartbeat has no Health")
+			break
+		}
+
+		if heartbeat.Token != "" {
+			token = heartbeat.Token
+		}
+
+		now := time.Now()
+		if err := verifyToken(asg, string(session.Node), token); err != nil {
+			conn.Close(wsPolicy, "Token is not valid anymore")
+			break
+		}
+
+		metrics := []domain.NamedMetric{}
+		for _, m := range heartbeat.Metrics {
+			name := domain.HealthMetricType
+			if m.Name != "" {
+				name = domain.MetricType(m.Name)
+			}
+
+			t := m.Time
+			if t.IsZero() {
+				t = now
+			}
+			metrics = append(metrics, domain.NewNamedMetric(name, m.Unit, domain.Labels(m.Labels), m.Value, t))
+		}
+
+		if err := session.Heartbeat(*heartbeat.Health, metrics, now); err != nil {
+			ctxLog.Errorf("Heartbeat of node [%s] of [%s] : %s", session.Node, asg.ID, err)
+		}
+	}
+
+	session.Close(time.Now())
+	ctxLog.Infof("Stream of node [%s] of [%s] is closed", session.Node, asg.ID)
+}
+
+// writeStream sends commands to agent and closes stream once session is
+// closed or agent misses its heartbeats
+func writeStream(conn *wsConn, session *domain.AgentSession) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case cmd := <-session.Commands():
+			out, err := json.Marshal(&StreamCommand{
+				Type:     string(cmd.Type),
+				Interval: int(cmd.Interval / time.Second),
+			})
+			if err == nil {
+				err = conn.WriteMessage(wsText, out)
+			}
+			if err != nil {
+				ctxLog.Errorf("Command could not be sent to node [%s] : %s", session.Node, err)
+				conn.Close(wsGoingAway, "")
+				return
+			}
+		case <-session.Done():
+			conn.Close(wsNormalClosure, "Stream is replaced or node is removed")
+			return
+		case now := <-ticker.C:
+			if session.Expired(now) {
+				conn.Close(wsPolicy, "Heartbeats are missing")
+				return
+			}
+		}
+	}
+}
+
+// SendStreamCommandHandler sends command to agent of node over its stream,
+// `interval` command changes report interval and `drain` asks agent to drain node
+func SendStreamCommandHandler(rw http.ResponseWriter, r *http.Request) {
+	req := &SendStreamCommandRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		utils.Respond(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	asg := ASGSupervisor.Get(domain.ID(req.ID))
+	if asg == nil {
+		err := errors.Errorf("ASG with ID [%s], could not be found! Have you created it with /setup endpoint?", req.ID)
+		utils.Respond(rw, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	err := asg.SendAgentCommand(domain.ID(req.NodeID), domain.AgentCommand{
+		Type:     domain.AgentCommandType(req.Type),
+		Interval: time.Duration(req.Interval) * time.Second,
+	})
+	if err != nil {
+		ctxLog.Error(err)
+		utils.Respond(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	utils.Respond(rw, nil, http.StatusOK)
+}
@@ -0,0 +1,148 @@
+package endpoints
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/nildev/artemis/domain"
+	. "gopkg.in/check.v1"
+)
+
+type StreamSuite struct {
+	server *httptest.Server
+}
+
+var _ = Suite(&StreamSuite{})
+
+func (s *StreamSuite) SetUpTest(c *C) {
+	s.server = httptest.NewServer(http.HandlerFunc(StreamHandler))
+}
+
+func (s *StreamSuite) TearDownTest(c *C) {
+	s.server.Close()
+	ASGSupervisor.Remove(domain.ID("stream-asg"))
+}
+
+// agentStream is client side of stream, as agent sees it
+type agentStream struct {
+	conn net.Conn
+	buf  *bufio.Reader
+}
+
+// openStream of node1 with given token, first command is already read
+func (s *StreamSuite) openStream(c *C, token string) *agentStream {
+	conn, err := net.Dial("tcp", s.server.Listener.Addr().String())
+	c.Assert(err, IsNil)
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	req, err := http.NewRequest("GET", s.server.URL+"/api/v1/stream?ID=stream-asg&NodeID=node1", nil)
+	c.Assert(err, IsNil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	req.Header.Set("Authorization", "Bearer "+token)
+	c.Assert(req.Write(conn), IsNil)
+
+	stream := &agentStream{conn: conn, buf: bufio.NewReader(conn)}
+	resp, err := http.ReadResponse(stream.buf, req)
+	c.Assert(err, IsNil)
+	c.Assert(resp.StatusCode, Equals, http.StatusSwitchingProtocols)
+	c.Assert(resp.Header.Get("Sec-WebSocket-Accept"), Equals, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=")
+
+	opcode, payload := stream.read(c)
+	c.Assert(opcode, Equals, byte(wsText))
+	c.Assert(string(payload), Equals, `{"Type":"interval","Interval":5}`)
+
+	return stream
+}
+
+func (as *agentStream) send(c *C, opcode byte, payload string) {
+	_, err := as.conn.Write(clientFrame(true, opcode, []byte(payload), true))
+	c.Assert(err, IsNil)
+}
+
+// read next frame of server, they are short and never masked
+func (as *agentStream) read(c *C) (byte, []byte) {
+	header := make([]byte, 2)
+	_, err := io.ReadFull(as.buf, header)
+	c.Assert(err, IsNil)
+
+	payload := make([]byte, header[1])
+	_, err = io.ReadFull(as.buf, payload)
+	c.Assert(err, IsNil)
+
+	return header[0] & 0x0F, payload
+}
+
+// heartbeat is sent and handled by the time pong comes back
+func (as *agentStream) heartbeat(c *C, message string) {
+	as.send(c, wsText, message)
+	as.send(c, wsPing, "")
+
+	opcode, _ := as.read(c)
+	c.Assert(opcode, Equals, byte(wsPong))
+}
+
+// closed expects stream to be closed with policy violation
+func (as *agentStream) closed(c *C, reason string) {
+	opcode, payload := as.read(c)
+	c.Assert(opcode, Equals, byte(wsClose))
+	c.Assert(binary.BigEndian.Uint16(payload), Equals, uint16(wsPolicy))
+	c.Assert(string(payload[2:]), Equals, reason)
+}
+
+// prepareStreamASG runs ASG with node1 which requires tokens, token of node1 is returned
+func prepareStreamASG(c *C) (*domain.AutoScalingGroup, *domain.NodeCredentials, domain.NodeToken) {
+	credentials, err := domain.NewNodeCredentials(time.Hour, time.Minute)
+	c.Assert(err, IsNil)
+	asg := prepareSecuredASG(c, "stream-asg", credentials, "node1")
+
+	token, err := credentials.Issue("node1", time.Now())
+	c.Assert(err, IsNil)
+
+	return asg, credentials, token
+}
+
+func (s *StreamSuite) TestIfHeartbeatWithoutHealthClosesStream(c *C) {
+	asg, _, token := prepareStreamASG(c)
+
+	stream := s.openStream(c, token.Token)
+	stream.heartbeat(c, `{"Health":1}`)
+	c.Assert(asg.AgentSessions(), HasLen, 1)
+
+	stream.send(c, wsText, `{"Metrics":[{"Name":"cpu","Value":42}]}`)
+	stream.closed(c, "Heartbeat has no Health")
+}
+
+func (s *StreamSuite) TestIfStreamIsClosedOnceTokenIsRevoked(c *C) {
+	_, credentials, token := prepareStreamASG(c)
+
+	stream := s.openStream(c, token.Token)
+	stream.heartbeat(c, `{"Health":1}`)
+
+	credentials.Revoke("node1")
+	stream.send(c, wsText, `{"Health":1}`)
+	stream.closed(c, "Token is not valid anymore")
+}
+
+func (s *StreamSuite) TestIfStreamGoesOnWithRotatedToken(c *C) {
+	asg, credentials, token := prepareStreamASG(c)
+
+	stream := s.openStream(c, token.Token)
+	rotated, err := credentials.Rotate("node1", token.Token, time.Now())
+	c.Assert(err, IsNil)
+	stream.heartbeat(c, `{"Health":1, "Token":"`+rotated.Token+`"}`)
+
+	// Issuing new token revokes previous ones, stream keeps token it got last
+	again, err := credentials.Issue("node1", time.Now())
+	c.Assert(err, IsNil)
+	stream.heartbeat(c, `{"Health":1, "Token":"`+again.Token+`"}`)
+	stream.heartbeat(c, `{"Health":1}`)
+	c.Assert(asg.AgentSessions(), HasLen, 1)
+}
@@ -0,0 +1,43 @@
+package endpoints
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/nildev/artemis/domain"
+	. "gopkg.in/check.v1"
+)
+
+// Run all test suites
+func TestAllSuite(t *testing.T) {
+	TestingT(t)
+}
+
+// prepareASG runs ASG with given nodes under ASGSupervisor, it has no
+// policies so its loop leaves nodes alone
+func prepareASG(c *C, id string, nodes ...string) *domain.AutoScalingGroup {
+	return prepareSecuredASG(c, id, nil, nodes...)
+}
+
+// prepareSecuredASG runs ASG which requires tokens of given credentials, they
+// are set before its loop is started and reads them
+func prepareSecuredASG(c *C, id string, credentials *domain.NodeCredentials, nodes ...string) *domain.AutoScalingGroup {
+	if ASGSupervisor == nil {
+		ASGSupervisor = domain.MakeMultiSupervisor()
+	}
+
+	set := domain.NewNodeSet()
+	for _, nodeID := range nodes {
+		node := domain.NewNode()
+		c.Assert(node.Setup(domain.ID(nodeID), domain.Provider{}, domain.NetworkInterface{}, domain.NetworkInterface{}), IsNil)
+		set[node.ID] = node
+	}
+
+	asg := domain.NewAutoScalingGroup(domain.ID(id))
+	asg.Log = ioutil.Discard
+	asg.Credentials = credentials
+	c.Assert(asg.Setup(set, domain.NewPolicySet()), IsNil)
+	ASGSupervisor.Add(asg)
+
+	return asg
+}
@@ -0,0 +1,238 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/nildev/artemis/domain"
+	"github.com/nildev/lib/utils"
+)
+
+type (
+	// ExchangeTokenRequest type
+	ExchangeTokenRequest struct {
+		ID             string
+		NodeID         string
+		BootstrapToken string
+	}
+
+	// RotateTokenRequest type, current token is given in Authorization header
+	RotateTokenRequest struct {
+		ID     string
+		NodeID string
+	}
+
+	// ASGTokenRequest type, for group token and bootstrap token of ASG
+	ASGTokenRequest struct {
+		ID string
+	}
+
+	// NodeTokenResponse type, NodeID is empty for group token
+	NodeTokenResponse struct {
+		NodeID    string
+		Token     string
+		ExpiresAt time.Time
+	}
+
+	// BootstrapTokenResponse type
+	BootstrapTokenResponse struct {
+		BootstrapToken string
+	}
+)
+
+// ExchangeTokenHandler exchanges bootstrap token of ASG for token of node,
+// request has to come from IP of the node
+func ExchangeTokenHandler(rw http.ResponseWriter, r *http.Request) {
+	req := &ExchangeTokenRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		utils.Respond(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	asg, node, err := credentialsOf(req.ID, req.NodeID)
+	if err != nil {
+		utils.Respond(rw, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	token, err := asg.Credentials.Exchange(node, req.BootstrapToken, remoteIP(r), time.Now())
+	if err != nil {
+		utils.Respond(rw, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	respondToken(rw, token)
+}
+
+// RotateTokenHandler issues new token for node, current one is accepted for
+// rotation grace period afterwards
+func RotateTokenHandler(rw http.ResponseWriter, r *http.Request) {
+	req := &RotateTokenRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		utils.Respond(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	asg, _, err := credentialsOf(req.ID, req.NodeID)
+	if err != nil {
+		utils.Respond(rw, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	token, err := asg.Credentials.Rotate(domain.ID(req.NodeID), nodeToken(r), time.Now())
+	if err != nil {
+		utils.Respond(rw, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	respondToken(rw, token)
+}
+
+// RevokeTokenHandler revokes token of node given by `ID` and `NodeID` query params
+func RevokeTokenHandler(rw http.ResponseWriter, r *http.Request) {
+	asg, _, err := credentialsOf(r.URL.Query().Get("ID"), r.URL.Query().Get("NodeID"))
+	if err != nil {
+		utils.Respond(rw, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	asg.Credentials.Revoke(domain.ID(r.URL.Query().Get("NodeID")))
+	utils.Respond(rw, nil, http.StatusOK)
+}
+
+// IssueGroupTokenHandler issues token for metrics of ASG itself, previous
+// group token is accepted for rotation grace period afterwards
+func IssueGroupTokenHandler(rw http.ResponseWriter, r *http.Request) {
+	req := &ASGTokenRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		utils.Respond(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	asg, err := asgCredentialsOf(req.ID)
+	if err != nil {
+		utils.Respond(rw, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	token, err := asg.Credentials.IssueGroupToken(time.Now())
+	if err != nil {
+		utils.Respond(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	respondToken(rw, token)
+}
+
+// RotateBootstrapHandler issues new bootstrap token of ASG, e.g. when it has
+// leaked. Previous one can be exchanged for rotation grace period afterwards
+func RotateBootstrapHandler(rw http.ResponseWriter, r *http.Request) {
+	req := &ASGTokenRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		utils.Respond(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	asg, err := asgCredentialsOf(req.ID)
+	if err != nil {
+		utils.Respond(rw, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	token, err := asg.Credentials.RotateBootstrap(time.Now())
+	if err != nil {
+		utils.Respond(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	out, err := json.Marshal(&BootstrapTokenResponse{BootstrapToken: token})
+	if err != nil {
+		utils.Respond(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	utils.Respond(rw, string(out), http.StatusOK)
+}
+
+// asgCredentialsOf returns ASG which requires node tokens
+func asgCredentialsOf(id string) (*domain.AutoScalingGroup, error) {
+	asg := ASGSupervisor.Get(domain.ID(id))
+	if asg == nil {
+		return nil, errors.Errorf("ASG with ID [%s], could not be found! Have you created it with /setup endpoint?", id)
+	}
+
+	if asg.Credentials == nil {
+		return nil, errors.Errorf("ASG [%s] does not require node tokens", id)
+	}
+
+	return asg, nil
+}
+
+// credentialsOf returns ASG which requires node tokens and its node
+func credentialsOf(id, nodeID string) (*domain.AutoScalingGroup, *domain.Node, error) {
+	asg, err := asgCredentialsOf(id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	node := asg.Nodes.GetByID(domain.ID(nodeID))
+	if node == nil {
+		return nil, nil, errors.Errorf("Node by ID %s was not found", nodeID)
+	}
+
+	return asg, node, nil
+}
+
+// verifyNodeToken checks token of request if ASG requires node tokens, metrics
+// of ASG itself require group token of ASG
+func verifyNodeToken(asg *domain.AutoScalingGroup, nodeID string, r *http.Request) error {
+	return verifyToken(asg, nodeID, nodeToken(r))
+}
+
+// verifyToken checks given token the same way as verifyNodeToken
+func verifyToken(asg *domain.AutoScalingGroup, nodeID, token string) error {
+	if asg.Credentials == nil {
+		return nil
+	}
+
+	if nodeID == "" {
+		return asg.Credentials.VerifyGroup(token, time.Now())
+	}
+
+	return asg.Credentials.Verify(domain.ID(nodeID), token, time.Now())
+}
+
+// nodeToken is given either as `Authorization: Bearer <token>` or `X-Node-Token` header
+func nodeToken(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+	}
+
+	return r.Header.Get("X-Node-Token")
+}
+
+// remoteIP of connection, forwarded headers are not trusted as node could set them
+func remoteIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return nil
+	}
+
+	return net.ParseIP(host)
+}
+
+func respondToken(rw http.ResponseWriter, token domain.NodeToken) {
+	out, err := json.Marshal(&NodeTokenResponse{
+		NodeID:    string(token.Node),
+		Token:     token.Token,
+		ExpiresAt: token.ExpiresAt,
+	})
+	if err != nil {
+		utils.Respond(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	utils.Respond(rw, string(out), http.StatusOK)
+}
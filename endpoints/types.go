@@ -9,12 +9,13 @@ type (
 
 	// Provider type
 	Provider struct {
-		ID     string
-		Region string
-		Size   string
-		APIKey string
-		Image  string
-		SSHKey string
+		ID       string
+		Region   string
+		Size     string
+		APIKey   string
+		Image    string
+		SSHKey   string
+		UserData string
 	}
 
 	// Node type
@@ -35,5 +36,133 @@ type (
 		CheckInterval     int
 		Provider          Provider
 		ConsecutiveChecks int
+		// MissingData is breaching (default), notBreaching or ignore
+		MissingData string
+		// Aggregation of node health, avg (default), min, max, sum, count,
+		// p50, p90, p99, rate or ewma. EWMAAlpha is used by ewma only
+		Aggregation string
+		EWMAAlpha   float64
+	}
+
+	// WarmPool type, warm node is powered off once it passes Bootstrap check,
+	// BootstrapTimeout is in seconds
+	WarmPool struct {
+		MinSize          int
+		MaxSize          int
+		ReuseOnScaleIn   bool
+		Provider         Provider
+		Bootstrap        *HealthCheck
+		BootstrapTimeout int
+	}
+
+	// HealthCheck type, Type is http, tcp or icmp, Interface is public or private,
+	// Interval and Timeout are in seconds
+	HealthCheck struct {
+		Type           string
+		Interface      string
+		Port           int
+		Path           string
+		ExpectedStatus int
+		ExpectedBody   string
+		Interval       int
+		Timeout        int
+	}
+
+	// Scraper type, Interface is public or private, Interval and Timeout are in seconds
+	Scraper struct {
+		Interface string
+		Port      int
+		Path      string
+		Interval  int
+		Timeout   int
+		Mappings  []ScrapeMapping
+	}
+
+	// ScrapeMapping type, Function is gauge, rate or busy
+	ScrapeMapping struct {
+		Series     string
+		Labels     map[string]string
+		Metric     string
+		Unit       string
+		Function   string
+		IdleLabels map[string]string
+	}
+
+	// HealthSignal type, Source is push, probe, provider, scrape or stream
+	HealthSignal struct {
+		Source string
+		Weight float64
+	}
+
+	// HealthModel type, Mode is all, any or quorum, ProviderStatusInterval is in seconds
+	HealthModel struct {
+		Mode                   string
+		Quorum                 float64
+		Signals                []HealthSignal
+		ProviderStatusInterval int
+	}
+
+	// LifecycleHook type, HeartbeatTimeout is in seconds
+	LifecycleHook struct {
+		Name             string
+		Transition       string
+		HeartbeatTimeout int
+		DefaultResult    string
+		NotificationURL  string
+	}
+
+	// HealingStep type, Action is reboot, power-cycle, rebuild or relaunch,
+	// Wait and Recheck are in seconds
+	HealingStep struct {
+		Action  string
+		Wait    int
+		Recheck int
+	}
+
+	// CircuitBreaker type, Window, Backoff, MaxBackoff and TrialTimeout are in seconds
+	CircuitBreaker struct {
+		Threshold    int
+		Window       int
+		Backoff      int
+		MaxBackoff   int
+		TrialTimeout int
+		AlertURL     string
+	}
+
+	// MassFailureGuard type, Window is in seconds
+	MassFailureGuard struct {
+		MaxUnhealthyFraction float64
+		Window               int
+		MinUnhealthy         int
+		AlertURL             string
+	}
+
+	// BacklogPolicy type
+	BacklogPolicy struct {
+		ID                string
+		Min               int
+		Max               int
+		AcceptableBacklog float64
+		CheckInterval     int
+		Provider          Provider
+		// Aggregation of backlog, the same as of HealthPolicy
+		Aggregation string
+		EWMAAlpha   float64
+	}
+
+	// NodeTokens type, TTL and RotationGrace are in seconds
+	NodeTokens struct {
+		TTL           int
+		RotationGrace int
+	}
+
+	// MetricRetention type, Raw is in seconds, Minutes and Hours are amounts
+	// of 1 minute and 1 hour rollups kept
+	MetricRetention struct {
+		Raw       int
+		RawPoints int
+		Minutes   int
+		Hours     int
+		MaxSeries int
 	}
 )
@@ -0,0 +1,237 @@
+package endpoints
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+// Minimal server side of WebSocket protocol (RFC 6455), enough for agents
+// to keep stream open. Extensions and subprotocols are not supported
+const (
+	websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xA
+
+	// maxWebsocketMessage is longest message agent can send
+	maxWebsocketMessage = 1 << 20
+	wsWriteTimeout      = 10 * time.Second
+)
+
+type wsConn struct {
+	conn      net.Conn
+	buf       *bufio.ReadWriter
+	writeLock sync.Mutex
+}
+
+// checkWebsocketRequest returns error if request is not WebSocket handshake
+func checkWebsocketRequest(r *http.Request) error {
+	if r.Method != "GET" {
+		return errors.Errorf("WebSocket handshake has to be GET request")
+	}
+
+	if !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") {
+		return errors.Errorf("Request is not WebSocket upgrade")
+	}
+
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		return errors.Errorf("WebSocket version [%s] is not supported", r.Header.Get("Sec-WebSocket-Version"))
+	}
+
+	if r.Header.Get("Sec-WebSocket-Key") == "" {
+		return errors.Errorf("Sec-WebSocket-Key header is required")
+	}
+
+	return nil
+}
+
+// upgradeWebsocket takes over connection of checked request
+func upgradeWebsocket(rw http.ResponseWriter, r *http.Request) (*wsConn, error) {
+	hj, ok := rw.(http.Hijacker)
+	if !ok {
+		return nil, errors.Errorf("Connection can not be upgraded")
+	}
+
+	conn, buf, err := hj.Hijack()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	buf.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+	buf.WriteString("Sec-WebSocket-Accept: " + websocketAccept(r.Header.Get("Sec-WebSocket-Key")) + "\r\n\r\n")
+	if err := buf.Flush(); err != nil {
+		conn.Close()
+		return nil, errors.Trace(err)
+	}
+
+	return &wsConn{conn: conn, buf: buf}, nil
+}
+
+func websocketAccept(key string) string {
+	h := sha1.Sum([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(h[:])
+}
+
+func headerContains(h http.Header, name, token string) bool {
+	for _, v := range h[http.CanonicalHeaderKey(name)] {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// ReadMessage returns next text or binary message, control frames are
+// handled on the way. io.EOF is returned once agent closes stream
+func (c *wsConn) ReadMessage() ([]byte, error) {
+	message := []byte{}
+	started := false
+
+	for {
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			return nil, err
+		}
+
+		switch opcode {
+		case wsPing:
+			if err := c.WriteMessage(wsPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case wsPong:
+			continue
+		case wsClose:
+			c.WriteMessage(wsClose, payload)
+			return nil, io.EOF
+		case wsText, wsBinary:
+			if started {
+				return nil, errors.Errorf("New message has started before previous one has finished")
+			}
+			started = true
+		case wsContinuation:
+			if !started {
+				return nil, errors.Errorf("Continuation frame without message")
+			}
+		default:
+			return nil, errors.Errorf("WebSocket opcode %d is not supported", opcode)
+		}
+
+		if len(message)+len(payload) > maxWebsocketMessage {
+			return nil, errors.Errorf("Message is larger than %d bytes", maxWebsocketMessage)
+		}
+		message = append(message, payload...)
+
+		if fin {
+			return message, nil
+		}
+	}
+}
+
+func (c *wsConn) readFrame() (bool, byte, []byte, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(c.buf, header); err != nil {
+		return false, 0, nil, err
+	}
+
+	fin := header[0]&0x80 != 0
+	opcode := header[0] & 0x0F
+	if header[0]&0x70 != 0 {
+		return false, 0, nil, errors.Errorf("WebSocket extensions are not supported")
+	}
+
+	// Frames of client have to be masked
+	if header[1]&0x80 == 0 {
+		return false, 0, nil, errors.Errorf("Frame of client is not masked")
+	}
+
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		ext := make([]byte, 2)
+		if _, err := io.ReadFull(c.buf, ext); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext))
+	case 127:
+		ext := make([]byte, 8)
+		if _, err := io.ReadFull(c.buf, ext); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext)
+	}
+
+	if opcode >= wsClose && (length > 125 || !fin) {
+		return false, 0, nil, errors.Errorf("Control frame is fragmented or too long")
+	}
+
+	if length > maxWebsocketMessage {
+		return false, 0, nil, errors.Errorf("Frame is larger than %d bytes", maxWebsocketMessage)
+	}
+
+	mask := make([]byte, 4)
+	if _, err := io.ReadFull(c.buf, mask); err != nil {
+		return false, 0, nil, err
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.buf, payload); err != nil {
+		return false, 0, nil, err
+	}
+
+	for i := range payload {
+		payload[i] = payload[i] ^ mask[i%4]
+	}
+
+	return fin, opcode, payload, nil
+}
+
+// WriteMessage as single unmasked frame, it is safe to call concurrently
+func (c *wsConn) WriteMessage(opcode byte, payload []byte) error {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+
+	header := []byte{0x80 | opcode}
+	switch {
+	case len(payload) < 126:
+		header = append(header, byte(len(payload)))
+	case len(payload) <= 0xFFFF:
+		header = append(header, 126, 0, 0)
+		binary.BigEndian.PutUint16(header[2:], uint16(len(payload)))
+	default:
+		header = append(header, 127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(header[2:], uint64(len(payload)))
+	}
+
+	c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	c.buf.Write(header)
+	c.buf.Write(payload)
+
+	return errors.Trace(c.buf.Flush())
+}
+
+// Close stream with given status code and reason
+func (c *wsConn) Close(code uint16, reason string) error {
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, code)
+	c.WriteMessage(wsClose, append(payload, reason...))
+
+	return c.conn.Close()
+}
@@ -0,0 +1,163 @@
+package endpoints
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+
+	. "gopkg.in/check.v1"
+)
+
+type WebsocketSuite struct{}
+
+var _ = Suite(&WebsocketSuite{})
+
+// clientFrame builds frame the way agent sends it, masked unless told otherwise
+func clientFrame(fin bool, opcode byte, payload []byte, masked bool) []byte {
+	first := opcode
+	if fin {
+		first = first | 0x80
+	}
+
+	frame := []byte{first}
+	maskBit := byte(0)
+	if masked {
+		maskBit = 0x80
+	}
+
+	switch {
+	case len(payload) < 126:
+		frame = append(frame, maskBit|byte(len(payload)))
+	case len(payload) <= 0xFFFF:
+		frame = append(frame, maskBit|126, 0, 0)
+		binary.BigEndian.PutUint16(frame[2:], uint16(len(payload)))
+	default:
+		frame = append(frame, maskBit|127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(frame[2:], uint64(len(payload)))
+	}
+
+	if !masked {
+		return append(frame, payload...)
+	}
+
+	mask := []byte{0x12, 0x34, 0x56, 0x78}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+
+	return frame
+}
+
+// prepareWsConn returns connection which reads given frames, what it writes
+// ends up in returned buffer
+func prepareWsConn(frames ...[]byte) (*wsConn, *bytes.Buffer) {
+	in := bytes.NewBuffer(bytes.Join(frames, nil))
+	out := &bytes.Buffer{}
+	conn, _ := net.Pipe()
+
+	return &wsConn{conn: conn, buf: bufio.NewReadWriter(bufio.NewReader(in), bufio.NewWriter(out))}, out
+}
+
+func (s *WebsocketSuite) TestIfMaskedMessageIsRead(c *C) {
+	conn, _ := prepareWsConn(clientFrame(true, wsText, []byte(`{"Health":1}`), true))
+
+	message, err := conn.ReadMessage()
+	c.Assert(err, IsNil)
+	c.Assert(string(message), Equals, `{"Health":1}`)
+
+	_, err = conn.ReadMessage()
+	c.Assert(err, Equals, io.EOF)
+}
+
+func (s *WebsocketSuite) TestIfUnmaskedFrameIsRejected(c *C) {
+	conn, _ := prepareWsConn(clientFrame(true, wsText, []byte("hello"), false))
+
+	_, err := conn.ReadMessage()
+	c.Assert(err, ErrorMatches, "Frame of client is not masked")
+}
+
+func (s *WebsocketSuite) TestIfFragmentedMessageIsJoinedAroundControlFrames(c *C) {
+	conn, out := prepareWsConn(
+		clientFrame(false, wsText, []byte("hel"), true),
+		clientFrame(true, wsPing, []byte("ping"), true),
+		clientFrame(false, wsContinuation, []byte("lo "), true),
+		clientFrame(true, wsPong, nil, true),
+		clientFrame(true, wsContinuation, []byte("agent"), true),
+	)
+
+	message, err := conn.ReadMessage()
+	c.Assert(err, IsNil)
+	c.Assert(string(message), Equals, "hello agent")
+
+	// Ping is answered with pong of the same payload, frames of server are not masked
+	c.Assert(out.Bytes(), DeepEquals, []byte{0x80 | wsPong, 4, 'p', 'i', 'n', 'g'})
+}
+
+func (s *WebsocketSuite) TestIfFragmentsOutOfOrderAreRejected(c *C) {
+	conn, _ := prepareWsConn(clientFrame(true, wsContinuation, []byte("lo"), true))
+	_, err := conn.ReadMessage()
+	c.Assert(err, ErrorMatches, "Continuation frame without message")
+
+	conn, _ = prepareWsConn(
+		clientFrame(false, wsText, []byte("hel"), true),
+		clientFrame(true, wsText, []byte("hello"), true),
+	)
+	_, err = conn.ReadMessage()
+	c.Assert(err, ErrorMatches, "New message has started before previous one has finished")
+}
+
+func (s *WebsocketSuite) TestIfCloseFrameIsEchoed(c *C) {
+	reason := []byte{0x03, 0xE8, 'b', 'y', 'e'}
+	conn, out := prepareWsConn(clientFrame(true, wsClose, reason, true))
+
+	_, err := conn.ReadMessage()
+	c.Assert(err, Equals, io.EOF)
+	c.Assert(out.Bytes(), DeepEquals, append([]byte{0x80 | wsClose, byte(len(reason))}, reason...))
+}
+
+func (s *WebsocketSuite) TestIfInvalidControlFramesAreRejected(c *C) {
+	conn, _ := prepareWsConn(clientFrame(false, wsPing, []byte("ping"), true))
+	_, err := conn.ReadMessage()
+	c.Assert(err, ErrorMatches, "Control frame is fragmented or too long")
+
+	conn, _ = prepareWsConn(clientFrame(true, wsPing, bytes.Repeat([]byte("a"), 126), true))
+	_, err = conn.ReadMessage()
+	c.Assert(err, ErrorMatches, "Control frame is fragmented or too long")
+
+	conn, _ = prepareWsConn(clientFrame(true, 0x3, []byte("a"), true))
+	_, err = conn.ReadMessage()
+	c.Assert(err, ErrorMatches, "WebSocket opcode 3 is not supported")
+
+	conn, _ = prepareWsConn([]byte{0x80 | 0x40 | wsText, 0x80})
+	_, err = conn.ReadMessage()
+	c.Assert(err, ErrorMatches, "WebSocket extensions are not supported")
+}
+
+func (s *WebsocketSuite) TestIfOversizedMessagesAreRejected(c *C) {
+	// Length alone is enough to reject frame, payload is not read
+	header := []byte{0x80 | wsText, 0x80 | 127, 0, 0, 0, 0, 0, 0, 0, 0}
+	binary.BigEndian.PutUint64(header[2:], maxWebsocketMessage+1)
+	conn, _ := prepareWsConn(header)
+	_, err := conn.ReadMessage()
+	c.Assert(err, ErrorMatches, "Frame is larger than .* bytes")
+
+	// Fragments which are small on their own can not add up to too much
+	half := bytes.Repeat([]byte("a"), maxWebsocketMessage/2+1)
+	conn, _ = prepareWsConn(
+		clientFrame(false, wsText, half, true),
+		clientFrame(true, wsContinuation, half, true),
+	)
+	_, err = conn.ReadMessage()
+	c.Assert(err, ErrorMatches, "Message is larger than .* bytes")
+}
+
+func (s *WebsocketSuite) TestIfServerFramesUseExtendedLength(c *C) {
+	conn, out := prepareWsConn()
+
+	c.Assert(conn.WriteMessage(wsText, bytes.Repeat([]byte("a"), 200)), IsNil)
+	c.Assert(out.Bytes()[:4], DeepEquals, []byte{0x80 | wsText, 126, 0, 200})
+	c.Assert(out.Len(), Equals, 204)
+}
@@ -1,44 +1,88 @@
 package main
 
 import (
-	"time"
-
-	"net/http"
-
 	"bytes"
-
-	"os"
-
+	"flag"
 	"fmt"
-
 	"io/ioutil"
+	"net/http"
+	"os"
 	"strconv"
+	"time"
 )
 
+// metricsd posts health of node to artemis every interval:
+//
+//	metricsd http://127.0.0.1:8080/api/v1/metrics <asg-id> <node-id> <health> <interval> [token]
+//
+// With -stream it keeps agent stream of node open instead and sends heartbeats
+// over it, artemis can change interval with interval command:
+//
+//	metricsd -stream ws://127.0.0.1:8080/api/v1/stream <asg-id> <node-id> <health> <interval> [token]
+//
+// Interval is in seconds, https and wss URLs are dialed over TLS
 func main() {
-	args := os.Args
+	streamMode := flag.Bool("stream", false, "keep agent stream open instead of posting metrics")
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) < 5 {
+		fmt.Printf("Usage: metricsd [-stream] <url> <asg-id> <node-id> <health> <interval> [token] \n")
+		os.Exit(1)
+	}
 
-	url := args[1]
-	asgId := args[2]
-	nodeId := args[3]
-	value := args[4]
-	interval, _ := strconv.Atoi(args[5])
+	url := args[0]
+	asgId := args[1]
+	nodeId := args[2]
+	value := args[3]
+	interval, _ := strconv.Atoi(args[4])
+	if interval <= 0 {
+		interval = 5
+	}
+	token := ""
+	if len(args) > 5 {
+		token = args[5]
+	}
 
+	if *streamMode {
+		health, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			fmt.Printf("Health is not a number: %s \n", err)
+			os.Exit(1)
+		}
+		streamMetrics(url, asgId, nodeId, health, interval, token)
+		return
+	}
+
+	postMetrics(url, asgId, nodeId, value, interval, token)
+}
+
+// postMetrics posts health of node every interval
+func postMetrics(url, asgId, nodeId, value string, interval int, token string) {
 	client := &http.Client{}
 	for {
 		var jsonStr = []byte(`{"ID":"` + asgId + `","NodeID":"` + nodeId + `", "Metrics":[{"Value":` + value + `, "Time":"` + time.Now().Format(time.RFC3339Nano) + `"}]}`)
 		fmt.Printf("Request: \n ---------\n %s \n ---------- \n", jsonStr)
 
 		req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonStr))
+		if err != nil {
+			fmt.Printf("Req err: %s \n\n", err)
+			os.Exit(1)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
 
 		resp, err := client.Do(req)
 		if err != nil {
 			fmt.Printf("Resp err: %s \n\n", err)
-		}
-		body, _ := ioutil.ReadAll(resp.Body)
+		} else {
+			body, _ := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
 
-		fmt.Printf("Resp: %+v \n\n", resp)
-		fmt.Printf("Body: %s \n\n", body)
+			fmt.Printf("Resp: %+v \n\n", resp)
+			fmt.Printf("Body: %s \n\n", body)
+		}
 		time.Sleep(time.Duration(interval) * time.Second)
 	}
 }
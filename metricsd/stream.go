@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	wsText  = 0x1
+	wsClose = 0x8
+	wsPing  = 0x9
+	wsPong  = 0xA
+)
+
+type (
+	heartbeat struct {
+		Health float64
+	}
+
+	command struct {
+		Type     string
+		Interval int
+	}
+
+	stream struct {
+		conn      net.Conn
+		buf       *bufio.Reader
+		writeLock sync.Mutex
+		commands  chan command
+		done      chan error
+	}
+)
+
+// streamMetrics keeps stream open, it is opened again once it is closed
+func streamMetrics(streamURL, asgId, nodeId string, value float64, interval int, token string) {
+	for {
+		s, err := openStream(streamURL, asgId, nodeId, interval, token)
+		if err != nil {
+			fmt.Printf("Stream err: %s \n\n", err)
+			time.Sleep(time.Duration(interval) * time.Second)
+			continue
+		}
+
+		err = s.report(value, &interval)
+		fmt.Printf("Stream closed: %s \n\n", err)
+		s.conn.Close()
+		time.Sleep(time.Duration(interval) * time.Second)
+	}
+}
+
+// openStream makes WebSocket handshake and starts reading commands
+func openStream(streamURL, asgId, nodeId string, interval int, token string) (*stream, error) {
+	u, err := url.Parse(streamURL)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "ws":
+		u.Scheme = "http"
+	case "wss":
+		u.Scheme = "https"
+	}
+	u.RawQuery = url.Values{"ID": {asgId}, "NodeID": {nodeId}, "Interval": {strconv.Itoa(interval)}}.Encode()
+
+	var conn net.Conn
+	if u.Scheme == "https" {
+		conn, err = tls.Dial("tcp", streamHost(u, "443"), &tls.Config{ServerName: u.Hostname()})
+	} else {
+		conn, err = net.Dial("tcp", streamHost(u, "80"))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := make([]byte, 16)
+	rand.Read(key)
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", base64.StdEncoding.EncodeToString(key))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	buf := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(buf, req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		body, _ := ioutil.ReadAll(resp.Body)
+		conn.Close()
+		return nil, fmt.Errorf("%s %s", resp.Status, body)
+	}
+
+	s := &stream{conn: conn, buf: buf, commands: make(chan command, 16), done: make(chan error, 1)}
+	go s.read()
+
+	return s, nil
+}
+
+// streamHost returns host of URL with given port if it has none
+func streamHost(u *url.URL, port string) string {
+	if u.Port() == "" {
+		return net.JoinHostPort(u.Hostname(), port)
+	}
+
+	return u.Host
+}
+
+// report sends heartbeat every interval until stream is closed
+func (s *stream) report(value float64, interval *int) error {
+	ticker := time.NewTicker(time.Duration(*interval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case cmd := <-s.commands:
+			fmt.Printf("Command: %+v \n\n", cmd)
+			if cmd.Type == "interval" && cmd.Interval > 0 {
+				*interval = cmd.Interval
+				ticker.Stop()
+				ticker = time.NewTicker(time.Duration(*interval) * time.Second)
+			}
+		case err := <-s.done:
+			return err
+		case <-ticker.C:
+			out, _ := json.Marshal(&heartbeat{Health: value})
+			fmt.Printf("Heartbeat: %s \n", out)
+			if err := s.write(wsText, out); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// read frames of server, they are never masked
+func (s *stream) read() {
+	for {
+		header := make([]byte, 2)
+		if _, err := io.ReadFull(s.buf, header); err != nil {
+			s.done <- err
+			return
+		}
+
+		length := uint64(header[1] & 0x7F)
+		switch length {
+		case 126:
+			ext := make([]byte, 2)
+			if _, err := io.ReadFull(s.buf, ext); err != nil {
+				s.done <- err
+				return
+			}
+			length = uint64(binary.BigEndian.Uint16(ext))
+		case 127:
+			ext := make([]byte, 8)
+			if _, err := io.ReadFull(s.buf, ext); err != nil {
+				s.done <- err
+				return
+			}
+			length = binary.BigEndian.Uint64(ext)
+		}
+
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(s.buf, payload); err != nil {
+			s.done <- err
+			return
+		}
+
+		switch header[0] & 0x0F {
+		case wsText:
+			cmd := command{}
+			if err := json.Unmarshal(payload, &cmd); err == nil {
+				s.commands <- cmd
+			}
+		case wsPing:
+			s.write(wsPong, payload)
+		case wsClose:
+			reason := ""
+			if len(payload) > 2 {
+				reason = string(payload[2:])
+			}
+			s.write(wsClose, payload)
+			s.done <- fmt.Errorf("closed by artemis %s", reason)
+			return
+		}
+	}
+}
+
+// write masked frame, frames of client have to be masked. Pong and close
+// are written by reader while heartbeats are sent
+func (s *stream) write(opcode byte, payload []byte) error {
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+
+	frame := []byte{0x80 | opcode}
+	switch {
+	case len(payload) < 126:
+		frame = append(frame, 0x80|byte(len(payload)))
+	case len(payload) <= 0xFFFF:
+		frame = append(frame, 0x80|126, 0, 0)
+		binary.BigEndian.PutUint16(frame[2:], uint16(len(payload)))
+	default:
+		frame = append(frame, 0x80|127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(frame[2:], uint64(len(payload)))
+	}
+
+	mask := make([]byte, 4)
+	rand.Read(mask)
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+
+	_, err := s.conn.Write(frame)
+	return err
+}
@@ -2,6 +2,7 @@ package server
 
 import (
 	"net/http"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/nildev/artemis/config"
@@ -20,6 +21,32 @@ type Server struct {
 // New type
 func New(cfg config.Config) (*Server, error) {
 	endpoints.ASGSupervisor = domain.MakeMultiSupervisor()
+	if cfg.MassFailureFraction > 0 {
+		guard, err := domain.NewMassFailureGuard(
+			cfg.MassFailureFraction,
+			time.Duration(cfg.MassFailureWindow)*time.Second,
+			cfg.MassFailureMinUnhealthy,
+			cfg.MassFailureAlertURL,
+		)
+		if err != nil {
+			return nil, err
+		}
+		endpoints.ASGSupervisor.SetGuard(guard)
+	}
+	endpoints.ASGSupervisor.SetShadow(cfg.ShadowMode)
+
+	if cfg.StatsdAddress != "" {
+		listener, err := domain.NewStatsdListener(
+			cfg.StatsdAddress,
+			time.Duration(cfg.StatsdFlushInterval)*time.Second,
+			endpoints.ASGSupervisor,
+		)
+		if err != nil {
+			return nil, err
+		}
+		endpoints.Statsd = listener
+	}
+
 	srv := Server{
 		cfg:     cfg,
 		stop:    nil,
@@ -40,6 +67,13 @@ func (s *Server) Run() {
 		endpoints.ASGSupervisor.Run()
 	}()
 
+	if endpoints.Statsd != nil {
+		ctxLog.Infof("Starting StatsD listener [%s]", s.cfg.StatsdAddress)
+		if err := endpoints.Statsd.Listen(); err != nil {
+			ctxLog.Fatalf("Unable to create StatsD listener, %s", err)
+		}
+	}
+
 	go func() {
 		ctxLog.Infof("Starting HTTP server ...")
 		if err := http.ListenAndServe(s.cfg.IP+":"+s.cfg.Port, s.handler); err != nil {
@@ -50,6 +84,9 @@ func (s *Server) Run() {
 
 // Stop server
 func (s *Server) Stop() {
+	if endpoints.Statsd != nil {
+		endpoints.Statsd.Close()
+	}
 	close(s.stop)
 }
 
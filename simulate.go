@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/nildev/artemis/domain"
+	"github.com/nildev/artemis/endpoints"
+)
+
+// simulate replays recorded metrics through policies of setup request given
+// by -policies, or through health policy given by flags, and prints timeline
+// of capacity, replacements and time to recover
+func simulate(args []string) error {
+	simset := flag.NewFlagSet("artemisd simulate", flag.ExitOnError)
+
+	metricsPath := simset.String("metrics", "-", "Path to JSON lines of recorded metrics, - reads them from stdin")
+	policiesPath := simset.String("policies", "", "Path to JSON of /setup request, its policies are used instead of policy flags")
+	min := simset.Int("min", 1, "Min amount of nodes")
+	max := simset.Int("max", 1, "Max amount of nodes")
+	desired := simset.Int("desired", 1, "Desired amount of healthy nodes")
+	healthyThreshold := simset.Float64("healthy_threshold", 0.7, "Avg health value which node has to reach to be healthy")
+	checkInterval := simset.Int("check_interval", 10, "Window (in seconds) of metrics node health is calculated from")
+	consecutiveChecks := simset.Int("consecutive_checks", 3, "Amount of failed checks after which node is replaced")
+	missingData := simset.String("missing_data", string(domain.MissingDataBreaching), "How nodes without data are treated: breaching, notBreaching or ignore")
+	tick := simset.Int("tick", 5, "How often (in seconds) policy is evaluated")
+	bootDelay := simset.Int("boot_delay", 60, "Time (in seconds) simulated node needs to report healthy")
+	failureRate := simset.Float64("failure_rate", 0, "Part of simulated nodes which never boot")
+	seed := simset.Int64("seed", 1, "Seed of simulated failures")
+	gracePeriod := simset.Int("grace_period", 300, "Health check grace period (in seconds) of launched nodes")
+	heartbeatTimeout := simset.Int("heartbeat_timeout", 0, "Time (in seconds) after which silent node is missing data, 0 disables it")
+	recoveryWindow := simset.Int("recovery_window", 600, "Time (in seconds) within which replaced node reporting healthy is false positive")
+	asJSON := simset.Bool("json", false, "Print whole result as JSON")
+	verbose := simset.Bool("verbose", false, "Print activity of simulated ASG to stderr")
+
+	simset.Parse(args)
+
+	in := os.Stdin
+	if *metricsPath != "-" {
+		f, err := os.Open(*metricsPath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	metrics, err := domain.ReadRecordedMetrics(in)
+	if err != nil {
+		return err
+	}
+
+	var policies domain.PolicySet
+	if *policiesPath != "" {
+		policies, err = readPolicies(*policiesPath)
+	} else {
+		policies, err = flagPolicy(*min, *max, *desired, *consecutiveChecks, *healthyThreshold, *checkInterval, *missingData)
+	}
+	if err != nil {
+		return err
+	}
+
+	provider, err := domain.NewSimulatedProvider(time.Duration(*bootDelay)*time.Second, *failureRate, *seed)
+	if err != nil {
+		return err
+	}
+
+	simulator, err := domain.NewSimulator(policies, provider, time.Duration(*tick)*time.Second)
+	if err != nil {
+		return err
+	}
+	simulator.HealthCheckGracePeriod = time.Duration(*gracePeriod) * time.Second
+	simulator.HeartbeatTimeout = time.Duration(*heartbeatTimeout) * time.Second
+	simulator.RecoveryWindow = time.Duration(*recoveryWindow) * time.Second
+	if *verbose {
+		simulator.Log = os.Stderr
+	}
+
+	result, err := simulator.Run(metrics)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		return json.NewEncoder(os.Stdout).Encode(result)
+	}
+
+	printSimulation(os.Stdout, result)
+	return nil
+}
+
+// readPolicies reads policies of /setup request from given file
+func readPolicies(path string) (domain.PolicySet, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	req := &endpoints.SetupASGRequest{}
+	if err := json.NewDecoder(f).Decode(req); err != nil {
+		return nil, err
+	}
+
+	return endpoints.ToDomainPolicies(req)
+}
+
+// flagPolicy returns health policy given by flags
+func flagPolicy(min, max, desired, consecutiveChecks int, healthyThreshold float64, checkInterval int, missingData string) (domain.PolicySet, error) {
+	plc, err := domain.NewDesiredNodeAmountPerProviderPolicy(
+		domain.ID("simulation"),
+		min,
+		max,
+		desired,
+		consecutiveChecks,
+		healthyThreshold,
+		time.Duration(-checkInterval)*time.Second,
+		domain.Provider{ID: "simulated"},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	err = plc.(*domain.DesiredHealthyNodeAmountPerProviderPolicy).SetMissingData(domain.MissingDataTreatment(missingData))
+	if err != nil {
+		return nil, err
+	}
+
+	return domain.NewPolicySet(plc), nil
+}
+
+// printSimulation prints summary, events and points of timeline where capacity has changed
+func printSimulation(w io.Writer, result *domain.SimulationResult) {
+	fmt.Fprintf(w, "Replayed:          %s - %s\n", result.Start.Format(time.RFC3339), result.End.Format(time.RFC3339))
+	fmt.Fprintf(w, "Launches:          %d (%d never booted)\n", result.Launches, result.FailedLaunches)
+	fmt.Fprintf(w, "Replacements:      %d\n", result.Replacements)
+	fmt.Fprintf(w, "Terminations:      %d\n", result.Terminations)
+	fmt.Fprintf(w, "False positives:   %d\n", result.FalsePositives)
+	fmt.Fprintf(w, "Recoveries:        %d\n", len(result.TimesToRecover))
+	fmt.Fprintf(w, "Mean time to recover: %s\n", result.MeanTimeToRecover)
+	fmt.Fprintf(w, "Max time to recover:  %s\n", result.MaxTimeToRecover)
+
+	fmt.Fprintf(w, "\nEvents:\n")
+	for _, e := range result.Events {
+		fmt.Fprintf(w, "%s %s\n", e.Time.Format(time.RFC3339), e.Message)
+	}
+
+	fmt.Fprintf(w, "\nTimeline:\n")
+	fmt.Fprintf(w, "%-25s %6s %10s %8s %10s %8s\n", "Time", "Nodes", "InService", "Pending", "Unhealthy", "Desired")
+	var last *domain.SimulationPoint
+	for i, p := range result.Timeline {
+		if last != nil && p.Nodes == last.Nodes && p.InService == last.InService && p.Pending == last.Pending && p.Unhealthy == last.Unhealthy && p.Desired == last.Desired {
+			continue
+		}
+		fmt.Fprintf(w, "%-25s %6d %10d %8d %10d %8d\n", p.Time.Format(time.RFC3339), p.Nodes, p.InService, p.Pending, p.Unhealthy, p.Desired)
+		last = &result.Timeline[i]
+	}
+}